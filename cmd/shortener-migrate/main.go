@@ -0,0 +1,134 @@
+// shortener-migrate copy short urls between stores.
+//
+// Copy from file store into postgres with resume by checkpoint:
+//
+//	shortener-migrate -src-file data/store.json -dst-dsn "postgres://..." -checkpoint data/migrate.checkpoint
+//
+// Compare count and checksum of stores after copy:
+//
+//	shortener-migrate -src-file data/store.json -dst-dsn "postgres://..." -verify
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	logStd "log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/config"
+	"github.com/shreyner/go-shortener/internal/pkg/logger"
+	"github.com/shreyner/go-shortener/internal/storage"
+	"github.com/shreyner/go-shortener/internal/transfer"
+)
+
+type options struct {
+	srcFile        string
+	srcDSN         string
+	dstFile        string
+	dstDSN         string
+	batchSize      int
+	checkpointPath string
+	verify         bool
+}
+
+func main() {
+	var opts options
+
+	flag.StringVar(&opts.srcFile, "src-file", "", "Путь до файла хранилища источника")
+	flag.StringVar(&opts.srcDSN, "src-dsn", "", "Конфиг подключения к db источника")
+	flag.StringVar(&opts.dstFile, "dst-file", "", "Путь до файла хранилища назначения")
+	flag.StringVar(&opts.dstDSN, "dst-dsn", "", "Конфиг подключения к db назначения")
+	flag.IntVar(&opts.batchSize, "batch", 500, "Количество ссылок в одной пачке")
+	flag.StringVar(&opts.checkpointPath, "checkpoint", "", "Файл для сохранения прогресса, при наличии копирование продолжится")
+	flag.BoolVar(&opts.verify, "verify", false, "Только сравнить количество и контрольные суммы хранилищ")
+	flag.Parse()
+
+	log, err := logger.InitLogger(&config.Config{})
+
+	if err != nil {
+		logStd.Fatal("error initilizing logger: %w", err)
+	}
+	defer log.Sync()
+
+	if err := run(log, &opts); err != nil {
+		log.Fatal("migrate failed", zap.Error(err))
+	}
+}
+
+func run(log *zap.Logger, opts *options) error {
+	if opts.srcFile == "" && opts.srcDSN == "" {
+		return errors.New("source is required: -src-file or -src-dsn")
+	}
+
+	if opts.dstFile == "" && opts.dstDSN == "" {
+		return errors.New("destination is required: -dst-file or -dst-dsn")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	src, err := storage.NewStorage(log.Named("source"), opts.srcFile, opts.srcDSN, nil)
+
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := storage.NewStorage(log.Named("destination"), opts.dstFile, opts.dstDSN, nil)
+
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if opts.verify {
+		result, err := transfer.Verify(ctx, src.ShortURL, dst.ShortURL)
+
+		if err != nil {
+			return err
+		}
+
+		log.Info(
+			"verify result",
+			zap.Int("sourceCount", result.Source.Count),
+			zap.Uint64("sourceChecksum", result.Source.Checksum),
+			zap.Int("destinationCount", result.Destination.Count),
+			zap.Uint64("destinationChecksum", result.Destination.Checksum),
+		)
+
+		if !result.Match() {
+			return errors.New("stores are different")
+		}
+
+		log.Info("stores are equal")
+
+		return nil
+	}
+
+	progress, err := transfer.Copy(ctx, log, src.ShortURL, dst.ShortURL, transfer.Options{
+		BatchSize:      opts.batchSize,
+		CheckpointPath: opts.checkpointPath,
+		OnProgress: func(progress transfer.Progress) {
+			log.Info(
+				"copy progress",
+				zap.Int("copied", progress.Copied),
+				zap.Int("skipped", progress.Skipped),
+				zap.Int("total", progress.Total),
+				zap.String("lastID", progress.LastID),
+			)
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	log.Info("copy finished", zap.Int("copied", progress.Copied), zap.Int("skipped", progress.Skipped))
+
+	return nil
+}
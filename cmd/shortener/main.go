@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	logStd "log"
+	"os"
 
 	"go.uber.org/zap"
 
@@ -46,5 +48,13 @@ func main() {
 		zap.String("trusted_subnet", cfg.TrustedSubnet),
 	)
 
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := app.Migrate(log, &cfg, os.Stdout, args[1:]); err != nil {
+			log.Fatal("error migrate", zap.Error(err))
+		}
+
+		return
+	}
+
 	app.NewApp(log, &cfg)
 }
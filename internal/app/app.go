@@ -5,13 +5,16 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/shreyner/go-shortener/internal/config"
 	"github.com/shreyner/go-shortener/internal/handlers"
 	"github.com/shreyner/go-shortener/internal/middlewares"
+	"github.com/shreyner/go-shortener/internal/pkg/clicks"
 	"github.com/shreyner/go-shortener/internal/pkg/fans"
+	"github.com/shreyner/go-shortener/internal/pkg/webhooks"
 	"github.com/shreyner/go-shortener/internal/rpcservices"
 	"github.com/shreyner/go-shortener/internal/server"
 	"github.com/shreyner/go-shortener/internal/service"
@@ -27,7 +30,7 @@ func NewApp(
 	log.Info("Start app...")
 
 	log.Info("Create storage...")
-	store, err := storage.NewStorage(log, cfg.FileStoragePath, cfg.DataBaseDSN)
+	store, err := storage.NewStorage(log, cfg.FileStoragePath, cfg.DataBaseDSN, cfg.ReplicaDSNList())
 
 	if err != nil {
 		log.Error("", zap.Error(err))
@@ -40,8 +43,15 @@ func NewApp(
 	//	}
 	//}()
 
+	idGenerator, err := service.NewIDGenerator(cfg.IDGenerator, cfg.IDLength, cfg.IDAlphabet)
+
+	if err != nil {
+		log.Error("can't create id generator", zap.Error(err))
+		return
+	}
+
 	log.Info("Create services...")
-	services, err := service.NewService(log, store.ShortURL, []byte(cfg.SignKey))
+	services, err := service.NewService(log, store.ShortURL, store.Clicks, store.Webhooks, []byte(cfg.SignKey), idGenerator)
 
 	if err != nil {
 		log.Error("can't create services", zap.Error(err))
@@ -52,6 +62,18 @@ func NewApp(
 	fansShortService := fans.NewFansShortService(log, store.ShortURL, 4)
 	//defer fansShortService.Close()
 
+	log.Info("Create expiredSweeper...")
+	expiredSweeper := service.NewExpiredSweeper(log, store.ShortURL)
+
+	log.Info("Create importer...")
+	importer := service.NewImporter(log, services.ShorterService, cfg.ImportDir)
+
+	log.Info("Create webhookDispatcher...")
+	webhookDispatcher := webhooks.NewDispatcher(log, store.Events, store.Webhooks, store.ShortURL, webhooks.Options{})
+
+	log.Info("Create clickRecorder...")
+	clickRecorder := clicks.NewRecorder(log, webhookDispatcher.NotifyClicks(store.Clicks), 10000, 500, time.Second)
+
 	r := handlers.NewRouter(
 		log,
 		cfg.BaseURL,
@@ -60,6 +82,10 @@ func NewApp(
 		store.ShortURL,
 		store,
 		fansShortService,
+		clickRecorder,
+		services.ClickStatsService,
+		services.WebhookService,
+		importer,
 		cfg.TrustedSubnet,
 	)
 
@@ -77,7 +103,7 @@ func NewApp(
 	pb.RegisterAuthServer(grcserver.Server, rpcservices.NewAuthServer(log, services.AuthService))
 	pb.RegisterShortenerServer(
 		grcserver.Server,
-		rpcservices.NewShortenerServer(log, services.ShorterService, fansShortService),
+		rpcservices.NewShortenerServer(log, services.ShorterService, services.ClickStatsService, fansShortService),
 	)
 
 	grcserver.Start()
@@ -106,6 +132,10 @@ func NewApp(
 	}
 
 	fansShortService.Close()
+	expiredSweeper.Close()
+	importer.Close()
+	clickRecorder.Close()
+	webhookDispatcher.Close()
 
 	if err := store.Close(); err != nil {
 		log.Error("error close connection to store", zap.Error(err))
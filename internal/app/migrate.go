@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/config"
+	"github.com/shreyner/go-shortener/internal/pkg/database"
+	storagedatabase "github.com/shreyner/go-shortener/internal/storage/storage_database"
+)
+
+// Migrate run command with database migrations separately from serving.
+//
+// Commands:
+//
+//	up           apply all new migrations
+//	down [steps] rollback last applied migrations, one by default
+//	status       print list of migrations
+func Migrate(log *zap.Logger, cfg *config.Config, out io.Writer, args []string) error {
+	if cfg.DataBaseDSN == "" {
+		return errors.New("database dsn is required for migrate")
+	}
+
+	if len(args) == 0 {
+		return errors.New("migrate command is required: up, down or status")
+	}
+
+	db, err := database.NewDataBase(log, cfg.DataBaseDSN)
+
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := storagedatabase.NewMigrator(log, db)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps for down: %s", args[1])
+			}
+		}
+
+		return migrator.Down(ctx, steps)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+
+		for _, status := range statuses {
+			appliedAt := "pending"
+
+			if status.AppliedAt.Valid {
+				appliedAt = status.AppliedAt.Time.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+}
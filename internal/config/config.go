@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/caarlos0/env/v6"
 )
@@ -25,10 +26,28 @@ type Config struct {
 	BaseURL         string `json:"base_url" env:"BASE_URL" envDefault:"http://localhost:8080"`
 	FileStoragePath string `json:"file_storage_path" env:"FILE_STORAGE_PATH"`
 	DataBaseDSN     string `json:"database_dsn" env:"DATABASE_DSN"`
+	ReplicaDSNs     string `json:"database_replica_dsns" env:"DATABASE_REPLICA_DSNS"`
 	Config          string `json:"-" env:"CONFIG"`
 	TrustedSubnet   string `json:"trusted_subnet"`
 	SignKey         string `json:"sign_key" env:"SIGN_KEY" envDefault:"triy6n9rw3"`
 	EnabledHTTPS    bool   `json:"enable_https" env:"ENABLE_HTTPS"`
+	IDGenerator     string `json:"id_generator" env:"ID_GENERATOR" envDefault:"random"`
+	IDLength        int    `json:"id_length" env:"ID_LENGTH" envDefault:"10"`
+	IDAlphabet      string `json:"id_alphabet" env:"ID_ALPHABET"`
+	ImportDir       string `json:"import_dir" env:"IMPORT_DIR"`
+}
+
+// ReplicaDSNList list of dsn of read replicas
+func (c *Config) ReplicaDSNList() []string {
+	var dsns []string
+
+	for _, dsn := range strings.Split(c.ReplicaDSNs, ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+
+	return dsns
 }
 
 // Parse will start parsing env variable and willed config
@@ -41,10 +60,15 @@ func (c *Config) Parse() error {
 	flag.StringVar(&c.ServerAddress, "a", c.ServerAddress, "Адрес сервера")
 	flag.StringVar(&c.BaseURL, "b", c.BaseURL, "Базовый адрес")
 	flag.StringVar(&c.FileStoragePath, "f", c.FileStoragePath, "Путь до папки с хранением данных")
-	flag.StringVar(&c.DataBaseDSN, "d", c.DataBaseDSN, "Конфиг подключения к db")
+	flag.StringVar(&c.DataBaseDSN, "d", c.DataBaseDSN, "Конфиг подключения к db, sqlite://path для встроенной SQLite, bolt://path для встроенного bbolt")
+	flag.StringVar(&c.ReplicaDSNs, "replicas", c.ReplicaDSNs, "Реплики postgres для чтения через запятую")
 	flag.BoolVar(&c.EnabledHTTPS, "s", c.EnabledHTTPS, "HTTPS соединение")
 	flag.StringVar(&c.TrustedSubnet, "t", c.TrustedSubnet, "CIDR для доступа к /internal")
 	flag.StringVar(&c.SignKey, "sign-key", c.SignKey, "signed cookie key")
+	flag.StringVar(&c.IDGenerator, "id-generator", c.IDGenerator, "Генератор коротких ссылок: random, counter, hash")
+	flag.IntVar(&c.IDLength, "id-length", c.IDLength, "Длина коротких ссылок")
+	flag.StringVar(&c.IDAlphabet, "id-alphabet", c.IDAlphabet, "Алфавит коротких ссылок, по умолчанию base62")
+	flag.StringVar(&c.ImportDir, "import-dir", c.ImportDir, "Папка для загруженных файлов импорта, по умолчанию временная папка системы")
 
 	flag.Parse()
 
@@ -82,6 +106,10 @@ func (c *Config) ParseConfigFile(name string) error {
 		c.DataBaseDSN = configJSON.DataBaseDSN
 	}
 
+	if c.ReplicaDSNs == "" && configJSON.ReplicaDSNs != "" {
+		c.ReplicaDSNs = configJSON.ReplicaDSNs
+	}
+
 	if c.ServerAddress == "" && configJSON.ServerAddress != "" {
 		c.ServerAddress = configJSON.ServerAddress
 	}
@@ -110,5 +138,21 @@ func (c *Config) ParseConfigFile(name string) error {
 		c.SignKey = configJSON.SignKey
 	}
 
+	if c.IDGenerator == "random" && configJSON.IDGenerator != "" {
+		c.IDGenerator = configJSON.IDGenerator
+	}
+
+	if c.IDLength == 10 && configJSON.IDLength != 0 {
+		c.IDLength = configJSON.IDLength
+	}
+
+	if c.IDAlphabet == "" && configJSON.IDAlphabet != "" {
+		c.IDAlphabet = configJSON.IDAlphabet
+	}
+
+	if c.ImportDir == "" && configJSON.ImportDir != "" {
+		c.ImportDir = configJSON.ImportDir
+	}
+
 	return nil
 }
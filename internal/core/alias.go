@@ -0,0 +1,59 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Limits of length custom alias
+const (
+	MinAliasLength = 3
+	MaxAliasLength = 64
+)
+
+// ErrInvalidAlias custom alias can't be used as ID of short url
+var ErrInvalidAlias = errors.New("invalid alias")
+
+// reservedAliases first segments of path which used by router
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"debug":   {},
+	"swagger": {},
+}
+
+// IsIDChar character which can be used in ID of short url without escaping in path: latin letters, digits, "-" and "_"
+func IsIDChar(r rune) bool {
+	isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	isDigit := r >= '0' && r <= '9'
+
+	return isLetter || isDigit || r == '-' || r == '_'
+}
+
+// IsReservedID ID is first segment of path used by router. Checked for aliases and generated IDs
+func IsReservedID(id string) bool {
+	_, ok := reservedAliases[strings.ToLower(id)]
+
+	return ok
+}
+
+// ValidateAlias check custom alias: latin letters, digits, "-" and "_", length from MinAliasLength to MaxAliasLength
+// and not reserved by router
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return fmt.Errorf("%w: length should be from %d to %d", ErrInvalidAlias, MinAliasLength, MaxAliasLength)
+	}
+
+	for _, r := range alias {
+		if !IsIDChar(r) {
+			return fmt.Errorf("%w: only latin letters, digits, \"-\" and \"_\" are allowed", ErrInvalidAlias)
+		}
+	}
+
+	if IsReservedID(alias) {
+		return fmt.Errorf("%w: %s is reserved", ErrInvalidAlias, alias)
+	}
+
+	return nil
+}
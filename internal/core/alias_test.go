@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		alias   string
+		wantErr bool
+	}{
+		{name: "should accept slug", alias: "spring-sale_2023"},
+		{name: "should reject short", alias: "ab", wantErr: true},
+		{name: "should reject long", alias: string(make([]byte, MaxAliasLength+1)), wantErr: true},
+		{name: "should reject slash", alias: "spring/sale", wantErr: true},
+		{name: "should reject plus", alias: "spring+", wantErr: true},
+		{name: "should reject not latin", alias: "распродажа", wantErr: true},
+		{name: "should reject reserved", alias: "api", wantErr: true},
+		{name: "should reject reserved in other case", alias: "Ping", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAlias(tt.alias)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAlias)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package core
+
+import "time"
+
+// Click event of redirect by short url
+type Click struct {
+	ShortURLID string    `json:"shortUrlId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Referrer   string    `json:"referrer,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+}
+
+// ClickStats aggregated clicks of short url in range of time
+type ClickStats struct {
+	Total          int
+	UniqueVisitors int
+	Days           []DayCount
+	TopReferrers   []ValueCount
+	TopUserAgents  []ValueCount
+}
+
+// ValueCount count of clicks with value of referrer or user agent
+type ValueCount struct {
+	Value string
+	Count int
+}
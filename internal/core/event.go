@@ -0,0 +1,40 @@
+package core
+
+import "time"
+
+// EventType type of lifecycle event of short url
+type EventType string
+
+// Types of lifecycle events
+const (
+	EventCreated EventType = "created"
+	EventDeleted EventType = "deleted" // deleted by owner
+	EventUpdated EventType = "updated" // target url was changed by owner
+	EventExpired EventType = "expired" // deleted by expiration
+	EventClicked EventType = "clicked" // redirect by short url, is not written in outbox
+)
+
+// EventsRetention count of last events which memory and file stores keep in feed, older events are dropped.
+// Retention does not depend on cursors of consumers, so slow consumer can miss events older than it
+const EventsRetention = 100_000
+
+// Event lifecycle event of short url in change feed. ID grows in order of commit of changes
+type Event struct {
+	ID         int64     `json:"id"`
+	Type       EventType `json:"type"`
+	ShortURLID string    `json:"shortUrlId"`
+	URL        string    `json:"url,omitempty"`
+	UserID     string    `json:"userId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// NewEvent create event of short url without ID, ID is assigned by store
+func NewEvent(eventType EventType, shortURL *ShortURL, now time.Time) *Event {
+	return &Event{
+		Type:       eventType,
+		ShortURLID: shortURL.ID,
+		URL:        shortURL.URL,
+		UserID:     shortURL.UserID.String,
+		CreatedAt:  now.UTC().Truncate(time.Microsecond),
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Errors of expiration params
+var (
+	ErrExpirationConflict = errors.New("ttl and expires_at can't be used together")
+	ErrInvalidTTL         = errors.New("ttl should be positive number of seconds")
+	ErrInvalidExpiresAt   = errors.New("expires_at should be RFC3339 time in future")
+)
+
+// CreateOptions optional params for create short url
+type CreateOptions struct {
+	ExpiresAt sql.NullTime
+	// Alias custom ID of short url instead generated
+	Alias string
+	RedirectOptions
+}
+
+// ParseExpiration return time of expiration by ttl in seconds or expires_at in RFC3339.
+// Both empty mean link without expiration
+func ParseExpiration(ttl int64, expiresAt string, now time.Time) (sql.NullTime, error) {
+	switch {
+	case ttl != 0 && expiresAt != "":
+		return sql.NullTime{}, ErrExpirationConflict
+	case ttl < 0:
+		return sql.NullTime{}, ErrInvalidTTL
+	case ttl > 0:
+		return sql.NullTime{Time: now.Add(time.Duration(ttl) * time.Second).UTC().Truncate(time.Microsecond), Valid: true}, nil
+	case expiresAt != "":
+		t, err := time.Parse(time.RFC3339, expiresAt)
+
+		if err != nil || !t.After(now) {
+			return sql.NullTime{}, ErrInvalidExpiresAt
+		}
+
+		return sql.NullTime{Time: t.UTC().Truncate(time.Microsecond), Valid: true}, nil
+	default:
+		return sql.NullTime{}, nil
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors of import
+var (
+	ErrImportNotFound      = errors.New("import not found")
+	ErrInvalidImportFormat = errors.New("import format should be csv or ndjson")
+	ErrImportTooLarge      = errors.New("import file is too large")
+	ErrTooManyImports      = errors.New("too many active imports")
+)
+
+// ImportFormat format of rows of imported file
+type ImportFormat string
+
+// Formats of import
+const (
+	ImportCSV    ImportFormat = "csv"
+	ImportNDJSON ImportFormat = "ndjson"
+)
+
+// ImportStatus status of import job
+type ImportStatus string
+
+// Statuses of import job
+const (
+	ImportPending ImportStatus = "pending"
+	ImportRunning ImportStatus = "running"
+	ImportDone    ImportStatus = "done"
+	ImportFailed  ImportStatus = "failed"
+)
+
+// ImportJob progress of import links of user from uploaded file
+type ImportJob struct {
+	ID     string
+	UserID string
+	Format ImportFormat
+	Status ImportStatus
+	// Error reason of failed job, for example broken gzip
+	Error string
+	// Size of uploaded file in bytes and Read how many bytes of it are processed
+	Size int64
+	Read int64
+	// Rows processed rows, each row is counted in one of Created, Conflicts, Invalid or Failed
+	Rows      int
+	Created   int
+	Conflicts int
+	Invalid   int
+	Failed    int
+	CreatedAt time.Time
+	// FinishedAt zero while job isn't done or failed
+	FinishedAt time.Time
+}
+
+// ImportRowStatus result of one row of import
+type ImportRowStatus string
+
+// Results of row
+const (
+	// ImportRowCreated new short url was created
+	ImportRowCreated ImportRowStatus = "created"
+	// ImportRowConflict url was already shortened or alias is taken
+	ImportRowConflict ImportRowStatus = "conflict"
+	// ImportRowInvalid row can't be parsed or has incorrect url, alias or expiration
+	ImportRowInvalid ImportRowStatus = "invalid"
+	// ImportRowFailed error of store
+	ImportRowFailed ImportRowStatus = "failed"
+)
+
+// ImportRowResult result of one row. Row is number of row with data starting from 1, csv header isn't counted.
+// ID is created short url or existing short url for conflict by url
+type ImportRowResult struct {
+	Row           int             `json:"row"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	URL           string          `json:"original_url,omitempty"`
+	ID            string          `json:"id,omitempty"`
+	Status        ImportRowStatus `json:"status"`
+	Error         string          `json:"error,omitempty"`
+}
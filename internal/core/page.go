@@ -0,0 +1,43 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortField field for order list of short urls
+type SortField int
+
+// Fields for order list of short urls
+const (
+	SortByID        SortField = iota // by short url ID
+	SortByCreatedAt                  // by time of create, equal times ordered by ID
+)
+
+// ListOptions params of page with short urls
+type ListOptions struct {
+	Limit  int
+	Cursor string
+	SortBy SortField
+	Desc   bool
+}
+
+// ShortURLPage page with short urls and opaque cursor to next page. Empty NextCursor mean last page
+type ShortURLPage struct {
+	ShortURLs  []*ShortURL
+	NextCursor string
+}
+
+// ParseSort parse sort param: id, -id, created_at, -created_at. Minus mean descending order
+func ParseSort(value string) (SortField, bool, error) {
+	desc := strings.HasPrefix(value, "-")
+
+	switch strings.TrimPrefix(value, "-") {
+	case "", "id":
+		return SortByID, desc, nil
+	case "created_at":
+		return SortByCreatedAt, desc, nil
+	default:
+		return SortByID, false, fmt.Errorf("unknown sort: %s", value)
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MaxCacheMaxAge limit of Cache-Control max-age of redirect, one year as in RFC 9111 recommendations
+const MaxCacheMaxAge = 365 * 24 * 60 * 60
+
+// Errors of redirect params
+var (
+	ErrInvalidRedirectType = errors.New("redirect_type should be 301, 302, 307 or 308")
+	ErrInvalidCacheMaxAge  = fmt.Errorf("cache_max_age should be from 0 to %d seconds", MaxCacheMaxAge)
+)
+
+// IsInvalidRedirect err is error of check of redirect params
+func IsInvalidRedirect(err error) bool {
+	return errors.Is(err, ErrInvalidRedirectType) || errors.Is(err, ErrInvalidCacheMaxAge)
+}
+
+// NewCacheMaxAge max-age of request in seconds, nil mean redirect without Cache-Control
+func NewCacheMaxAge(seconds *int64) sql.NullInt64 {
+	if seconds == nil {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: *seconds, Valid: true}
+}
+
+// RedirectOptions status code of redirect and caching of it. Zero RedirectType mean 307,
+// not valid CacheMaxAge mean redirect without Cache-Control
+type RedirectOptions struct {
+	RedirectType int
+	CacheMaxAge  sql.NullInt64
+}
+
+// Validate check redirect type and max-age
+func (o RedirectOptions) Validate() error {
+	switch o.RedirectType {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return ErrInvalidRedirectType
+	}
+
+	if o.CacheMaxAge.Valid && (o.CacheMaxAge.Int64 < 0 || o.CacheMaxAge.Int64 > MaxCacheMaxAge) {
+		return ErrInvalidCacheMaxAge
+	}
+
+	return nil
+}
+
+// RedirectStatus status code of redirect by short url
+func (s *ShortURL) RedirectStatus() int {
+	if s.RedirectType == 0 {
+		return http.StatusTemporaryRedirect
+	}
+
+	return s.RedirectType
+}
+
+// CacheControl value of Cache-Control header for redirect, empty without max-age.
+// Zero max-age forbid store of redirect, max-age of link with expiration isn't longer than time until expiration
+func (s *ShortURL) CacheControl(now time.Time) string {
+	if !s.CacheMaxAge.Valid {
+		return ""
+	}
+
+	maxAge := s.CacheMaxAge.Int64
+
+	if s.ExpiresAt.Valid {
+		if untilExpire := int64(s.ExpiresAt.Time.Sub(now) / time.Second); untilExpire < maxAge {
+			maxAge = untilExpire
+		}
+	}
+
+	if maxAge <= 0 {
+		return "no-store"
+	}
+
+	return fmt.Sprintf("public, max-age=%d", maxAge)
+}
+
+// RedirectUpdate changed fields of redirect options, nil field keeps current value.
+// Not valid CacheMaxAge removes max-age
+type RedirectUpdate struct {
+	RedirectType *int
+	CacheMaxAge  *sql.NullInt64
+}
+
+// IsEmpty update doesn't change any field
+func (u RedirectUpdate) IsEmpty() bool {
+	return u.RedirectType == nil && u.CacheMaxAge == nil
+}
+
+// Apply changed fields to opts
+func (u RedirectUpdate) Apply(opts RedirectOptions) RedirectOptions {
+	if u.RedirectType != nil {
+		opts.RedirectType = *u.RedirectType
+	}
+
+	if u.CacheMaxAge != nil {
+		opts.CacheMaxAge = *u.CacheMaxAge
+	}
+
+	return opts
+}
+
+// ApplyTo change redirect options of short url by changed fields
+func (u RedirectUpdate) ApplyTo(shortURL *ShortURL) {
+	opts := u.Apply(RedirectOptions{RedirectType: shortURL.RedirectType, CacheMaxAge: shortURL.CacheMaxAge})
+
+	shortURL.RedirectType = opts.RedirectType
+	shortURL.CacheMaxAge = opts.CacheMaxAge
+}
+
+// Validate check changed fields
+func (u RedirectUpdate) Validate() error {
+	return u.Apply(RedirectOptions{}).Validate()
+}
+
+// NewRedirectUpdate checked update of redirect options by request. Nil redirectType keeps redirect type,
+// nil cacheMaxAge keeps max-age, clearCacheMaxAge removes it
+func NewRedirectUpdate(redirectType *int, cacheMaxAge *int64, clearCacheMaxAge bool) (RedirectUpdate, error) {
+	update := RedirectUpdate{RedirectType: redirectType}
+
+	if cacheMaxAge != nil || clearCacheMaxAge {
+		value := NewCacheMaxAge(cacheMaxAge)
+		update.CacheMaxAge = &value
+	}
+
+	if err := update.Validate(); err != nil {
+		return RedirectUpdate{}, err
+	}
+
+	return update, nil
+}
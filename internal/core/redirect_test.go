@@ -0,0 +1,114 @@
+package core
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    RedirectOptions
+		wantErr error
+	}{
+		{name: "should accept default", opts: RedirectOptions{}},
+		{name: "should accept permanent with max-age", opts: RedirectOptions{RedirectType: http.StatusMovedPermanently, CacheMaxAge: sql.NullInt64{Int64: 3600, Valid: true}}},
+		{name: "should accept zero max-age", opts: RedirectOptions{RedirectType: http.StatusFound, CacheMaxAge: sql.NullInt64{Valid: true}}},
+		{name: "should reject other status", opts: RedirectOptions{RedirectType: http.StatusOK}, wantErr: ErrInvalidRedirectType},
+		{name: "should reject negative max-age", opts: RedirectOptions{CacheMaxAge: sql.NullInt64{Int64: -1, Valid: true}}, wantErr: ErrInvalidCacheMaxAge},
+		{name: "should reject too long max-age", opts: RedirectOptions{CacheMaxAge: sql.NullInt64{Int64: MaxCacheMaxAge + 1, Valid: true}}, wantErr: ErrInvalidCacheMaxAge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.opts.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestShortURL_CacheControl(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should return redirect status and cache control", func(t *testing.T) {
+		shortURL := &ShortURL{}
+
+		assert.Equal(t, http.StatusTemporaryRedirect, shortURL.RedirectStatus())
+		assert.Equal(t, "", shortURL.CacheControl(now))
+
+		shortURL.RedirectType = http.StatusPermanentRedirect
+		shortURL.CacheMaxAge = sql.NullInt64{Int64: 3600, Valid: true}
+
+		assert.Equal(t, http.StatusPermanentRedirect, shortURL.RedirectStatus())
+		assert.Equal(t, "public, max-age=3600", shortURL.CacheControl(now))
+
+		shortURL.CacheMaxAge = sql.NullInt64{Valid: true}
+		assert.Equal(t, "no-store", shortURL.CacheControl(now))
+	})
+
+	t.Run("should limit max-age by expiration", func(t *testing.T) {
+		shortURL := &ShortURL{
+			CacheMaxAge: sql.NullInt64{Int64: 3600, Valid: true},
+			ExpiresAt:   sql.NullTime{Time: now.Add(10 * time.Minute), Valid: true},
+		}
+
+		assert.Equal(t, "public, max-age=600", shortURL.CacheControl(now))
+	})
+}
+
+func TestRedirectUpdate_Apply(t *testing.T) {
+	t.Run("should change only set fields", func(t *testing.T) {
+		opts := RedirectOptions{RedirectType: http.StatusMovedPermanently, CacheMaxAge: sql.NullInt64{Int64: 60, Valid: true}}
+		redirectType := http.StatusFound
+
+		assert.True(t, RedirectUpdate{}.IsEmpty())
+		assert.Equal(t, opts, RedirectUpdate{}.Apply(opts))
+		assert.Equal(t,
+			RedirectOptions{RedirectType: http.StatusFound, CacheMaxAge: opts.CacheMaxAge},
+			RedirectUpdate{RedirectType: &redirectType}.Apply(opts),
+		)
+		assert.Equal(t,
+			RedirectOptions{RedirectType: http.StatusMovedPermanently},
+			RedirectUpdate{CacheMaxAge: &sql.NullInt64{}}.Apply(opts),
+		)
+	})
+}
+
+func TestNewRedirectUpdate(t *testing.T) {
+	t.Run("should set only passed fields", func(t *testing.T) {
+		redirectType := http.StatusFound
+		maxAge := int64(60)
+
+		update, err := NewRedirectUpdate(&redirectType, nil, false)
+		assert.NoError(t, err)
+		assert.Equal(t, RedirectUpdate{RedirectType: &redirectType}, update)
+
+		update, err = NewRedirectUpdate(nil, &maxAge, false)
+		assert.NoError(t, err)
+		assert.Equal(t, RedirectUpdate{CacheMaxAge: &sql.NullInt64{Int64: 60, Valid: true}}, update)
+
+		update, err = NewRedirectUpdate(nil, nil, true)
+		assert.NoError(t, err)
+		assert.Equal(t, RedirectUpdate{CacheMaxAge: &sql.NullInt64{}}, update)
+
+		update, err = NewRedirectUpdate(nil, nil, false)
+		assert.NoError(t, err)
+		assert.True(t, update.IsEmpty())
+	})
+
+	t.Run("should error for invalid fields", func(t *testing.T) {
+		redirectType := http.StatusOK
+		maxAge := int64(-1)
+
+		_, err := NewRedirectUpdate(&redirectType, nil, false)
+		assert.ErrorIs(t, err, ErrInvalidRedirectType)
+		assert.True(t, IsInvalidRedirect(err))
+
+		_, err = NewRedirectUpdate(nil, &maxAge, false)
+		assert.ErrorIs(t, err, ErrInvalidCacheMaxAge)
+		assert.True(t, IsInvalidRedirect(err))
+	})
+}
@@ -1,18 +1,49 @@
 package core
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
-// ShortURL models for short urls
+// ShortURL models for short urls. RedirectType and CacheMaxAge are same as in RedirectOptions
 type ShortURL struct {
 	ID            string         `json:"id"`
 	URL           string         `json:"url"`
 	CorrelationID string         `json:"correlation_id,omitempty"`
 	UserID        sql.NullString `json:"userId,omitempty"`
 	IsDeleted     bool           `json:"isDeleted"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	ExpiresAt     sql.NullTime   `json:"expiresAt"`
+	RedirectType  int            `json:"redirectType,omitempty"`
+	CacheMaxAge   sql.NullInt64  `json:"cacheMaxAge"`
+}
+
+// IsExpired ссылка с истекшим сроком жизни
+func (s *ShortURL) IsExpired(now time.Time) bool {
+	return s.ExpiresAt.Valid && !now.Before(s.ExpiresAt.Time)
+}
+
+// IsActive ссылка не удалена и не истекла. Только активная ссылка занимает свой url,
+// url удаленной или истекшей ссылки можно сократить заново
+func (s *ShortURL) IsActive(now time.Time) bool {
+	return !s.IsDeleted && !s.IsExpired(now)
 }
 
 // ShortStats models with stats service
 type ShortStats struct {
-	URLs  int `json:"urls"`
-	Users int `json:"users"`
+	URLs        int
+	Users       int
+	DeletedURLs int
+	// ActiveUsers users which created urls in range of stats
+	ActiveUsers int
+	// CreatedPerDay urls created by days of range of stats, days without urls have zero
+	CreatedPerDay []DayCount
+	// StorageSize size of store in bytes, for memory store is approximate size of data
+	StorageSize int64
+}
+
+// DayCount count in day. Day is begin of day in UTC
+type DayCount struct {
+	Day   time.Time
+	Count int
 }
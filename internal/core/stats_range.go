@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+// Limits of range of click stats in days
+const (
+	DefaultStatsDays = 30
+	MaxStatsDays     = 366
+)
+
+// StatsDayLayout format of days in range of click stats
+const StatsDayLayout = "2006-01-02"
+
+// Errors of request of click stats
+var (
+	ErrInvalidStatsRange = errors.New("from and to should be days YYYY-MM-DD, from not after to, no more 366 days")
+	ErrShortURLNotFound  = errors.New("short url not found")
+	ErrNotOwner          = errors.New("short url was created by other user")
+)
+
+// StatsRange range of click stats. From inclusive, To exclusive, both are begin of day in UTC
+type StatsRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Days count of days in range
+func (r StatsRange) Days() int {
+	return int(r.To.Sub(r.From) / (24 * time.Hour))
+}
+
+// ParseStatsRange return range by days from and to inclusive. Empty to mean today,
+// empty from mean DefaultStatsDays days before to
+func ParseStatsRange(from, to string, now time.Time) (StatsRange, error) {
+	toDay := now.UTC().Truncate(24 * time.Hour)
+
+	if to != "" {
+		t, err := time.Parse(StatsDayLayout, to)
+
+		if err != nil {
+			return StatsRange{}, ErrInvalidStatsRange
+		}
+
+		toDay = t
+	}
+
+	fromDay := toDay.AddDate(0, 0, 1-DefaultStatsDays)
+
+	if from != "" {
+		t, err := time.Parse(StatsDayLayout, from)
+
+		if err != nil {
+			return StatsRange{}, ErrInvalidStatsRange
+		}
+
+		fromDay = t
+	}
+
+	statsRange := StatsRange{From: fromDay, To: toDay.AddDate(0, 0, 1)}
+
+	if days := statsRange.Days(); days < 1 || days > MaxStatsDays {
+		return StatsRange{}, ErrInvalidStatsRange
+	}
+
+	return statsRange, nil
+}
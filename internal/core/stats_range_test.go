@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatsRange(t *testing.T) {
+	now := time.Date(2022, 10, 15, 13, 30, 0, 0, time.UTC)
+
+	t.Run("should return last 30 days by default", func(t *testing.T) {
+		got, err := ParseStatsRange("", "", now)
+		require.NoError(t, err)
+
+		assert.Equal(t, time.Date(2022, 9, 16, 0, 0, 0, 0, time.UTC), got.From)
+		assert.Equal(t, time.Date(2022, 10, 16, 0, 0, 0, 0, time.UTC), got.To)
+		assert.Equal(t, DefaultStatsDays, got.Days())
+	})
+
+	t.Run("should include both days", func(t *testing.T) {
+		got, err := ParseStatsRange("2022-10-01", "2022-10-01", now)
+		require.NoError(t, err)
+
+		assert.Equal(t, time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC), got.From)
+		assert.Equal(t, time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC), got.To)
+		assert.Equal(t, 1, got.Days())
+	})
+
+	t.Run("should error for incorrect range", func(t *testing.T) {
+		tests := [][2]string{
+			{"2022-10-01T00:00:00Z", ""},
+			{"", "01.10.2022"},
+			{"2022-10-02", "2022-10-01"},
+			{"2020-01-01", "2022-10-01"},
+		}
+
+		for _, tt := range tests {
+			_, err := ParseStatsRange(tt[0], tt[1], now)
+
+			assert.ErrorIs(t, err, ErrInvalidStatsRange, tt)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrShortURLDeleted short url was deleted by owner or by expiration and can't be changed
+var ErrShortURLDeleted = errors.New("short url was deleted")
+
+// URLChange change of target url of short url by owner. ID grows in order of changes
+type URLChange struct {
+	ID         int64     `json:"id"`
+	ShortURLID string    `json:"shortUrlId"`
+	UserID     string    `json:"userId"`
+	OldURL     string    `json:"oldUrl"`
+	NewURL     string    `json:"newUrl"`
+	ChangedAt  time.Time `json:"changedAt"`
+}
+
+// ErrURLVersionNotFound version isn't in history of short url
+var ErrURLVersionNotFound = errors.New("version of url not found")
+
+// URLVersion target url of short url from CreatedAt until next version. Version 1 is url at create of short url
+type URLVersion struct {
+	Version   int
+	URL       string
+	UserID    string
+	CreatedAt time.Time
+}
+
+// NewURLVersions history of target url by changes ordered by ID, last version is current url
+func NewURLVersions(shortURL *ShortURL, changes []*URLChange) []*URLVersion {
+	versions := make([]*URLVersion, 0, len(changes)+1)
+
+	first := &URLVersion{Version: 1, URL: shortURL.URL, UserID: shortURL.UserID.String, CreatedAt: shortURL.CreatedAt}
+
+	if len(changes) > 0 {
+		first.URL = changes[0].OldURL
+	}
+
+	versions = append(versions, first)
+
+	for i, change := range changes {
+		versions = append(versions, &URLVersion{
+			Version:   i + 2,
+			URL:       change.NewURL,
+			UserID:    change.UserID,
+			CreatedAt: change.ChangedAt,
+		})
+	}
+
+	return versions
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewURLVersions(t *testing.T) {
+	createdAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	shortURL := &ShortURL{ID: "abc", URL: "https://ya.ru/3", UserID: sql.NullString{String: "1", Valid: true}, CreatedAt: createdAt}
+
+	t.Run("should return only url at create without changes", func(t *testing.T) {
+		versions := NewURLVersions(&ShortURL{ID: "abc", URL: "https://ya.ru", CreatedAt: createdAt}, nil)
+
+		require.Equal(t, 1, len(versions))
+		assert.Equal(t, &URLVersion{Version: 1, URL: "https://ya.ru", CreatedAt: createdAt}, versions[0])
+	})
+
+	t.Run("should build versions by changes", func(t *testing.T) {
+		changes := []*URLChange{
+			{ID: 1, ShortURLID: "abc", UserID: "1", OldURL: "https://ya.ru/1", NewURL: "https://ya.ru/2", ChangedAt: createdAt.Add(time.Hour)},
+			{ID: 5, ShortURLID: "abc", UserID: "1", OldURL: "https://ya.ru/2", NewURL: "https://ya.ru/3", ChangedAt: createdAt.Add(2 * time.Hour)},
+		}
+
+		versions := NewURLVersions(shortURL, changes)
+
+		require.Equal(t, 3, len(versions))
+		assert.Equal(t, &URLVersion{Version: 1, URL: "https://ya.ru/1", UserID: "1", CreatedAt: createdAt}, versions[0])
+		assert.Equal(t, &URLVersion{Version: 2, URL: "https://ya.ru/2", UserID: "1", CreatedAt: createdAt.Add(time.Hour)}, versions[1])
+		assert.Equal(t, 3, versions[2].Version)
+		assert.Equal(t, shortURL.URL, versions[2].URL)
+	})
+}
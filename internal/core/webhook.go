@@ -0,0 +1,104 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Errors of webhooks
+var (
+	ErrInvalidWebhook  = errors.New("webhook url should be absolute http or https url of public host, events one of created, deleted, expired, clicked")
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrTooManyWebhooks = errors.New("too many webhooks")
+)
+
+// WebhookEventTypes types of events available for subscription
+var WebhookEventTypes = []EventType{EventCreated, EventDeleted, EventExpired, EventClicked}
+
+// Webhook subscription of user on events of own short urls
+type Webhook struct {
+	ID        string      `json:"id"`
+	UserID    string      `json:"userId"`
+	URL       string      `json:"url"`
+	Secret    string      `json:"secret"` // key of HMAC signature of body
+	Events    []EventType `json:"events"`
+	Failures  int         `json:"failures,omitempty"` // count of failed deliveries in a row
+	Disabled  bool        `json:"disabled,omitempty"` // disabled after too many failed deliveries
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// Subscribed webhook is enabled and wait events of type
+func (w *Webhook) Subscribed(eventType EventType) bool {
+	if w.Disabled {
+		return false
+	}
+
+	for _, v := range w.Events {
+		if v == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseWebhook check url of receiver and types of events. Empty events mean all types.
+// Host which is localhost or not public IP is rejected, resolved addresses are checked at delivery
+func ParseWebhook(rawURL string, events []string) (string, []EventType, error) {
+	u, err := url.Parse(rawURL)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", nil, ErrInvalidWebhook
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "", nil, ErrInvalidWebhook
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !IsPublicIP(ip) {
+		return "", nil, ErrInvalidWebhook
+	}
+
+	if len(events) == 0 {
+		return u.String(), append([]EventType(nil), WebhookEventTypes...), nil
+	}
+
+	result := make([]EventType, 0, len(events))
+	seen := map[EventType]bool{}
+
+	for _, event := range events {
+		eventType := EventType(event)
+
+		if !isWebhookEventType(eventType) {
+			return "", nil, ErrInvalidWebhook
+		}
+
+		if !seen[eventType] {
+			seen[eventType] = true
+			result = append(result, eventType)
+		}
+	}
+
+	return u.String(), result, nil
+}
+
+// IsPublicIP address is not loopback, private, link-local, multicast or unspecified,
+// so request to it can't reach internal services
+func IsPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+func isWebhookEventType(eventType EventType) bool {
+	for _, v := range WebhookEventTypes {
+		if v == eventType {
+			return true
+		}
+	}
+
+	return false
+}
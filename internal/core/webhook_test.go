@@ -0,0 +1,69 @@
+package core
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWebhook(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		events     []string
+		wantEvents []EventType
+		wantErr    bool
+	}{
+		{name: "should subscribe on all events by default", url: "https://example.com/hook", wantEvents: WebhookEventTypes},
+		{name: "should keep order and drop duplicates", url: "http://example.com", events: []string{"clicked", "created", "clicked"}, wantEvents: []EventType{EventClicked, EventCreated}},
+		{name: "should reject unknown event", url: "https://example.com", events: []string{"updated"}, wantErr: true},
+		{name: "should reject relative url", url: "/hook", wantErr: true},
+		{name: "should reject other scheme", url: "ftp://example.com", wantErr: true},
+		{name: "should reject loopback", url: "http://127.0.0.1:8080/hook", wantErr: true},
+		{name: "should reject localhost", url: "http://localhost/hook", wantErr: true},
+		{name: "should reject metadata address", url: "http://169.254.169.254/latest", wantErr: true},
+		{name: "should reject private network", url: "https://10.1.2.3/hook", wantErr: true},
+		{name: "should reject ipv6 loopback", url: "http://[::1]/hook", wantErr: true},
+		{name: "should accept public ip", url: "https://8.8.8.8/hook", wantEvents: WebhookEventTypes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, events, err := ParseWebhook(tt.url, tt.events)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidWebhook)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEvents, events)
+		})
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	t.Run("should reject internal addresses", func(t *testing.T) {
+		for _, ip := range []string{"127.0.0.1", "10.0.0.1", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+			assert.False(t, IsPublicIP(net.ParseIP(ip)), ip)
+		}
+
+		assert.True(t, IsPublicIP(net.ParseIP("8.8.8.8")))
+		assert.True(t, IsPublicIP(net.ParseIP("2a00:1450:4010::64")))
+	})
+}
+
+func TestWebhook_Subscribed(t *testing.T) {
+	t.Run("should not wait events when disabled", func(t *testing.T) {
+		webhook := &Webhook{Events: []EventType{EventCreated}}
+
+		assert.True(t, webhook.Subscribed(EventCreated))
+		assert.False(t, webhook.Subscribed(EventClicked))
+
+		webhook.Disabled = true
+
+		assert.False(t, webhook.Subscribed(EventCreated))
+	})
+}
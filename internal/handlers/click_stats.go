@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/middlewares"
+)
+
+type clickStatsService interface {
+	GetStats(ctx context.Context, userID, id string, statsRange core.StatsRange) (*core.ClickStats, error)
+}
+
+// ClickStatsHandler handlers of click stats by short url for owner
+type ClickStatsHandler struct {
+	log     *zap.Logger
+	service clickStatsService
+}
+
+// NewClickStatsHandler create instance
+func NewClickStatsHandler(log *zap.Logger, service clickStatsService) *ClickStatsHandler {
+	return &ClickStatsHandler{
+		log:     log,
+		service: service,
+	}
+}
+
+// ClickStatsDayDTO clicks in day
+type ClickStatsDayDTO struct {
+	Day    string `json:"day" example:"2022-10-01"`
+	Clicks int    `json:"clicks" example:"12"`
+}
+
+// ClickStatsValueDTO count of clicks with referrer or user agent
+type ClickStatsValueDTO struct {
+	Value string `json:"value" example:"https://ya.ru"`
+	Count int    `json:"count" example:"5"`
+}
+
+// ClickStatsResponseDTO data transfer object for response
+type ClickStatsResponseDTO struct {
+	ID             string               `json:"id" example:"Jndshf"`
+	From           string               `json:"from" example:"2022-09-02"`
+	To             string               `json:"to" example:"2022-10-01"`
+	Total          int                  `json:"total" example:"42"`
+	UniqueVisitors int                  `json:"unique_visitors" example:"17"`
+	Days           []ClickStatsDayDTO   `json:"days"`
+	TopReferrers   []ClickStatsValueDTO `json:"top_referrers"`
+	TopUserAgents  []ClickStatsValueDTO `json:"top_user_agents"`
+}
+
+// Get статистика переходов по короткой ссылке для владельца
+//
+//	@summary Статистика переходов по короткой ссылке
+//	@tags    apiShorten
+//	@produce json
+//	@param   id   path     string true  "Идентификатор короткой ссылки"
+//	@param   from query    string false "Первый день YYYY-MM-DD, по умолчанию 30 дней до to"
+//	@param   to   query    string false "Последний день YYYY-MM-DD, по умолчанию сегодня"
+//	@success 200  {object} ClickStatsResponseDTO
+//	@failure 400  {string} string message
+//	@failure 403  {string} string message
+//	@failure 404  {string} string message
+//	@failure 500  {string} string message
+//	@router  /api/user/urls/{id}/stats [get]
+func (h *ClickStatsHandler) Get(wr http.ResponseWriter, r *http.Request) {
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+	id := chi.URLParam(r, "id")
+
+	query := r.URL.Query()
+	statsRange, err := core.ParseStatsRange(query.Get("from"), query.Get("to"), time.Now())
+
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.service.GetStats(r.Context(), userID, id, statsRange)
+
+	if errors.Is(err, core.ErrShortURLNotFound) {
+		http.Error(wr, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, core.ErrNotOwner) {
+		http.Error(wr, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if err != nil {
+		h.log.Error("get click stats error", zap.String("id", id), zap.Error(err))
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	responseDTO := ClickStatsResponseDTO{
+		ID:             id,
+		From:           statsRange.From.Format(core.StatsDayLayout),
+		To:             statsRange.To.AddDate(0, 0, -1).Format(core.StatsDayLayout),
+		Total:          stats.Total,
+		UniqueVisitors: stats.UniqueVisitors,
+		Days:           make([]ClickStatsDayDTO, len(stats.Days)),
+		TopReferrers:   newClickStatsValueDTOs(stats.TopReferrers),
+		TopUserAgents:  newClickStatsValueDTOs(stats.TopUserAgents),
+	}
+
+	for i, day := range stats.Days {
+		responseDTO.Days[i] = ClickStatsDayDTO{Day: day.Day.Format(core.StatsDayLayout), Clicks: day.Count}
+	}
+
+	body, err := json.Marshal(responseDTO)
+
+	if err != nil {
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Add("Content-Type", "application/json")
+	wr.Write(body)
+}
+
+func newClickStatsValueDTOs(values []core.ValueCount) []ClickStatsValueDTO {
+	result := make([]ClickStatsValueDTO, len(values))
+
+	for i, value := range values {
+		result[i] = ClickStatsValueDTO{Value: value.Value, Count: value.Count}
+	}
+
+	return result
+}
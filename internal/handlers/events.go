@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+type eventRepository interface {
+	EventsAfter(ctx context.Context, after int64, limit int) ([]*core.Event, error)
+}
+
+// EventsHandler change feed of lifecycle events of short urls
+type EventsHandler struct {
+	log        *zap.Logger
+	repository eventRepository
+}
+
+// NewEventsHandler create struct EventsHandler
+func NewEventsHandler(log *zap.Logger, repository eventRepository) *EventsHandler {
+	return &EventsHandler{
+		log:        log,
+		repository: repository,
+	}
+}
+
+// EventDTO lifecycle event of short url
+type EventDTO struct {
+	ID         string `json:"id" example:"42"`
+	Type       string `json:"type" example:"created"`
+	ShortURLID string `json:"short_url_id" example:"Ds3kd9"`
+	URL        string `json:"url" example:"https://ya.ru"`
+	UserID     string `json:"user_id,omitempty" example:"d1a9c8e2"`
+	CreatedAt  string `json:"created_at" example:"2022-10-01T12:00:00Z"`
+}
+
+// EventsResponseDTO page of change feed. NextCursor is passed as after for next page
+type EventsResponseDTO struct {
+	Events     []EventDTO `json:"events"`
+	NextCursor string     `json:"next_cursor" example:"42"`
+}
+
+// List handler of change feed. Events are ordered by ID, event of change committed later never get ID less than cursor,
+// so consumer can tail feed by next_cursor and never miss event
+//
+//	@summary Лента событий коротких ссылок
+//	@tags    internal
+//	@produce json
+//	@param   after query    string false "Курсор, ID последнего прочитанного события. По умолчанию с начала"
+//	@param   limit query    int    false "Количество событий, по умолчанию 100, максимум 1000"
+//	@success 200   {object} EventsResponseDTO
+//	@failure 400   {string} string message
+//	@failure 403   {string} string message
+//	@failure 500   {string} string message
+//	@router  /api/internal/events [get]
+func (h *EventsHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	after := int64(0)
+
+	if rawAfter := query.Get("after"); rawAfter != "" {
+		var err error
+		after, err = strconv.ParseInt(rawAfter, 10, 64)
+
+		if err != nil || after < 0 {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	limit := defaultEventsLimit
+
+	if rawLimit := query.Get("limit"); rawLimit != "" {
+		var err error
+		limit, err = strconv.Atoi(rawLimit)
+
+		if err != nil || limit <= 0 || limit > maxEventsLimit {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	events, err := h.repository.EventsAfter(ctx, after, limit)
+
+	if err != nil {
+		h.log.Error("get events error", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	responseDTO := EventsResponseDTO{
+		Events:     make([]EventDTO, len(events)),
+		NextCursor: strconv.FormatInt(after, 10),
+	}
+
+	for index, event := range events {
+		responseDTO.Events[index] = EventDTO{
+			ID:         strconv.FormatInt(event.ID, 10),
+			Type:       string(event.Type),
+			ShortURLID: event.ShortURLID,
+			URL:        event.URL,
+			UserID:     event.UserID,
+			CreatedAt:  event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		}
+	}
+
+	if len(events) > 0 {
+		responseDTO.NextCursor = responseDTO.Events[len(events)-1].ID
+	}
+
+	body, err := json.Marshal(responseDTO)
+
+	if err != nil {
+		h.log.Error("json marshal events error", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
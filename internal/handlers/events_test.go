@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// EventsMockRepository mock of change feed
+type EventsMockRepository struct {
+	mock.Mock
+}
+
+func (m *EventsMockRepository) EventsAfter(_ context.Context, after int64, limit int) ([]*core.Event, error) {
+	args := m.Called(after, limit)
+
+	events, _ := args.Get(0).([]*core.Event)
+
+	return events, args.Error(1)
+}
+
+func TestEventsHandler_List(t *testing.T) {
+	createdAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should return events and cursor of last event", func(t *testing.T) {
+		repository := new(EventsMockRepository)
+		handler := NewEventsHandler(zap.NewNop(), repository)
+
+		repository.On("EventsAfter", int64(41), 2).Return([]*core.Event{
+			{ID: 42, Type: core.EventCreated, ShortURLID: "abc", URL: "https://ya.ru", UserID: "user1", CreatedAt: createdAt},
+			{ID: 44, Type: core.EventExpired, ShortURLID: "def", URL: "https://vk.com", CreatedAt: createdAt},
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/internal/events?after=41&limit=2", nil)
+		wr := httptest.NewRecorder()
+
+		handler.List(wr, req)
+
+		repository.AssertExpectations(t)
+		require.Equal(t, http.StatusOK, wr.Code)
+		assert.JSONEq(t, `{
+			"events": [
+				{"id": "42", "type": "created", "short_url_id": "abc", "url": "https://ya.ru", "user_id": "user1", "created_at": "2022-10-01T12:00:00Z"},
+				{"id": "44", "type": "expired", "short_url_id": "def", "url": "https://vk.com", "created_at": "2022-10-01T12:00:00Z"}
+			],
+			"next_cursor": "44"
+		}`, wr.Body.String())
+	})
+
+	t.Run("should keep cursor when no new events", func(t *testing.T) {
+		repository := new(EventsMockRepository)
+		handler := NewEventsHandler(zap.NewNop(), repository)
+
+		repository.On("EventsAfter", int64(0), defaultEventsLimit).Return(nil, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/internal/events", nil)
+		wr := httptest.NewRecorder()
+
+		handler.List(wr, req)
+
+		repository.AssertExpectations(t)
+		require.Equal(t, http.StatusOK, wr.Code)
+		assert.JSONEq(t, `{"events": [], "next_cursor": "0"}`, wr.Body.String())
+	})
+
+	t.Run("should error for incorrect cursor or limit", func(t *testing.T) {
+		for _, query := range []string{"after=abc", "after=-1", "limit=0", "limit=1001"} {
+			repository := new(EventsMockRepository)
+			handler := NewEventsHandler(zap.NewNop(), repository)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/events?"+query, nil)
+			wr := httptest.NewRecorder()
+
+			handler.List(wr, req)
+
+			repository.AssertNotCalled(t, "EventsAfter")
+			assert.Equal(t, http.StatusBadRequest, wr.Code, query)
+		}
+	})
+
+	t.Run("should forbid access outside trusted subnet", func(t *testing.T) {
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", nil, nil, nil, nil, nil, nil, nil, nil, nil, "10.0.0.0/24")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/internal/events", nil)
+		req.Header.Set("X-Forwarded-For", "192.168.1.1")
+		wr := httptest.NewRecorder()
+
+		r.ServeHTTP(wr, req)
+
+		assert.Equal(t, http.StatusForbidden, wr.Code)
+	})
+}
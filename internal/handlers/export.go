@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/timewasted/go-accept-headers"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/middlewares"
+)
+
+const contentTypeCSV = "text/csv"
+
+// exportFormats formats of export by query param format
+var exportFormats = map[string]string{
+	"json":   contentTypeJSON,
+	"ndjson": contentTypeNDJSON,
+	"csv":    contentTypeCSV,
+}
+
+// ShortedExportDTO short url of user in export
+type ShortedExportDTO struct {
+	OriginalURL string `json:"original_url" example:"https://ya.ru"`
+	ShortURL    string `json:"short_url" example:"http://localhost:8080/Sjfnwf"`
+	IsDeleted   bool   `json:"is_deleted" example:"false"`
+	CreatedAt   string `json:"created_at" example:"2022-10-01T12:00:00Z"`
+}
+
+// exportWriter write rows of export in format
+type exportWriter interface {
+	begin() error
+	row(dto ShortedExportDTO) error
+	end() error
+}
+
+// APIUserURLsExport выгрузка всех ссылок пользователя, включая удаленные.
+// Ссылки читаются из хранилища страницами и сразу пишутся в ответ
+//
+//	@summary Выгрузка ссылок пользователя
+//	@tags    apiShorten
+//	@produce json,application/x-ndjson,text/csv
+//	@param   format query    string false "json, ndjson или csv, по умолчанию по Accept"
+//	@success 200    {array}  ShortedExportDTO
+//	@failure 400    {string} string message
+//	@failure 406    {string} string message
+//	@failure 500    {string} string message
+//	@router  /api/user/urls/export [get]
+func (sh *ShortedHandler) APIUserURLsExport(wr http.ResponseWriter, r *http.Request) {
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+
+	contentType, ok := exportFormats[r.URL.Query().Get("format")]
+
+	if r.URL.Query().Has("format") && !ok {
+		http.Error(wr, "format should be json, ndjson or csv", http.StatusBadRequest)
+		return
+	}
+
+	if acceptHeader := r.Header.Get("Accept"); !ok && acceptHeader != "" {
+		var err error
+
+		contentType, err = accept.Negotiate(acceptHeader, contentTypeJSON, contentTypeNDJSON, contentTypeCSV)
+
+		if err != nil {
+			http.Error(wr, "bad headers", http.StatusBadRequest)
+			return
+		}
+
+		if contentType == "" {
+			http.Error(wr, "bad accepting content", http.StatusNotAcceptable)
+			return
+		}
+	}
+
+	if contentType == "" {
+		contentType = contentTypeJSON
+	}
+
+	ew := newExportWriter(contentType, wr)
+	started := false
+
+	start := func() error {
+		if started {
+			return nil
+		}
+
+		started = true
+
+		wr.Header().Add("Content-Type", contentType)
+		wr.Header().Add("Content-Disposition", fmt.Sprintf(`attachment; filename="urls.%s"`, exportExtension(contentType)))
+		wr.WriteHeader(http.StatusOK)
+
+		return ew.begin()
+	}
+
+	err := sh.ShorterService.EachByUser(r.Context(), userID, func(shortURL *core.ShortURL) error {
+		if err := start(); err != nil {
+			return err
+		}
+
+		return ew.row(ShortedExportDTO{
+			OriginalURL: shortURL.URL,
+			ShortURL:    fmt.Sprintf("%s/%s", sh.baseURL, shortURL.ID),
+			IsDeleted:   shortURL.IsDeleted,
+			CreatedAt:   shortURL.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	})
+
+	if err == nil {
+		if err = start(); err == nil {
+			err = ew.end()
+		}
+	}
+
+	if err == nil {
+		return
+	}
+
+	sh.log.Error("export user urls error", zap.String("userID", userID), zap.Error(err))
+
+	// После начала ответа статус уже не поменять, клиент получит оборванный файл
+	if !started {
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+	}
+}
+
+func exportExtension(contentType string) string {
+	for format, v := range exportFormats {
+		if v == contentType {
+			return format
+		}
+	}
+
+	return "json"
+}
+
+func newExportWriter(contentType string, w io.Writer) exportWriter {
+	switch contentType {
+	case contentTypeCSV:
+		return &csvExportWriter{w: csv.NewWriter(w)}
+	case contentTypeNDJSON:
+		return &ndjsonExportWriter{enc: json.NewEncoder(w)}
+	default:
+		return &jsonExportWriter{w: w}
+	}
+}
+
+// jsonExportWriter JSON array, rows are written one by one
+type jsonExportWriter struct {
+	w    io.Writer
+	rows int
+}
+
+func (e *jsonExportWriter) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonExportWriter) row(dto ShortedExportDTO) error {
+	if e.rows > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+
+	e.rows++
+
+	body, err := json.Marshal(dto)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(body)
+
+	return err
+}
+
+func (e *jsonExportWriter) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+type ndjsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExportWriter) begin() error {
+	return nil
+}
+
+func (e *ndjsonExportWriter) row(dto ShortedExportDTO) error {
+	return e.enc.Encode(dto)
+}
+
+func (e *ndjsonExportWriter) end() error {
+	return nil
+}
+
+// csvExportWriter CSV with header, columns are named as json fields
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) begin() error {
+	return e.w.Write([]string{"original_url", "short_url", "is_deleted", "created_at"})
+}
+
+func (e *csvExportWriter) row(dto ShortedExportDTO) error {
+	return e.w.Write([]string{dto.OriginalURL, dto.ShortURL, strconv.FormatBool(dto.IsDeleted), dto.CreatedAt})
+}
+
+func (e *csvExportWriter) end() error {
+	e.w.Flush()
+
+	return e.w.Error()
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+func TestShortedHandler_APIUserURLsExport(t *testing.T) {
+	createdAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	shortURLs := []*core.ShortURL{
+		{ID: "abc", URL: "https://ya.ru", UserID: sql.NullString{String: "123", Valid: true}, CreatedAt: createdAt},
+		{ID: "def", URL: "https://vk.com/?a=1,2", UserID: sql.NullString{String: "123", Valid: true}, IsDeleted: true, CreatedAt: createdAt},
+	}
+
+	newServer := func(mockService *MyMockService) *httptest.Server {
+		authMockService := new(AuthMockService)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should export in format by accept or query", func(t *testing.T) {
+		for _, c := range []struct {
+			path, accept, contentType, body string
+		}{
+			{
+				path:        "/api/user/urls/export",
+				contentType: contentTypeJSON,
+				body: `[{"original_url":"https://ya.ru","short_url":"http://localhost:8080/abc","is_deleted":false,"created_at":"2022-10-01T12:00:00Z"},` +
+					`{"original_url":"https://vk.com/?a=1,2","short_url":"http://localhost:8080/def","is_deleted":true,"created_at":"2022-10-01T12:00:00Z"}]` + "\n",
+			},
+			{
+				path:        "/api/user/urls/export",
+				accept:      "application/x-ndjson",
+				contentType: contentTypeNDJSON,
+				body: `{"original_url":"https://ya.ru","short_url":"http://localhost:8080/abc","is_deleted":false,"created_at":"2022-10-01T12:00:00Z"}` + "\n" +
+					`{"original_url":"https://vk.com/?a=1,2","short_url":"http://localhost:8080/def","is_deleted":true,"created_at":"2022-10-01T12:00:00Z"}` + "\n",
+			},
+			{
+				path:        "/api/user/urls/export?format=csv",
+				accept:      "application/json",
+				contentType: contentTypeCSV,
+				body: "original_url,short_url,is_deleted,created_at\n" +
+					"https://ya.ru,http://localhost:8080/abc,false,2022-10-01T12:00:00Z\n" +
+					"\"https://vk.com/?a=1,2\",http://localhost:8080/def,true,2022-10-01T12:00:00Z\n",
+			},
+		} {
+			mockService := new(MyMockService)
+			mockService.On("EachByUser", "123").Return(shortURLs, nil)
+
+			ts := newServer(mockService)
+
+			resp, respBody := testRequest(t, ts, http.MethodGet, c.path, "", c.accept, "")
+			resp.Body.Close()
+			ts.Close()
+
+			mockService.AssertExpectations(t)
+			require.Equal(t, http.StatusOK, resp.StatusCode, c.path)
+			assert.Equal(t, c.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, c.body, respBody)
+		}
+	})
+
+	t.Run("should export empty list", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("EachByUser", "123").Return(nil, nil)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/api/user/urls/export?format=json", "", "", "")
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "[]\n", respBody)
+	})
+
+	t.Run("should compress export with gzip", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("EachByUser", "123").Return(shortURLs, nil)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls/export?format=ndjson", nil)
+		require.NoError(t, err)
+
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+		gr, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(gr)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"short_url":"http://localhost:8080/def"`)
+	})
+
+	t.Run("should error for incorrect format or store error", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("EachByUser", "123").Return(nil, errors.New("store error"))
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, _ := testRequest(t, ts, http.MethodGet, "/api/user/urls/export?format=xml", "", "", "")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, _ = testRequest(t, ts, http.MethodGet, "/api/user/urls/export", "", "text/html", "")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+
+		resp, _ = testRequest(t, ts, http.MethodGet, "/api/user/urls/export", "", "", "")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/middlewares"
+)
+
+const contentTypeNDJSON = "application/x-ndjson"
+
+type importService interface {
+	Start(userID string, format core.ImportFormat, compressed bool, body io.Reader) (*core.ImportJob, error)
+	Get(userID, id string) (*core.ImportJob, error)
+	Results(userID, id string) (io.ReadCloser, error)
+}
+
+// ImportHandler handlers of bulk import of short urls
+type ImportHandler struct {
+	log     *zap.Logger
+	baseURL string
+	service importService
+}
+
+// NewImportHandler create instance
+func NewImportHandler(log *zap.Logger, baseURL string, service importService) *ImportHandler {
+	return &ImportHandler{
+		log:     log,
+		baseURL: baseURL,
+		service: service,
+	}
+}
+
+// ImportJobDTO progress of import. Read is processed bytes of uploaded file from size
+type ImportJobDTO struct {
+	ID         string `json:"id" example:"Kd93jdLs0aQe"`
+	Format     string `json:"format" example:"csv"`
+	Status     string `json:"status" example:"running"`
+	Error      string `json:"error,omitempty" example:"invalid gzip"`
+	Size       int64  `json:"size" example:"1048576"`
+	Read       int64  `json:"read" example:"524288"`
+	Rows       int    `json:"rows" example:"10000"`
+	Created    int    `json:"created" example:"9990"`
+	Conflicts  int    `json:"conflicts" example:"8"`
+	Invalid    int    `json:"invalid" example:"2"`
+	Failed     int    `json:"failed" example:"0"`
+	CreatedAt  string `json:"created_at" example:"2022-10-01T12:00:00Z"`
+	FinishedAt string `json:"finished_at,omitempty" example:"2022-10-01T12:05:00Z"`
+	ResultsURL string `json:"results_url" example:"http://localhost:8080/api/shorten/import/Kd93jdLs0aQe/results"`
+}
+
+func (h *ImportHandler) newImportJobDTO(job *core.ImportJob) ImportJobDTO {
+	dto := ImportJobDTO{
+		ID:         job.ID,
+		Format:     string(job.Format),
+		Status:     string(job.Status),
+		Error:      job.Error,
+		Size:       job.Size,
+		Read:       job.Read,
+		Rows:       job.Rows,
+		Created:    job.Created,
+		Conflicts:  job.Conflicts,
+		Invalid:    job.Invalid,
+		Failed:     job.Failed,
+		CreatedAt:  job.CreatedAt.UTC().Format(time.RFC3339),
+		ResultsURL: h.baseURL + "/api/shorten/import/" + job.ID + "/results",
+	}
+
+	if !job.FinishedAt.IsZero() {
+		dto.FinishedAt = job.FinishedAt.UTC().Format(time.RFC3339)
+	}
+
+	return dto
+}
+
+// Start загрузка файла со ссылками. Файл сохраняется и обрабатывается в фоне пачками,
+// прогресс доступен по id задачи
+//
+//	@summary Импорт ссылок из CSV или NDJSON
+//	@tags    apiShorten
+//	@accept  text/csv,application/x-ndjson
+//	@produce json
+//	@param   format query    string false "csv или ndjson, по умолчанию по Content-Type"
+//	@param   file   body     string true  "Строки с полями original_url, correlation_id, alias, ttl, expires_at. Для CSV первая строка — заголовок"
+//	@success 202    {object} ImportJobDTO
+//	@header  202    {string} Location "Адрес прогресса импорта"
+//	@failure 400    {string} string message
+//	@failure 413    {string} string message
+//	@failure 429    {string} string message
+//	@failure 500    {string} string message
+//	@router  /api/shorten/import [post]
+func (h *ImportHandler) Start(wr http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+
+	format, compressed, ok := importFormat(r)
+
+	if !ok {
+		http.Error(wr, core.ErrInvalidImportFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.service.Start(userID, format, compressed, r.Body)
+
+	if errors.Is(err, core.ErrInvalidImportFormat) {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, core.ErrImportTooLarge) {
+		http.Error(wr, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if errors.Is(err, core.ErrTooManyImports) {
+		http.Error(wr, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	if err != nil {
+		h.log.Error("start import error", zap.Error(err))
+		http.Error(wr, "error start import", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Add("Location", h.baseURL+"/api/shorten/import/"+job.ID)
+	h.writeJSON(wr, http.StatusAccepted, h.newImportJobDTO(job))
+}
+
+// importFormat format of rows by query param format or Content-Type. Body is compressed
+// with Content-Encoding: gzip or Content-Type of gzip, then format should be in query
+func importFormat(r *http.Request) (core.ImportFormat, bool, bool) {
+	compressed := strings.Contains(r.Header.Get("Content-Encoding"), "gzip")
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "application/gzip" || mediaType == "application/x-gzip" {
+		compressed = true
+	}
+
+	switch format := r.URL.Query().Get("format"); {
+	case format == string(core.ImportCSV):
+		return core.ImportCSV, compressed, true
+	case format == string(core.ImportNDJSON):
+		return core.ImportNDJSON, compressed, true
+	case format != "":
+		return "", false, false
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return core.ImportCSV, compressed, true
+	case contentTypeNDJSON, "application/ndjson":
+		return core.ImportNDJSON, compressed, true
+	default:
+		return "", false, false
+	}
+}
+
+// Get прогресс импорта пользователя
+//
+//	@summary Прогресс импорта
+//	@tags    apiShorten
+//	@produce json
+//	@param   id  path     string true "Идентификатор импорта"
+//	@success 200 {object} ImportJobDTO
+//	@failure 404 {string} string message
+//	@router  /api/shorten/import/{id} [get]
+func (h *ImportHandler) Get(wr http.ResponseWriter, r *http.Request) {
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+
+	job, err := h.service.Get(userID, chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(wr, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(wr, http.StatusOK, h.newImportJobDTO(job))
+}
+
+// Results результаты обработанных строк импорта в NDJSON в порядке строк файла.
+// Для незавершенного импорта только уже обработанные строки
+//
+//	@summary Результаты строк импорта
+//	@tags    apiShorten
+//	@produce application/x-ndjson
+//	@param   id  path     string true "Идентификатор импорта"
+//	@success 200 {array}  core.ImportRowResult
+//	@failure 404 {string} string message
+//	@failure 500 {string} string message
+//	@router  /api/shorten/import/{id}/results [get]
+func (h *ImportHandler) Results(wr http.ResponseWriter, r *http.Request) {
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+	id := chi.URLParam(r, "id")
+
+	results, err := h.service.Results(userID, id)
+
+	if errors.Is(err, core.ErrImportNotFound) {
+		http.Error(wr, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		h.log.Error("import results error", zap.String("id", id), zap.Error(err))
+		http.Error(wr, "error get results", http.StatusInternalServerError)
+		return
+	}
+
+	defer results.Close()
+
+	wr.Header().Add("Content-Type", contentTypeNDJSON)
+	wr.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(wr, results); err != nil {
+		h.log.Error("write import results error", zap.String("id", id), zap.Error(err))
+	}
+}
+
+func (h *ImportHandler) writeJSON(wr http.ResponseWriter, status int, data interface{}) {
+	body, err := json.Marshal(data)
+
+	if err != nil {
+		h.log.Error("json marshal import error", zap.Error(err))
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Add("Content-Type", "application/json")
+	wr.WriteHeader(status)
+	wr.Write(body)
+}
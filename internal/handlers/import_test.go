@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/service"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+)
+
+func TestImportHandler(t *testing.T) {
+	newServer := func(t *testing.T) *httptest.Server {
+		authMockService := new(AuthMockService)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		importer := service.NewImporter(zap.NewNop(), service.NewShorter(storagememory.NewShortURLStore(), nil), t.TempDir())
+		t.Cleanup(importer.Close)
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", new(MyMockService), authMockService, nil, nil, nil, nil, nil, nil, importer, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should import gzip csv and return progress and results", func(t *testing.T) {
+		ts := newServer(t)
+		defer ts.Close()
+
+		var body bytes.Buffer
+
+		gw := gzip.NewWriter(&body)
+		gw.Write([]byte("original_url,correlation_id\nhttps://ya.ru,1\nnot url,2\n"))
+		require.NoError(t, gw.Close())
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/shorten/import", &body)
+		require.NoError(t, err)
+
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set("Content-Encoding", "gzip")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		var job ImportJobDTO
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+
+		assert.NotEmpty(t, job.ID)
+		assert.Equal(t, "csv", job.Format)
+		assert.Equal(t, "http://localhost:8080/api/shorten/import/"+job.ID, resp.Header.Get("Location"))
+
+		require.Eventually(t, func() bool {
+			resp, respBody := testRequest(t, ts, http.MethodGet, "/api/shorten/import/"+job.ID, "", "", "")
+			resp.Body.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.NoError(t, json.Unmarshal([]byte(respBody), &job))
+
+			return job.Status == "done"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, 2, job.Rows)
+		assert.Equal(t, 1, job.Created)
+		assert.Equal(t, 1, job.Invalid)
+		assert.NotEmpty(t, job.FinishedAt)
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/api/shorten/import/"+job.ID+"/results", "", "", "")
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, contentTypeNDJSON, resp.Header.Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSpace(respBody), "\n")
+		require.Equal(t, 2, len(lines))
+		assert.Contains(t, lines[0], `"status":"created"`)
+		assert.Contains(t, lines[1], `"status":"invalid"`)
+	})
+
+	t.Run("should error for unknown format and import", func(t *testing.T) {
+		ts := newServer(t)
+		defer ts.Close()
+
+		resp, _ := testRequest(t, ts, http.MethodPost, "/api/shorten/import", "application/json", "", `[]`)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, _ = testRequest(t, ts, http.MethodPost, "/api/shorten/import?format=xml", "text/csv", "", "original_url")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, _ = testRequest(t, ts, http.MethodGet, "/api/shorten/import/unknown", "", "", "")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, _ = testRequest(t, ts, http.MethodGet, "/api/shorten/import/unknown/results", "", "", "")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/shreyner/go-shortener/internal/core"
 	"go.uber.org/zap"
 )
 
 type internalRepository interface {
-	GetStats(ctx context.Context) (*core.ShortStats, error)
+	GetStats(ctx context.Context, statsRange core.StatsRange) (*core.ShortStats, error)
 }
 
 // InternalHandler with internal handlers
@@ -27,11 +28,49 @@ func NewInternalHandler(log *zap.Logger, repository internalRepository) *Interna
 	}
 }
 
-// GetStats handler stats endpoint
+// StatsDayDTO count of created urls in day
+type StatsDayDTO struct {
+	Day   string `json:"day" example:"2022-10-01"`
+	Count int    `json:"count" example:"12"`
+}
+
+// StatsResponseDTO data transfer object for response
+type StatsResponseDTO struct {
+	URLs          int           `json:"urls" example:"1200"`
+	Users         int           `json:"users" example:"80"`
+	DeletedURLs   int           `json:"deleted_urls" example:"40"`
+	ActiveUsers   int           `json:"active_users" example:"12"`
+	StorageSize   int64         `json:"storage_size" example:"1048576"`
+	From          string        `json:"from" example:"2022-09-02"`
+	To            string        `json:"to" example:"2022-10-01"`
+	CreatedPerDay []StatsDayDTO `json:"created_per_day"`
+}
+
+// GetStats handler stats endpoint. Totals are for all time, active users and created per day for range from/to
+//
+//	@summary Статистика сервиса
+//	@tags    internal
+//	@produce json
+//	@param   from query    string false "Первый день YYYY-MM-DD, по умолчанию 30 дней до to"
+//	@param   to   query    string false "Последний день YYYY-MM-DD, по умолчанию сегодня"
+//	@success 200  {object} StatsResponseDTO
+//	@failure 400  {string} string message
+//	@failure 403  {string} string message
+//	@failure 500  {string} string message
+//	@router  /api/internal/stats [get]
 func (i *InternalHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	stats, err := i.repository.GetStats(ctx)
+	query := r.URL.Query()
+	statsRange, err := core.ParseStatsRange(query.Get("from"), query.Get("to"), time.Now())
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	stats, err := i.repository.GetStats(ctx, statsRange)
 
 	if err != nil {
 		i.log.Error("get stats error", zap.Error(err))
@@ -40,7 +79,22 @@ func (i *InternalHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := json.Marshal(stats)
+	responseDTO := StatsResponseDTO{
+		URLs:          stats.URLs,
+		Users:         stats.Users,
+		DeletedURLs:   stats.DeletedURLs,
+		ActiveUsers:   stats.ActiveUsers,
+		StorageSize:   stats.StorageSize,
+		From:          statsRange.From.Format(core.StatsDayLayout),
+		To:            statsRange.To.AddDate(0, 0, -1).Format(core.StatsDayLayout),
+		CreatedPerDay: make([]StatsDayDTO, len(stats.CreatedPerDay)),
+	}
+
+	for index, day := range stats.CreatedPerDay {
+		responseDTO.CreatedPerDay[index] = StatsDayDTO{Day: day.Day.Format(core.StatsDayLayout), Count: day.Count}
+	}
+
+	body, err := json.Marshal(responseDTO)
 
 	if err != nil {
 		i.log.Error("json marshal stats error", zap.Error(err))
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// InternalMockRepository mock of repository with stats
+type InternalMockRepository struct {
+	mock.Mock
+}
+
+func (m *InternalMockRepository) GetStats(_ context.Context, statsRange core.StatsRange) (*core.ShortStats, error) {
+	args := m.Called(statsRange)
+
+	stats, _ := args.Get(0).(*core.ShortStats)
+
+	return stats, args.Error(1)
+}
+
+func TestInternalHandler_GetStats(t *testing.T) {
+	day := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should return stats in range", func(t *testing.T) {
+		repository := new(InternalMockRepository)
+		handler := NewInternalHandler(zap.NewNop(), repository)
+
+		repository.On("GetStats", core.StatsRange{From: day, To: day.AddDate(0, 0, 2)}).Return(&core.ShortStats{
+			URLs:          10,
+			Users:         3,
+			DeletedURLs:   2,
+			ActiveUsers:   1,
+			StorageSize:   4096,
+			CreatedPerDay: []core.DayCount{{Day: day, Count: 4}, {Day: day.AddDate(0, 0, 1)}},
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/internal/stats?from=2022-10-01&to=2022-10-02", nil)
+		wr := httptest.NewRecorder()
+
+		handler.GetStats(wr, req)
+
+		repository.AssertExpectations(t)
+		require.Equal(t, http.StatusOK, wr.Code)
+		assert.JSONEq(t, `{
+			"urls": 10,
+			"users": 3,
+			"deleted_urls": 2,
+			"active_users": 1,
+			"storage_size": 4096,
+			"from": "2022-10-01",
+			"to": "2022-10-02",
+			"created_per_day": [{"day": "2022-10-01", "count": 4}, {"day": "2022-10-02", "count": 0}]
+		}`, wr.Body.String())
+	})
+
+	t.Run("should error for incorrect range", func(t *testing.T) {
+		repository := new(InternalMockRepository)
+		handler := NewInternalHandler(zap.NewNop(), repository)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/internal/stats?from=2022-10-02&to=2022-10-01", nil)
+		wr := httptest.NewRecorder()
+
+		handler.GetStats(wr, req)
+
+		repository.AssertNotCalled(t, "GetStats")
+		assert.Equal(t, http.StatusBadRequest, wr.Code)
+	})
+
+	t.Run("should forbid access outside trusted subnet", func(t *testing.T) {
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", nil, nil, nil, nil, nil, nil, nil, nil, nil, "10.0.0.0/24")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+		req.Header.Set("X-Forwarded-For", "192.168.1.1")
+		wr := httptest.NewRecorder()
+
+		r.ServeHTTP(wr, req)
+
+		assert.Equal(t, http.StatusForbidden, wr.Code)
+	})
+}
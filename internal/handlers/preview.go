@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// previewSuffix suffix of short url path for preview, /{id}+
+const previewSuffix = "+"
+
+//go:embed templates/preview.html
+var previewHTML string
+
+// previewTemplate page of preview, html/template escapes url of user and replaces unsafe schemes in href
+var previewTemplate = template.Must(template.New("preview").Parse(previewHTML))
+
+// previewData data of preview page
+type previewData struct {
+	URL       string
+	Domain    string
+	ShortURL  string
+	CreatedAt time.Time
+}
+
+// previewRequested preview by suffix of id or by query param preview, id is returned without suffix
+func previewRequested(r *http.Request, id string) (string, bool) {
+	if strings.HasSuffix(id, previewSuffix) {
+		return strings.TrimSuffix(id, previewSuffix), true
+	}
+
+	preview, _ := strconv.ParseBool(r.URL.Query().Get("preview"))
+
+	return id, preview
+}
+
+// renderPreview страница с адресом назначения вместо редиректа, переход по кнопке не записывает клик
+func (sh *ShortedHandler) renderPreview(wr http.ResponseWriter, shortURL *core.ShortURL) {
+	data := previewData{
+		URL:       shortURL.URL,
+		Domain:    shortURL.URL,
+		ShortURL:  fmt.Sprintf("%s/%s", sh.baseURL, shortURL.ID),
+		CreatedAt: shortURL.CreatedAt.UTC(),
+	}
+
+	if parsedURL, err := url.Parse(shortURL.URL); err == nil && parsedURL.Hostname() != "" {
+		data.Domain = parsedURL.Hostname()
+	}
+
+	var body bytes.Buffer
+
+	if err := previewTemplate.Execute(&body, data); err != nil {
+		sh.log.Error("render preview error", zap.String("id", shortURL.ID), zap.Error(err))
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	wr.Header().Set("Referrer-Policy", "no-referrer")
+	wr.Header().Set("X-Content-Type-Options", "nosniff")
+	wr.WriteHeader(http.StatusOK)
+
+	wr.Write(body.Bytes())
+}
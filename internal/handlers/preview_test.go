@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/pkg/clicks"
+)
+
+func TestShortedHandler_GetPreview(t *testing.T) {
+	createdAt := time.Date(2022, 10, 1, 12, 30, 0, 0, time.UTC)
+
+	newServer := func(mockService *MyMockService, clickRecorder *clicks.Recorder) *httptest.Server {
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, new(AuthMockService), nil, nil, nil, clickRecorder, nil, nil, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should render preview by suffix or query param", func(t *testing.T) {
+		for _, path := range []string{"/abc+", "/abc?preview=1", "/abc?preview=true"} {
+			mockService := new(MyMockService)
+			mockService.On("GetByID", "abc").Return(&core.ShortURL{ID: "abc", URL: "https://ya.ru/search?q=go", CreatedAt: createdAt}, true)
+
+			store := &ClickMockStore{}
+			clickRecorder := clicks.NewRecorder(zap.NewNop(), store, 10, 10, time.Hour)
+
+			ts := newServer(mockService, clickRecorder)
+
+			resp, respBody := testRequest(t, ts, http.MethodGet, path, "", "", "")
+			resp.Body.Close()
+			ts.Close()
+			clickRecorder.Close()
+
+			require.Equal(t, http.StatusOK, resp.StatusCode, path)
+			assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+			assert.Empty(t, resp.Header.Get("Location"))
+			assert.Contains(t, respBody, "ya.ru</h1>")
+			assert.Contains(t, respBody, `href="https://ya.ru/search?q=go"`)
+			assert.Contains(t, respBody, "http://localhost:8080/abc")
+			assert.Contains(t, respBody, "01.10.2022 12:30 UTC")
+			assert.Empty(t, store.clicks, path)
+		}
+	})
+
+	t.Run("should escape url of user", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("GetByID", "abc").Return(&core.ShortURL{ID: "abc", URL: `javascript:alert("<b>1</b>")`, CreatedAt: createdAt}, true)
+
+		ts := newServer(mockService, nil)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/abc+", "", "", "")
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotContains(t, respBody, "<b>1</b>")
+		assert.Contains(t, respBody, "&lt;b&gt;1&lt;/b&gt;")
+		assert.Contains(t, respBody, `href="#ZgotmplZ"`)
+	})
+
+	t.Run("should return not found and gone like redirect", func(t *testing.T) {
+		tests := []struct {
+			shortURL *core.ShortURL
+			code     int
+		}{
+			{shortURL: nil, code: http.StatusNotFound},
+			{shortURL: &core.ShortURL{ID: "abc", IsDeleted: true}, code: http.StatusGone},
+			{shortURL: &core.ShortURL{ID: "abc", ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}}, code: http.StatusGone},
+		}
+
+		for _, tt := range tests {
+			mockService := new(MyMockService)
+			mockService.On("GetByID", "abc").Return(tt.shortURL, tt.shortURL != nil)
+
+			ts := newServer(mockService, nil)
+
+			resp, _ := testRequest(t, ts, http.MethodGet, "/abc+", "", "", "")
+			resp.Body.Close()
+			ts.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/pkg/qr"
+)
+
+const (
+	contentTypePNG = "image/png"
+	contentTypeSVG = "image/svg+xml"
+
+	qrDefaultSize   = 256
+	qrMinSize       = 64
+	qrMaxSize       = 2048
+	qrDefaultMargin = 4
+	qrMaxMargin     = 16
+	// qrMaxAge QR code of short url doesn't change, but link can be deleted, so it's cached for a day
+	qrMaxAge = 24 * time.Hour
+)
+
+// qrFormats formats of QR code by query param format
+var qrFormats = map[string]string{
+	"png": contentTypePNG,
+	"svg": contentTypeSVG,
+}
+
+// qrOptions options of render QR code from query
+type qrOptions struct {
+	contentType string
+	size        int
+	margin      int
+	level       qr.Level
+	levelName   string
+}
+
+// QR QR код полной короткой ссылки в PNG или SVG. Для удаленной или истекшей ссылки 410, как при переходе
+//
+//	@summary QR код короткой ссылки
+//	@tags    shorten
+//	@produce png,image/svg+xml
+//	@param   id     path     string true  "Идентификатор короткой ссылки"
+//	@param   format query    string false "png или svg, по умолчанию png"
+//	@param   size   query    int    false "Размер стороны в пикселях от 64 до 2048, по умолчанию 256"
+//	@param   level  query    string false "Уровень коррекции ошибок L, M, Q или H, по умолчанию M"
+//	@param   margin query    int    false "Отступ в модулях от 0 до 16, по умолчанию 4"
+//	@success 200    {file}   binary
+//	@success 304
+//	@failure 400    {string} string message
+//	@failure 404    {string} string message
+//	@failure 410    {string} string message
+//	@failure 500    {string} string message
+//	@router  /{id}/qr [get]
+func (sh *ShortedHandler) QR(wr http.ResponseWriter, r *http.Request) {
+	opts, err := parseQROptions(r)
+
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, ok := sh.ShorterService.GetByID(r.Context(), chi.URLParam(r, "id"))
+
+	if !ok {
+		http.Error(wr, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if shortURL.IsDeleted {
+		http.Error(wr, "Was deleted", http.StatusGone)
+		return
+	}
+
+	if shortURL.IsExpired(time.Now()) {
+		http.Error(wr, "Was expired", http.StatusGone)
+		return
+	}
+
+	text := fmt.Sprintf("%s/%s", sh.baseURL, shortURL.ID)
+
+	etagHash := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d|%s", text, opts.contentType, opts.size, opts.margin, opts.levelName)))
+	etag := `"` + hex.EncodeToString(etagHash[:16]) + `"`
+
+	wr.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(qrMaxAge.Seconds())))
+	wr.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		wr.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	code, err := qr.Encode(text, opts.level)
+
+	if err != nil {
+		sh.log.Error("encode qr error", zap.String("id", shortURL.ID), zap.Error(err))
+		http.Error(wr, "error create qr", http.StatusInternalServerError)
+		return
+	}
+
+	var body []byte
+
+	if opts.contentType == contentTypeSVG {
+		body = code.SVG(opts.size, opts.margin)
+	} else if body, err = code.PNG(opts.size, opts.margin); err != nil {
+		sh.log.Error("render qr error", zap.String("id", shortURL.ID), zap.Error(err))
+		http.Error(wr, "error create qr", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Set("Content-Type", opts.contentType)
+	wr.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	wr.WriteHeader(http.StatusOK)
+
+	wr.Write(body)
+}
+
+func parseQROptions(r *http.Request) (qrOptions, error) {
+	query := r.URL.Query()
+	opts := qrOptions{contentType: contentTypePNG, size: qrDefaultSize, margin: qrDefaultMargin, level: qr.LevelMedium, levelName: "M"}
+
+	if query.Has("format") {
+		contentType, ok := qrFormats[query.Get("format")]
+
+		if !ok {
+			return opts, errors.New("format should be png or svg")
+		}
+
+		opts.contentType = contentType
+	}
+
+	if query.Has("size") {
+		size, err := strconv.Atoi(query.Get("size"))
+
+		if err != nil || size < qrMinSize || size > qrMaxSize {
+			return opts, fmt.Errorf("size should be from %d to %d", qrMinSize, qrMaxSize)
+		}
+
+		opts.size = size
+	}
+
+	if query.Has("margin") {
+		margin, err := strconv.Atoi(query.Get("margin"))
+
+		if err != nil || margin < 0 || margin > qrMaxMargin {
+			return opts, fmt.Errorf("margin should be from 0 to %d", qrMaxMargin)
+		}
+
+		opts.margin = margin
+	}
+
+	if query.Has("level") {
+		level, err := qr.ParseLevel(query.Get("level"))
+
+		if err != nil {
+			return opts, err
+		}
+
+		opts.level = level
+		opts.levelName = strings.ToUpper(query.Get("level"))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+func TestShortedHandler_QR(t *testing.T) {
+	newServer := func(mockService *MyMockService) *httptest.Server {
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, new(AuthMockService), nil, nil, nil, nil, nil, nil, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should render png by default with cache headers", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("GetByID", "abc").Return(&core.ShortURL{ID: "abc", URL: "https://ya.ru"}, true)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/abc/qr?size=128&level=h&margin=0", "", "", "")
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, contentTypePNG, resp.Header.Get("Content-Type"))
+		assert.Equal(t, "public, max-age=86400", resp.Header.Get("Cache-Control"))
+		assert.NotEmpty(t, resp.Header.Get("ETag"))
+
+		img, err := png.Decode(bytes.NewReader([]byte(respBody)))
+		require.NoError(t, err)
+		assert.Equal(t, 128, img.Bounds().Dx())
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/abc/qr?size=128&level=h&margin=0", nil)
+		require.NoError(t, err)
+
+		req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+
+		notModified, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer notModified.Body.Close()
+
+		assert.Equal(t, http.StatusNotModified, notModified.StatusCode)
+	})
+
+	t.Run("should render svg", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("GetByID", "abc").Return(&core.ShortURL{ID: "abc", URL: "https://ya.ru"}, true)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/abc/qr?format=svg&size=300", "", "", "")
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, contentTypeSVG, resp.Header.Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(respBody, `<svg xmlns="http://www.w3.org/2000/svg" width="300" height="300"`))
+	})
+
+	t.Run("should return not found and gone like redirect", func(t *testing.T) {
+		tests := []struct {
+			shortURL *core.ShortURL
+			code     int
+		}{
+			{shortURL: nil, code: http.StatusNotFound},
+			{shortURL: &core.ShortURL{ID: "abc", IsDeleted: true}, code: http.StatusGone},
+			{shortURL: &core.ShortURL{ID: "abc", ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}}, code: http.StatusGone},
+		}
+
+		for _, tt := range tests {
+			mockService := new(MyMockService)
+			mockService.On("GetByID", "abc").Return(tt.shortURL, tt.shortURL != nil)
+
+			ts := newServer(mockService)
+
+			resp, _ := testRequest(t, ts, http.MethodGet, "/abc/qr", "", "", "")
+			resp.Body.Close()
+			ts.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get("Cache-Control"))
+		}
+	})
+
+	t.Run("should error for incorrect params", func(t *testing.T) {
+		mockService := new(MyMockService)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		for _, query := range []string{"format=gif", "size=10", "size=abc", "margin=100", "level=X"} {
+			resp, _ := testRequest(t, ts, http.MethodGet, "/abc/qr?"+query, "", "", "")
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+
+		mockService.AssertNotCalled(t, "GetByID")
+	})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// nullableInt64 json field which can be absent, null or number. Set is true for null and number
+type nullableInt64 struct {
+	Set   bool
+	Value *int64
+}
+
+// UnmarshalJSON is called only for field in json, null is passed as is
+func (n *nullableInt64) UnmarshalJSON(data []byte) error {
+	n.Set = true
+
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+
+	return json.Unmarshal(data, &n.Value)
+}
+
+// isNull field is null in json
+func (n nullableInt64) isNull() bool {
+	return n.Set && n.Value == nil
+}
+
+// ptrInt64 optional number of response
+func ptrInt64(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+
+	return &v.Int64
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+func TestShortedHandler_Redirect(t *testing.T) {
+	newServer := func(mockService *MyMockService) *httptest.Server {
+		authMockService := new(AuthMockService)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should redirect with type and cache control of short url", func(t *testing.T) {
+		tests := []struct {
+			shortURL     *core.ShortURL
+			code         int
+			cacheControl string
+		}{
+			{shortURL: &core.ShortURL{ID: "abc", URL: "https://ya.ru"}, code: http.StatusTemporaryRedirect},
+			{
+				shortURL:     &core.ShortURL{ID: "abc", URL: "https://ya.ru", RedirectType: http.StatusMovedPermanently, CacheMaxAge: sql.NullInt64{Int64: 3600, Valid: true}},
+				code:         http.StatusMovedPermanently,
+				cacheControl: "public, max-age=3600",
+			},
+			{
+				shortURL:     &core.ShortURL{ID: "abc", URL: "https://ya.ru", RedirectType: http.StatusFound, CacheMaxAge: sql.NullInt64{Valid: true}},
+				code:         http.StatusFound,
+				cacheControl: "no-store",
+			},
+		}
+
+		for _, tt := range tests {
+			mockService := new(MyMockService)
+			mockService.On("GetByID", "abc").Return(tt.shortURL, true)
+
+			ts := newServer(mockService)
+
+			resp, _ := testRequest(t, ts, http.MethodGet, "/abc", "", "", "")
+			resp.Body.Close()
+			ts.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode)
+			assert.Equal(t, "https://ya.ru", resp.Header.Get("Location"))
+			assert.Equal(t, tt.cacheControl, resp.Header.Get("Cache-Control"))
+		}
+	})
+
+	t.Run("should create with redirect options", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.
+			On("Create", mock.Anything, "https://ya.ru/", core.CreateOptions{
+				RedirectOptions: core.RedirectOptions{RedirectType: http.StatusPermanentRedirect, CacheMaxAge: sql.NullInt64{Valid: true}},
+			}).
+			Return(&core.ShortURL{URL: "https://ya.ru/", ID: "abc"}, nil)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, _ := testRequest(t, ts, http.MethodPost, "/api/shorten", "application/json", "", `{"url":"https://ya.ru/","redirect_type":308,"cache_max_age":0}`)
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("should error for invalid redirect options on create", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("Create", mock.Anything, "https://ya.ru/", mock.Anything).Return((*core.ShortURL)(nil), core.ErrInvalidRedirectType)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, _ := testRequest(t, ts, http.MethodPost, "/api/shorten", "application/json", "", `{"url":"https://ya.ru/","redirect_type":200}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("should change only redirect options", func(t *testing.T) {
+		redirectType := http.StatusMovedPermanently
+
+		mockService := new(MyMockService)
+		mockService.
+			On("SetRedirect", "123", "abc", core.RedirectUpdate{RedirectType: &redirectType, CacheMaxAge: &sql.NullInt64{}}).
+			Return(&core.ShortURL{ID: "abc", URL: "https://ya.ru", RedirectType: http.StatusMovedPermanently}, nil)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodPatch, "/api/user/urls/abc", "application/json", "", `{"redirect_type":301,"cache_max_age":null}`)
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		mockService.AssertNotCalled(t, "Update")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.JSONEq(t, `{"short_url": "http://localhost:8080/abc", "original_url": "https://ya.ru", "redirect_type": 301}`, respBody)
+	})
+
+	t.Run("should change url and max-age", func(t *testing.T) {
+		maxAge := sql.NullInt64{Int64: 60, Valid: true}
+
+		mockService := new(MyMockService)
+		mockService.
+			On("Update", "123", "abc", "https://ya.ru", core.RedirectUpdate{CacheMaxAge: &maxAge}).
+			Return(&core.ShortURL{ID: "abc", URL: "https://ya.ru", CacheMaxAge: maxAge}, nil)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodPatch, "/api/user/urls/abc", "application/json", "", `{"url":"https://ya.ru","cache_max_age":60}`)
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		mockService.AssertNotCalled(t, "SetRedirect")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.JSONEq(t, `{"short_url": "http://localhost:8080/abc", "original_url": "https://ya.ru", "redirect_type": 307, "cache_max_age": 60}`, respBody)
+	})
+
+	t.Run("should error for empty or invalid change", func(t *testing.T) {
+		mockService := new(MyMockService)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		for _, body := range []string{`{}`, `{"url":"https://ya.ru","redirect_type":200}`, `{"cache_max_age":-5}`} {
+			resp, _ := testRequest(t, ts, http.MethodPatch, "/api/user/urls/abc", "application/json", "", body)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+
+		mockService.AssertNotCalled(t, "Update")
+		mockService.AssertNotCalled(t, "SetRedirect")
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/shreyner/go-shortener/internal/middlewares"
+	"github.com/shreyner/go-shortener/internal/pkg/clicks"
 	"github.com/shreyner/go-shortener/internal/pkg/fans"
 	"github.com/shreyner/go-shortener/internal/repositories"
 	"github.com/shreyner/go-shortener/internal/storage"
@@ -32,6 +33,10 @@ func NewRouter(
 	shortURIRepository repositories.ShortURLRepository,
 	storage *storage.Storage,
 	fansShortService *fans.FansShortService,
+	clickRecorder *clicks.Recorder,
+	clickStatsService clickStatsService,
+	webhookService webhookService,
+	importService importService,
 	trustedSubnet string,
 ) *chi.Mux {
 	r := chi.NewRouter()
@@ -45,9 +50,20 @@ func NewRouter(
 	realIPMiddleware := middlewares.RealIP
 	cidrAccessMiddleware, _ := middlewares.CIDRAccess(trustedSubnet) // 192.168.88.0/24,127.0.0.1/32
 
-	shortedHandler := NewShortedHandler(log, baseURL, shorterService, shortURIRepository, fansShortService)
+	shortedHandler := NewShortedHandler(log, baseURL, shorterService, shortURIRepository, fansShortService, clickRecorder)
 	storeHandler := NewStoreHandler(log, storage)
 	internalHandler := NewInternalHandler(log, shortURIRepository)
+	clickStatsHandler := NewClickStatsHandler(log, clickStatsService)
+	webhooksHandler := NewWebhooksHandler(log, webhookService)
+	importHandler := NewImportHandler(log, baseURL, importService)
+
+	var eventRepository repositories.EventRepository
+
+	if storage != nil {
+		eventRepository = storage.Events
+	}
+
+	eventsHandler := NewEventsHandler(log, eventRepository)
 
 	r.Route("/api", func(r chi.Router) {
 		r.With(authMiddleware).Route("/shorten", func(r chi.Router) {
@@ -59,17 +75,35 @@ func NewRouter(
 				Post("/", shortedHandler.APICreate)
 
 			r.Post("/batch", shortedHandler.APICreateBatch)
+
+			r.Route("/import", func(r chi.Router) {
+				r.With(chiMiddleware.AllowContentEncoding("gzip")).Post("/", importHandler.Start)
+				r.Get("/{id}", importHandler.Get)
+				r.Get("/{id}/results", importHandler.Results)
+			})
 		})
 
 		r.With(authMiddleware).Route("/user", func(r chi.Router) {
 			r.Route("/urls", func(r chi.Router) {
 				r.Get("/", shortedHandler.APIUserURLs)
 				r.Delete("/", shortedHandler.APIUserDeleteURLs)
+				r.With(middlewares.GzlibCompressHandler).Get("/export", shortedHandler.APIUserURLsExport)
+				r.Patch("/{id}", shortedHandler.APIUserUpdateURL)
+				r.Get("/{id}/history", shortedHandler.APIUserURLHistory)
+				r.Post("/{id}/rollback", shortedHandler.APIUserURLRollback)
+				r.Get("/{id}/stats", clickStatsHandler.Get)
+			})
+
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Get("/", webhooksHandler.List)
+				r.Post("/", webhooksHandler.Create)
+				r.Delete("/{id}", webhooksHandler.Delete)
 			})
 		})
 
 		r.With(realIPMiddleware, cidrAccessMiddleware).Route("/internal", func(r chi.Router) {
 			r.Get("/stats", internalHandler.GetStats)
+			r.Get("/events", eventsHandler.List)
 		})
 	})
 
@@ -82,7 +116,8 @@ func NewRouter(
 
 	r.Get("/ping", storeHandler.Ping)
 
-	r.Get("/{id}", shortedHandler.Get)
+	r.With(realIPMiddleware).Get("/{id}", shortedHandler.Get)
+	r.Get("/{id}/qr", shortedHandler.QR)
 
 	//r.Mount("/debug", chiMiddleware.Profiler())
 
@@ -10,13 +10,17 @@ import (
 	"fmt"
 	"io"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/shreyner/go-shortener/internal/core"
 	"github.com/shreyner/go-shortener/internal/middlewares"
+	"github.com/shreyner/go-shortener/internal/pkg/clicks"
 	"github.com/shreyner/go-shortener/internal/pkg/fans"
 	"github.com/shreyner/go-shortener/internal/pkg/pool"
 	"github.com/shreyner/go-shortener/internal/repositories"
@@ -31,10 +35,16 @@ var (
 
 // ShortedService interface for service with business logic
 type ShortedService interface {
-	Create(ctx context.Context, userID, url string) (*core.ShortURL, error)
+	Create(ctx context.Context, userID, url string, opts core.CreateOptions) (*core.ShortURL, error)
 	CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error
 	GetByID(ctx context.Context, key string) (*core.ShortURL, bool)
 	AllByUser(ctx context.Context, id string) ([]*core.ShortURL, error)
+	ListByUser(ctx context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error)
+	EachByUser(ctx context.Context, id string, fn func(shortURL *core.ShortURL) error) error
+	Update(ctx context.Context, userID, id, url string, redirect core.RedirectUpdate) (*core.ShortURL, error)
+	History(ctx context.Context, userID, id string) ([]*core.URLVersion, error)
+	Rollback(ctx context.Context, userID, id string, version int) (*core.ShortURL, error)
+	SetRedirect(ctx context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error)
 }
 
 // ShortedHandler include handlers for shorteners handlers
@@ -43,6 +53,7 @@ type ShortedHandler struct {
 	ShorterService    ShortedService
 	ShorterRepository repositories.ShortURLRepository
 	fansShortService  *fans.FansShortService
+	clickRecorder     *clicks.Recorder
 	baseURL           string
 }
 
@@ -53,6 +64,7 @@ func NewShortedHandler(
 	shorterService ShortedService,
 	shorterRepository repositories.ShortURLRepository,
 	fansShortService *fans.FansShortService,
+	clickRecorder *clicks.Recorder,
 ) *ShortedHandler {
 	return &ShortedHandler{
 		ShorterService:    shorterService,
@@ -60,6 +72,7 @@ func NewShortedHandler(
 		baseURL:           baseURL,
 		log:               log,
 		fansShortService:  fansShortService,
+		clickRecorder:     clickRecorder,
 	}
 }
 
@@ -116,7 +129,7 @@ func (sh *ShortedHandler) Create(wr http.ResponseWriter, r *http.Request) {
 
 	userID, _ := middlewares.GetUserIDCtx(r.Context())
 
-	shortURL, err := sh.ShorterService.Create(r.Context(), userID, string(body))
+	shortURL, err := sh.ShorterService.Create(r.Context(), userID, string(body), core.CreateOptions{})
 
 	var shortURLCreateConflictError *sdb.ShortURLCreateConflictError
 
@@ -138,16 +151,19 @@ func (sh *ShortedHandler) Create(wr http.ResponseWriter, r *http.Request) {
 	wr.Write([]byte(fmt.Sprintf("%s/%s", sh.baseURL, shortURL.ID)))
 }
 
-// Get Редирект по короткой ссылке
+// Get Редирект по короткой ссылке. С суффиксом + у идентификатора или preview=1 вместо редиректа
+// отдается страница с адресом назначения
 //
 //	@summary Редирект по короткой ссылке
-//	@param   id path string true "URL ID"
-//	@success 307
+//	@param   id      path  string true  "URL ID, с суффиксом + страница предпросмотра"
+//	@param   preview query bool   false "Страница предпросмотра вместо редиректа"
+//	@success 301,302,307,308 "Тип редиректа ссылки, по умолчанию 307"
+//	@success 200 {string} string html страница предпросмотра
 //	@failure 404 {string} message
-//	@failure 409 {string} message Was deleted
+//	@failure 410 {string} message Was deleted or expired
 //	@router  /{id} [get]
 func (sh *ShortedHandler) Get(wr http.ResponseWriter, r *http.Request) {
-	shortCode := chi.URLParam(r, "id")
+	shortCode, preview := previewRequested(r, chi.URLParam(r, "id"))
 
 	shortURL, ok := sh.ShorterService.GetByID(r.Context(), shortCode)
 
@@ -161,12 +177,56 @@ func (sh *ShortedHandler) Get(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(wr, r, shortURL.URL, http.StatusTemporaryRedirect)
+	if shortURL.IsExpired(time.Now()) {
+		http.Error(wr, "Was expired", http.StatusGone)
+		return
+	}
+
+	if preview {
+		sh.renderPreview(wr, shortURL)
+		return
+	}
+
+	if cacheControl := shortURL.CacheControl(time.Now()); cacheControl != "" {
+		wr.Header().Set("Cache-Control", cacheControl)
+	}
+
+	http.Redirect(wr, r, shortURL.URL, shortURL.RedirectStatus())
+
+	sh.recordClick(r, shortURL.ID)
+}
+
+// recordClick отправить клик в очередь записи, редирект не ждет сохранения
+func (sh *ShortedHandler) recordClick(r *http.Request, id string) {
+	if sh.clickRecorder == nil {
+		return
+	}
+
+	ip := ""
+
+	if realIP := middlewares.GetRealIPCtx(r.Context()); realIP != nil {
+		ip = realIP.String()
+	} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	sh.clickRecorder.Record(&core.Click{
+		ShortURLID: id,
+		CreatedAt:  time.Now().UTC(),
+		Referrer:   r.Referer(),
+		UserAgent:  r.UserAgent(),
+		IP:         ip,
+	})
 }
 
 // ShortedCreateDTO data transfer object for request
 type ShortedCreateDTO struct {
-	URL string `json:"url" example:"https://ya.ru"`
+	URL          string `json:"url" example:"https://ya.ru"`
+	TTL          int64  `json:"ttl,omitempty" example:"3600"`
+	ExpiresAt    string `json:"expires_at,omitempty" example:"2023-01-01T00:00:00Z"`
+	Alias        string `json:"alias,omitempty" example:"spring-sale"`
+	RedirectType int    `json:"redirect_type,omitempty" example:"301"`
+	CacheMaxAge  *int64 `json:"cache_max_age,omitempty" example:"3600"`
 }
 
 // ShortedCreateDTOPool pool dto for requests
@@ -177,6 +237,11 @@ type ShortedCreateDTOPool struct {
 // Put return object to pool
 func (p *ShortedCreateDTOPool) Put(v *ShortedCreateDTO) {
 	v.URL = ""
+	v.TTL = 0
+	v.ExpiresAt = ""
+	v.Alias = ""
+	v.RedirectType = 0
+	v.CacheMaxAge = nil
 	p.Pool.Put(v)
 }
 
@@ -209,6 +274,7 @@ var shortedResponseDTOPool = &ShortedResponseDTOPool{}
 //	@param   request body     ShortedCreateDTO true "Ссылка для сокращения"
 //	@success 201     {object} ShortedResponseDTO
 //	@failure 409     {object} ShortedResponseDTO Ранее созданная короткая ссылка
+//	@failure 409     {string} string             Псевдоним занят
 //	@failure 400     {string} string             message
 //	@failure 500     {string} string             message
 //	@router  /api/shorten/ [post]
@@ -277,29 +343,38 @@ func (sh *ShortedHandler) APICreate(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, _ := middlewares.GetUserIDCtx(r.Context())
-	shortURL, err := sh.ShorterService.Create(r.Context(), userID, shortedCreateDTO.URL)
-
-	// TODO: Отрефакторить и убрать дублирование кода
-	var shortURLCreateConflictError *sdb.ShortURLCreateConflictError
-
-	if errors.As(err, &shortURLCreateConflictError) {
-		resultURL := fmt.Sprintf("%s/%s", sh.baseURL, shortURLCreateConflictError.OriginID)
-
-		responseCreateDTO := ShortedResponseDTO{Result: resultURL}
+	expiresAt, err := core.ParseExpiration(shortedCreateDTO.TTL, shortedCreateDTO.ExpiresAt, time.Now())
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		responseBody, errJSONMarshal := json.Marshal(responseCreateDTO)
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+	shortURL, err := sh.ShorterService.Create(r.Context(), userID, shortedCreateDTO.URL, core.CreateOptions{
+		ExpiresAt: expiresAt,
+		Alias:     shortedCreateDTO.Alias,
+		RedirectOptions: core.RedirectOptions{
+			RedirectType: shortedCreateDTO.RedirectType,
+			CacheMaxAge:  core.NewCacheMaxAge(shortedCreateDTO.CacheMaxAge),
+		},
+	})
+
+	if errors.Is(err, core.ErrInvalidAlias) || core.IsInvalidRedirect(err) {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-		if errJSONMarshal != nil {
-			http.Error(wr, "error create response", http.StatusInternalServerError)
-			return
-		}
+	var shortURLIDConflictError *sdb.ShortURLIDConflictError
 
-		wr.Header().Add("Content-Type", "application/json")
-		wr.WriteHeader(http.StatusConflict)
+	if errors.As(err, &shortURLIDConflictError) {
+		http.Error(wr, fmt.Sprintf("alias %s is taken", shortURLIDConflictError.ID), http.StatusConflict)
+		return
+	}
 
-		wr.Write(responseBody)
+	var shortURLCreateConflictError *sdb.ShortURLCreateConflictError
 
+	if errors.As(err, &shortURLCreateConflictError) {
+		sh.writeCreateConflict(wr, shortURLCreateConflictError.OriginID)
 		return
 	}
 
@@ -332,6 +407,11 @@ func (sh *ShortedHandler) APICreate(wr http.ResponseWriter, r *http.Request) {
 type ShortedCreateBatchDTO struct {
 	CorrelationID string `json:"correlation_id" example:"1"`
 	OriginalURL   string `json:"original_url" example:"https://ya.ru"`
+	TTL           int64  `json:"ttl,omitempty" example:"3600"`
+	ExpiresAt     string `json:"expires_at,omitempty" example:"2023-01-01T00:00:00Z"`
+	Alias         string `json:"alias,omitempty" example:"spring-sale"`
+	RedirectType  int    `json:"redirect_type,omitempty" example:"301"`
+	CacheMaxAge   *int64 `json:"cache_max_age,omitempty" example:"3600"`
 }
 
 // ShortedResponseBatchDTO data transfer object for response
@@ -349,7 +429,9 @@ type ShortedResponseBatchDTO struct {
 //	@param   request body     []ShortedCreateBatchDTO true "Ссылки для сокращения"
 //	@success 201     {array}  ShortedResponseBatchDTO
 //	@failure 400     {string} string message
-//	@failure 500     {string} string message
+//	@failure 409     {object} ShortedResponseDTO Ранее созданная короткая ссылка с одним из url
+//	@failure 409     {string} string             Псевдоним занят
+//	@failure 500     {string} string             message
 //	@router  /api/shorten/batch [post]
 func (sh *ShortedHandler) APICreateBatch(wr http.ResponseWriter, r *http.Request) {
 	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
@@ -412,6 +494,7 @@ func (sh *ShortedHandler) APICreateBatch(wr http.ResponseWriter, r *http.Request
 	userID, _ := middlewares.GetUserIDCtx(r.Context())
 
 	shoredURLs := make([]*core.ShortURL, len(shortedCreateBatchDTO))
+	now := time.Now()
 
 	for i, v := range shortedCreateBatchDTO {
 		_, err = url.ParseRequestURI(v.OriginalURL)
@@ -420,17 +503,48 @@ func (sh *ShortedHandler) APICreateBatch(wr http.ResponseWriter, r *http.Request
 			return
 		}
 
+		expiresAt, errExpiration := core.ParseExpiration(v.TTL, v.ExpiresAt, now)
+		if errExpiration != nil {
+			http.Error(wr, fmt.Sprintf("%s for correlation_id: %s", errExpiration, v.CorrelationID), http.StatusBadRequest)
+			return
+		}
+
 		shoredURLs[i] = &core.ShortURL{
+			ID: v.Alias,
 			UserID: sql.NullString{
 				String: userID,
 				Valid:  userID != "",
 			},
 			URL:           v.OriginalURL,
 			CorrelationID: v.CorrelationID,
+			ExpiresAt:     expiresAt,
+			RedirectType:  v.RedirectType,
+			CacheMaxAge:   core.NewCacheMaxAge(v.CacheMaxAge),
 		}
 	}
 
-	if err = sh.ShorterService.CreateBatch(r.Context(), &shoredURLs); err != nil {
+	err = sh.ShorterService.CreateBatch(r.Context(), &shoredURLs)
+
+	if errors.Is(err, core.ErrInvalidAlias) || core.IsInvalidRedirect(err) {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var shortURLIDConflictError *sdb.ShortURLIDConflictError
+
+	if errors.As(err, &shortURLIDConflictError) {
+		http.Error(wr, fmt.Sprintf("alias %s is taken", shortURLIDConflictError.ID), http.StatusConflict)
+		return
+	}
+
+	var shortURLCreateConflictError *sdb.ShortURLCreateConflictError
+
+	if errors.As(err, &shortURLCreateConflictError) {
+		sh.writeCreateConflict(wr, shortURLCreateConflictError.OriginID)
+		return
+	}
+
+	if err != nil {
 		http.Error(wr, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -461,24 +575,60 @@ type ShortedAllUserUResponseDTO struct {
 	OriginalURL string `json:"original_url" example:"https://ya.ru"`
 }
 
-// APIUserURLs Получить всех коротких ссылок пользователя
+// APIUserURLs Получить всех коротких ссылок пользователя.
+// С параметрами limit, cursor или sort ссылки отдаются страницами, курсор следующей страницы в заголовке X-Next-Cursor
 //
 //	@summary Получить всех коротких ссылок пользователя
 //	@tags    apiShorten
 //	@produce json
-//	@success 200 {array} ShortedAllUserUResponseDTO
+//	@param   limit  query    int    false "Размер страницы, по умолчанию 100, не больше 1000"
+//	@param   cursor query    string false "Курсор из заголовка X-Next-Cursor предыдущей страницы"
+//	@param   sort   query    string false "Сортировка: id, -id, created_at, -created_at"
+//	@success 200    {array}  ShortedAllUserUResponseDTO
+//	@header  200    {string} X-Next-Cursor "Курсор следующей страницы"
 //	@success 204
+//	@failure 400
 //	@failure 403
 //	@failure 500
 //	@router  /api/user/urls [get]
 func (sh *ShortedHandler) APIUserURLs(wr http.ResponseWriter, r *http.Request) {
 	userID, _ := middlewares.GetUserIDCtx(r.Context())
 
-	content, err := sh.ShorterService.AllByUser(r.Context(), userID)
+	var content []*core.ShortURL
+	var nextCursor string
 
-	if err != nil {
-		http.Error(wr, "error create response", http.StatusInternalServerError)
-		return
+	query := r.URL.Query()
+
+	if query.Has("limit") || query.Has("cursor") || query.Has("sort") {
+		opts, err := parseListOptions(query)
+
+		if err != nil {
+			http.Error(wr, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := sh.ShorterService.ListByUser(r.Context(), userID, opts)
+
+		if errors.Is(err, repositories.ErrInvalidCursor) {
+			http.Error(wr, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			http.Error(wr, "error create response", http.StatusInternalServerError)
+			return
+		}
+
+		content = page.ShortURLs
+		nextCursor = page.NextCursor
+	} else {
+		var err error
+		content, err = sh.ShorterService.AllByUser(r.Context(), userID)
+
+		if err != nil {
+			http.Error(wr, "error create response", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	if len(content) == 0 {
@@ -499,10 +649,41 @@ func (sh *ShortedHandler) APIUserURLs(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if nextCursor != "" {
+		wr.Header().Set("X-Next-Cursor", nextCursor)
+	}
+
 	wr.Header().Add("Content-Type", "application/json")
 	wr.Write(newContent)
 }
 
+// parseListOptions разбор параметров страницы из query
+func parseListOptions(query url.Values) (core.ListOptions, error) {
+	var opts core.ListOptions
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+
+		if err != nil || limit <= 0 || limit > repositories.MaxPageLimit {
+			return opts, fmt.Errorf("limit should be from 1 to %d", repositories.MaxPageLimit)
+		}
+
+		opts.Limit = limit
+	}
+
+	sortBy, desc, err := core.ParseSort(query.Get("sort"))
+
+	if err != nil {
+		return opts, err
+	}
+
+	opts.SortBy = sortBy
+	opts.Desc = desc
+	opts.Cursor = query.Get("cursor")
+
+	return opts, nil
+}
+
 // APIUserDeleteURLs Удаление ссылок пользователем
 //
 //	@summary Удаление ссылок пользователем
@@ -581,6 +762,165 @@ func (sh *ShortedHandler) APIUserDeleteURLs(wr http.ResponseWriter, r *http.Requ
 	wr.WriteHeader(http.StatusAccepted)
 }
 
+// ShortedUpdateDTO data transfer object for request of change url and redirect. Absent field isn't changed,
+// null cache_max_age removes Cache-Control of redirect
+type ShortedUpdateDTO struct {
+	URL          string        `json:"url,omitempty" example:"https://ya.ru"`
+	RedirectType *int          `json:"redirect_type,omitempty" example:"308"`
+	CacheMaxAge  nullableInt64 `json:"cache_max_age" swaggertype:"integer" example:"3600"`
+}
+
+// ShortedUpdateResponseDTO short url after change
+type ShortedUpdateResponseDTO struct {
+	ShortURL     string `json:"short_url" example:"http://localhost:8080/Sjfnwf"`
+	OriginalURL  string `json:"original_url" example:"https://ya.ru"`
+	RedirectType int    `json:"redirect_type" example:"307"`
+	CacheMaxAge  *int64 `json:"cache_max_age,omitempty" example:"3600"`
+}
+
+// APIUserUpdateURL Изменение оригинальной ссылки, типа редиректа и max-age владельцем,
+// идентификатор короткой ссылки остается прежним
+//
+//	@summary Изменение оригинальной ссылки и редиректа
+//	@tags    apiShorten
+//	@accept  json
+//	@produce json
+//	@param   id      path     string           true "Идентификатор короткой ссылки"
+//	@param   request body     ShortedUpdateDTO true "Изменяемые поля"
+//	@success 200     {object} ShortedUpdateResponseDTO
+//	@failure 409     {object} ShortedResponseDTO Ранее созданная короткая ссылка с этим url
+//	@failure 400     {string} string             message
+//	@failure 403     {string} string             message
+//	@failure 404     {string} string             message
+//	@failure 410     {string} string             message
+//	@failure 500     {string} string             message
+//	@router  /api/user/urls/{id} [patch]
+func (sh *ShortedHandler) APIUserUpdateURL(wr http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if err != nil || mediaType != contentTypeJSON {
+		http.Error(wr, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	var body []byte
+
+	if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+		body, err = decompress(r.Body)
+	} else {
+		body, err = io.ReadAll(r.Body)
+	}
+
+	defer r.Body.Close()
+
+	if err != nil {
+		sh.log.Error("error", zap.Error(err))
+		http.Error(wr, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	var updateDTO ShortedUpdateDTO
+
+	if err := json.Unmarshal(body, &updateDTO); err != nil {
+		http.Error(wr, "Error parse body", http.StatusBadRequest)
+		return
+	}
+
+	// url и параметры редиректа меняются одной записью, поэтому запрос не применяется частично
+	redirectUpdate, err := core.NewRedirectUpdate(updateDTO.RedirectType, updateDTO.CacheMaxAge.Value, updateDTO.CacheMaxAge.isNull())
+
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if updateDTO.URL == "" && redirectUpdate.IsEmpty() {
+		http.Error(wr, "url, redirect_type or cache_max_age is required", http.StatusBadRequest)
+		return
+	}
+
+	if updateDTO.URL != "" {
+		if _, err := url.ParseRequestURI(updateDTO.URL); err != nil {
+			http.Error(wr, "Invalid url", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+	id := chi.URLParam(r, "id")
+
+	var shortURL *core.ShortURL
+
+	if updateDTO.URL != "" {
+		shortURL, err = sh.ShorterService.Update(r.Context(), userID, id, updateDTO.URL, redirectUpdate)
+	} else if !redirectUpdate.IsEmpty() {
+		shortURL, err = sh.ShorterService.SetRedirect(r.Context(), userID, id, redirectUpdate)
+	}
+
+	sh.writeUpdatedURL(wr, id, shortURL, err)
+}
+
+// writeCreateConflict write 409 with short url which was created before for same url
+func (sh *ShortedHandler) writeCreateConflict(wr http.ResponseWriter, originID string) {
+	responseBody, err := json.Marshal(ShortedResponseDTO{
+		Result: fmt.Sprintf("%s/%s", sh.baseURL, originID),
+	})
+
+	if err != nil {
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Add("Content-Type", contentTypeJSON)
+	wr.WriteHeader(http.StatusConflict)
+	wr.Write(responseBody)
+}
+
+// writeUpdatedURL write response of change url of short url by result of service
+func (sh *ShortedHandler) writeUpdatedURL(wr http.ResponseWriter, id string, shortURL *core.ShortURL, err error) {
+	var shortURLCreateConflictError *sdb.ShortURLCreateConflictError
+
+	switch {
+	case errors.Is(err, core.ErrShortURLNotFound):
+		http.Error(wr, "Not Found", http.StatusNotFound)
+		return
+	case errors.Is(err, core.ErrNotOwner):
+		http.Error(wr, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	case errors.Is(err, core.ErrShortURLDeleted):
+		http.Error(wr, "Was deleted", http.StatusGone)
+		return
+	case errors.As(err, &shortURLCreateConflictError):
+		sh.writeCreateConflict(wr, shortURLCreateConflictError.OriginID)
+		return
+	case core.IsInvalidRedirect(err):
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		sh.log.Error("update url error", zap.String("id", id), zap.Error(err))
+		http.Error(wr, "error update url", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(ShortedUpdateResponseDTO{
+		ShortURL:     fmt.Sprintf("%s/%s", sh.baseURL, shortURL.ID),
+		OriginalURL:  shortURL.URL,
+		RedirectType: shortURL.RedirectStatus(),
+		CacheMaxAge:  ptrInt64(shortURL.CacheMaxAge),
+	})
+
+	if err != nil {
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Add("Content-Type", contentTypeJSON)
+	wr.WriteHeader(http.StatusOK)
+
+	wr.Write(responseBody)
+}
+
 func decompress(dateRead io.Reader) ([]byte, error) {
 	gr, err := gzip.NewReader(dateRead)
 
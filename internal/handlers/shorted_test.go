@@ -3,7 +3,9 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +14,7 @@ import (
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,8 +22,11 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/pkg/clicks"
+	"github.com/shreyner/go-shortener/internal/repositories"
 	service2 "github.com/shreyner/go-shortener/internal/service"
 	"github.com/shreyner/go-shortener/internal/storage"
+	sdb "github.com/shreyner/go-shortener/internal/storage/store_errors"
 )
 
 // TODO: Проверять сообщения при плохих ответах
@@ -33,8 +39,8 @@ type MyMockService struct {
 	mock.Mock
 }
 
-func (m *MyMockService) Create(_ context.Context, id, url string) (*core.ShortURL, error) {
-	args := m.Called(id, url)
+func (m *MyMockService) Create(_ context.Context, id, url string, opts core.CreateOptions) (*core.ShortURL, error) {
+	args := m.Called(id, url, opts)
 	return args.Get(0).(*core.ShortURL), args.Error(1)
 }
 
@@ -54,6 +60,78 @@ func (m *MyMockService) AllByUser(_ context.Context, id string) ([]*core.ShortUR
 	return shortURLs, args.Error(1)
 }
 
+func (m *MyMockService) ListByUser(_ context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error) {
+	args := m.Called(id, opts)
+
+	page, ok := args.Get(0).(*core.ShortURLPage)
+	if !ok {
+		log.Print("Error in type")
+	}
+
+	return page, args.Error(1)
+}
+
+func (m *MyMockService) EachByUser(_ context.Context, id string, fn func(shortURL *core.ShortURL) error) error {
+	args := m.Called(id)
+
+	shortURLs, ok := args.Get(0).([]*core.ShortURL)
+	if !ok {
+		log.Print("Error in type")
+	}
+
+	for _, shortURL := range shortURLs {
+		if err := fn(shortURL); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *MyMockService) Update(_ context.Context, userID, id, url string, redirect core.RedirectUpdate) (*core.ShortURL, error) {
+	args := m.Called(userID, id, url, redirect)
+
+	shortURL, ok := args.Get(0).(*core.ShortURL)
+	if !ok {
+		log.Print("Error in type")
+	}
+
+	return shortURL, args.Error(1)
+}
+
+func (m *MyMockService) History(_ context.Context, userID, id string) ([]*core.URLVersion, error) {
+	args := m.Called(userID, id)
+
+	versions, ok := args.Get(0).([]*core.URLVersion)
+	if !ok {
+		log.Print("Error in type")
+	}
+
+	return versions, args.Error(1)
+}
+
+func (m *MyMockService) Rollback(_ context.Context, userID, id string, version int) (*core.ShortURL, error) {
+	args := m.Called(userID, id, version)
+
+	shortURL, ok := args.Get(0).(*core.ShortURL)
+	if !ok {
+		log.Print("Error in type")
+	}
+
+	return shortURL, args.Error(1)
+}
+
+func (m *MyMockService) SetRedirect(_ context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error) {
+	args := m.Called(userID, id, update)
+
+	shortURL, ok := args.Get(0).(*core.ShortURL)
+	if !ok {
+		log.Print("Error in type")
+	}
+
+	return shortURL, args.Error(1)
+}
+
 func (m *MyMockService) CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error {
 	args := m.Called(ctx, shortURLs)
 	return args.Error(0)
@@ -123,11 +201,15 @@ func TestShortedHandler_ShortedCreate(t *testing.T) {
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 			"",
 		)
 		ts := httptest.NewServer(r)
 
-		mockService.On("Create", mock.Anything, "https://ya.ru/").Return(
+		mockService.On("Create", mock.Anything, "https://ya.ru/", core.CreateOptions{}).Return(
 			&core.ShortURL{URL: "https://ya.ru/", ID: "ya"},
 			nil,
 		)
@@ -138,7 +220,7 @@ func TestShortedHandler_ShortedCreate(t *testing.T) {
 		defer resp.Body.Close()
 
 		mockService.AssertExpectations(t)
-		mockService.AssertCalled(t, "Create", mock.Anything, "https://ya.ru/")
+		mockService.AssertCalled(t, "Create", mock.Anything, "https://ya.ru/", core.CreateOptions{})
 		assert.Equal(t, http.StatusCreated, resp.StatusCode)
 		assert.Equal(t, "http://localhost:8080/ya", respBody)
 	})
@@ -155,6 +237,10 @@ func TestShortedHandler_ShortedCreate(t *testing.T) {
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 			"",
 		)
 		ts := httptest.NewServer(r)
@@ -181,6 +267,10 @@ func TestShortedHandler_ShortedCreate(t *testing.T) {
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 			"",
 		)
 		ts := httptest.NewServer(r)
@@ -207,6 +297,10 @@ func TestShortedHandler_ShortedCreate(t *testing.T) {
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 			"",
 		)
 		ts := httptest.NewServer(r)
@@ -236,6 +330,21 @@ func TestShortedHandler_ShortedCreate(t *testing.T) {
 	//})
 }
 
+// ClickMockStore store of clicks for tests
+type ClickMockStore struct {
+	clicks []*core.Click
+}
+
+func (s *ClickMockStore) AddClicks(_ context.Context, clicks []*core.Click) error {
+	s.clicks = append(s.clicks, clicks...)
+
+	return nil
+}
+
+func (s *ClickMockStore) GetClickStats(_ context.Context, shortURLID string, from, to time.Time, top int) (*core.ClickStats, error) {
+	return repositories.AggregateClicks(s.clicks, shortURLID, from, to, top), nil
+}
+
 func TestShortedHandler_ShortedGet(t *testing.T) {
 	t.Run("should success redirect", func(t *testing.T) {
 		mockService := new(MyMockService)
@@ -249,6 +358,10 @@ func TestShortedHandler_ShortedGet(t *testing.T) {
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 			"",
 		)
 		ts := httptest.NewServer(r)
@@ -266,6 +379,50 @@ func TestShortedHandler_ShortedGet(t *testing.T) {
 		assert.Equal(t, "https://ya.ru", resp.Header.Get("Location"))
 	})
 
+	t.Run("should record click after redirect", func(t *testing.T) {
+		mockService := new(MyMockService)
+		authMockService := new(AuthMockService)
+		clickStore := &ClickMockStore{}
+		clickRecorder := clicks.NewRecorder(zap.NewNop(), clickStore, 10, 10, time.Hour)
+
+		r := NewRouter(
+			zap.NewNop(),
+			"http://localhost:8080",
+			mockService,
+			authMockService,
+			nil,
+			nil,
+			nil,
+			clickRecorder,
+			nil,
+			nil,
+			nil,
+			"",
+		)
+
+		mockService.On("GetByID", "asdd").Return(&core.ShortURL{ID: "asdd", URL: "https://ya.ru"}, true)
+
+		req := httptest.NewRequest(http.MethodGet, "/asdd", nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		req.Header.Set("Referer", "https://vk.com")
+		req.Header.Set("User-Agent", "curl/7.79.1")
+		wr := httptest.NewRecorder()
+
+		r.ServeHTTP(wr, req)
+		clickRecorder.Close()
+
+		require.Equal(t, http.StatusTemporaryRedirect, wr.Code)
+
+		got := clickStore.clicks
+
+		require.Len(t, got, 1)
+		assert.Equal(t, "asdd", got[0].ShortURLID)
+		assert.Equal(t, "10.0.0.1", got[0].IP)
+		assert.Equal(t, "https://vk.com", got[0].Referrer)
+		assert.Equal(t, "curl/7.79.1", got[0].UserAgent)
+		assert.False(t, got[0].CreatedAt.IsZero())
+	})
+
 	t.Run("should error for not found by id", func(t *testing.T) {
 		mockService := new(MyMockService)
 		authMockService := new(AuthMockService)
@@ -278,6 +435,10 @@ func TestShortedHandler_ShortedGet(t *testing.T) {
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 			"",
 		)
 		ts := httptest.NewServer(r)
@@ -293,6 +454,28 @@ func TestShortedHandler_ShortedGet(t *testing.T) {
 		mockService.AssertCalled(t, "GetByID", "not")
 		require.Equal(t, http.StatusNotFound, resp.StatusCode)
 	})
+
+	t.Run("should error gone for expired", func(t *testing.T) {
+		mockService := new(MyMockService)
+		authMockService := new(AuthMockService)
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+		ts := httptest.NewServer(r)
+
+		mockService.On("GetByID", "asdd").Return(&core.ShortURL{
+			ID:        "asdd",
+			URL:       "https://ya.ru",
+			ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		}, true)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		resp, _ := testRequest(t, ts, http.MethodGet, "/asdd", "", "", "")
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		require.Equal(t, http.StatusGone, resp.StatusCode)
+	})
 }
 
 func TestShortedHandler_ApiCreate(t *testing.T) {
@@ -310,11 +493,15 @@ func TestShortedHandler_ApiCreate(t *testing.T) {
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 			"",
 		)
 		ts := httptest.NewServer(r)
 
-		mockService.On("Create", mock.Anything, "https://ya.ru/").Return(&core.ShortURL{URL: "https://ya.ru/", ID: "ya"}, nil)
+		mockService.On("Create", mock.Anything, "https://ya.ru/", core.CreateOptions{}).Return(&core.ShortURL{URL: "https://ya.ru/", ID: "ya"}, nil)
 		authMockService.On("GenerateUserID").Return("123")
 		authMockService.On("CreateToken", "123").Return("44444")
 
@@ -322,7 +509,7 @@ func TestShortedHandler_ApiCreate(t *testing.T) {
 		defer resp.Body.Close()
 
 		mockService.AssertExpectations(t)
-		mockService.AssertCalled(t, "Create", mock.AnythingOfType("string"), "https://ya.ru/")
+		mockService.AssertCalled(t, "Create", mock.AnythingOfType("string"), "https://ya.ru/", core.CreateOptions{})
 		assert.Equal(t, http.StatusCreated, resp.StatusCode)
 		assert.Equal(t, "{\"result\":\"http://localhost:8080/ya\"}", respBody)
 	})
@@ -333,10 +520,10 @@ func TestShortedHandler_ApiCreate(t *testing.T) {
 		mockService := new(MyMockService)
 		authMockService := new(AuthMockService)
 
-		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, "")
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
 		ts := httptest.NewServer(r)
 
-		mockService.On("Create", mock.Anything, "https://ya.ru/").Return(&core.ShortURL{URL: "https://ya.ru/", ID: "ya"}, nil)
+		mockService.On("Create", mock.Anything, "https://ya.ru/", core.CreateOptions{}).Return(&core.ShortURL{URL: "https://ya.ru/", ID: "ya"}, nil)
 		authMockService.On("GenerateUserID").Return("123")
 		authMockService.On("CreateToken", "123").Return("44444")
 
@@ -344,7 +531,7 @@ func TestShortedHandler_ApiCreate(t *testing.T) {
 		defer resp.Body.Close()
 
 		mockService.AssertExpectations(t)
-		mockService.AssertCalled(t, "Create", mock.AnythingOfType("string"), "https://ya.ru/")
+		mockService.AssertCalled(t, "Create", mock.AnythingOfType("string"), "https://ya.ru/", core.CreateOptions{})
 		assert.Equal(t, http.StatusCreated, resp.StatusCode)
 		assert.Equal(t, resp.Header.Get("Content-Type"), "application/json")
 		assert.Equal(t, "{\"result\":\"http://localhost:8080/ya\"}", respBody)
@@ -364,11 +551,15 @@ func TestShortedHandler_ApiCreate(t *testing.T) {
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 			"",
 		)
 		ts := httptest.NewServer(r)
 
-		mockService.On("Create", mock.Anything, "https://ya.ru/").Return(&core.ShortURL{URL: "https://ya.ru/", ID: "ya"}, nil)
+		mockService.On("Create", mock.Anything, "https://ya.ru/", core.CreateOptions{}).Return(&core.ShortURL{URL: "https://ya.ru/", ID: "ya"}, nil)
 		authMockService.On("GenerateUserID").Return("123")
 		authMockService.On("CreateToken", "123").Return("44444")
 
@@ -384,7 +575,7 @@ func TestShortedHandler_ApiCreate(t *testing.T) {
 		mockService := new(MyMockService)
 		authMockService := new(AuthMockService)
 
-		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, "")
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
 		ts := httptest.NewServer(r)
 
 		authMockService.On("GenerateUserID").Return("123")
@@ -402,7 +593,7 @@ func TestShortedHandler_ApiCreate(t *testing.T) {
 		mockService := new(MyMockService)
 		authMockService := new(AuthMockService)
 
-		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, "")
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
 		ts := httptest.NewServer(r)
 
 		authMockService.On("GenerateUserID").Return("123")
@@ -423,10 +614,11 @@ func BenchmarkShortedHandler_APICreate(b *testing.B) {
 		zap.NewNop(),
 		"",
 		"",
+		nil,
 	)
 	defer memoRepository.Close()
 
-	service := service2.NewShorter(memoRepository.ShortURL)
+	service := service2.NewShorter(memoRepository.ShortURL, nil)
 
 	shortedHandler := NewShortedHandler(
 		zap.NewNop(),
@@ -434,6 +626,7 @@ func BenchmarkShortedHandler_APICreate(b *testing.B) {
 		service,
 		memoRepository.ShortURL,
 		nil,
+		nil,
 	)
 
 	b.ResetTimer()
@@ -456,6 +649,201 @@ func BenchmarkShortedHandler_APICreate(b *testing.B) {
 	}
 }
 
+func TestShortedHandler_ApiCreateExpiration(t *testing.T) {
+	t.Run("should create with ttl", func(t *testing.T) {
+		mockService := new(MyMockService)
+		authMockService := new(AuthMockService)
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+		ts := httptest.NewServer(r)
+
+		now := time.Now()
+		withTTL := mock.MatchedBy(func(opts core.CreateOptions) bool {
+			return opts.ExpiresAt.Valid &&
+				!opts.ExpiresAt.Time.Before(now.Add(time.Hour).Truncate(time.Microsecond)) &&
+				opts.ExpiresAt.Time.Before(now.Add(time.Hour+time.Minute))
+		})
+
+		mockService.On("Create", mock.Anything, "https://ya.ru/", withTTL).Return(&core.ShortURL{URL: "https://ya.ru/", ID: "ya"}, nil)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		resp, _ := testRequest(t, ts, http.MethodPost, "/api/shorten", "application/json", "", `{"url":"https://ya.ru/","ttl":3600}`)
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("should error for incorrect expiration", func(t *testing.T) {
+		mockService := new(MyMockService)
+		authMockService := new(AuthMockService)
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+		ts := httptest.NewServer(r)
+
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		for _, body := range []string{
+			`{"url":"https://ya.ru/","ttl":-1}`,
+			`{"url":"https://ya.ru/","expires_at":"2000-01-01T00:00:00Z"}`,
+			`{"url":"https://ya.ru/","expires_at":"tomorrow"}`,
+			`{"url":"https://ya.ru/","ttl":60,"expires_at":"2100-01-01T00:00:00Z"}`,
+		} {
+			resp, _ := testRequest(t, ts, http.MethodPost, "/api/shorten", "application/json", "", body)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+
+		resp, _ := testRequest(t, ts, http.MethodPost, "/api/shorten/batch", "application/json", "", `[{"correlation_id":"1","original_url":"https://ya.ru/","ttl":-1}]`)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		mockService.AssertNotCalled(t, "Create")
+		mockService.AssertNotCalled(t, "CreateBatch")
+	})
+}
+
+func TestShortedHandler_ApiCreateAlias(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "should create with alias", wantStatus: http.StatusCreated},
+		{name: "should error conflict for taken alias", err: sdb.NewShortURLIDConflictError("spring-sale"), wantStatus: http.StatusConflict},
+		{name: "should error for invalid alias", err: fmt.Errorf("%w: reserved", core.ErrInvalidAlias), wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MyMockService)
+			authMockService := new(AuthMockService)
+
+			r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+			ts := httptest.NewServer(r)
+
+			mockService.
+				On("Create", mock.Anything, "https://ya.ru/", core.CreateOptions{Alias: "spring-sale"}).
+				Return(&core.ShortURL{URL: "https://ya.ru/", ID: "spring-sale"}, tt.err)
+			authMockService.On("GenerateUserID").Return("123")
+			authMockService.On("CreateToken", "123").Return("44444")
+
+			resp, respBody := testRequest(t, ts, http.MethodPost, "/api/shorten", "application/json", "", `{"url":"https://ya.ru/","alias":"spring-sale"}`)
+			defer resp.Body.Close()
+
+			mockService.AssertExpectations(t)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.err == nil {
+				assert.Equal(t, `{"result":"http://localhost:8080/spring-sale"}`, respBody)
+			}
+		})
+	}
+}
+
+func TestShortedHandler_APICreateBatchConflict(t *testing.T) {
+	t.Run("should error conflict with short url of url created before", func(t *testing.T) {
+		mockService := new(MyMockService)
+		authMockService := new(AuthMockService)
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		mockService.On("CreateBatch", mock.Anything, mock.Anything).Return(sdb.NewShortURLCreateConflictError("abc"))
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		resp, respBody := testRequest(t, ts, http.MethodPost, "/api/shorten/batch", "application/json", "", `[{"correlation_id":"1","original_url":"https://ya.ru/"}]`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, `{"result":"http://localhost:8080/abc"}`, respBody)
+	})
+}
+
+func TestShortedHandler_APIUserURLs(t *testing.T) {
+	newServer := func(mockService *MyMockService) *httptest.Server {
+		authMockService := new(AuthMockService)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should return all urls without page params", func(t *testing.T) {
+		mockService := new(MyMockService)
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		mockService.On("AllByUser", "123").Return([]*core.ShortURL{{ID: "ya", URL: "https://ya.ru/"}}, nil)
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/api/user/urls", "", "", "")
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		mockService.AssertNotCalled(t, "ListByUser")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "", resp.Header.Get("X-Next-Cursor"))
+		assert.Equal(t, `[{"short_url":"http://localhost:8080/ya","original_url":"https://ya.ru/"}]`, respBody)
+	})
+
+	t.Run("should return page with next cursor", func(t *testing.T) {
+		mockService := new(MyMockService)
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		opts := core.ListOptions{Limit: 1, Cursor: "abc", SortBy: core.SortByCreatedAt, Desc: true}
+
+		mockService.On("ListByUser", "123", opts).Return(&core.ShortURLPage{
+			ShortURLs:  []*core.ShortURL{{ID: "ya", URL: "https://ya.ru/"}},
+			NextCursor: "next",
+		}, nil)
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/api/user/urls?limit=1&cursor=abc&sort=-created_at", "", "", "")
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "next", resp.Header.Get("X-Next-Cursor"))
+		assert.Equal(t, `[{"short_url":"http://localhost:8080/ya","original_url":"https://ya.ru/"}]`, respBody)
+	})
+
+	t.Run("should error for incorrect page params", func(t *testing.T) {
+		mockService := new(MyMockService)
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		for _, query := range []string{"limit=0", "limit=abc", "limit=1001", "sort=url"} {
+			resp, _ := testRequest(t, ts, http.MethodGet, "/api/user/urls?"+query, "", "", "")
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+
+		mockService.AssertNotCalled(t, "ListByUser")
+	})
+
+	t.Run("should error for invalid cursor", func(t *testing.T) {
+		mockService := new(MyMockService)
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		mockService.On("ListByUser", "123", core.ListOptions{Cursor: "broken"}).Return(nil, repositories.ErrInvalidCursor)
+
+		resp, _ := testRequest(t, ts, http.MethodGet, "/api/user/urls?cursor=broken", "", "", "")
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
 func ExampleShortedHandler_Create() {
 	req, err := http.NewRequest("POST", "http://localhost:8080/", strings.NewReader("https://yandex.ru"))
 
@@ -686,3 +1074,178 @@ func ExampleShortedHandler_APIUserDeleteURLs() {
 		return
 	}
 }
+
+// ClickStatsMockService mock of service of click stats
+type ClickStatsMockService struct {
+	mock.Mock
+}
+
+func (m *ClickStatsMockService) GetStats(_ context.Context, userID, id string, statsRange core.StatsRange) (*core.ClickStats, error) {
+	args := m.Called(userID, id, statsRange)
+
+	stats, _ := args.Get(0).(*core.ClickStats)
+
+	return stats, args.Error(1)
+}
+
+func TestShortedHandler_APIUserUpdateURL(t *testing.T) {
+	newServer := func(mockService *MyMockService) *httptest.Server {
+		authMockService := new(AuthMockService)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should change url of short url", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("Update", "123", "abc", "https://ya.ru", core.RedirectUpdate{}).
+			Return(&core.ShortURL{ID: "abc", URL: "https://ya.ru", UserID: sql.NullString{String: "123", Valid: true}}, nil)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodPatch, "/api/user/urls/abc", "application/json", "", `{"url": "https://ya.ru"}`)
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.JSONEq(t, `{"short_url": "http://localhost:8080/abc", "original_url": "https://ya.ru", "redirect_type": 307}`, respBody)
+	})
+
+	t.Run("should return existing short url on conflict", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("Update", "123", "abc", "https://ya.ru", core.RedirectUpdate{}).Return(nil, sdb.NewShortURLCreateConflictError("def"))
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodPatch, "/api/user/urls/abc", "application/json", "", `{"url": "https://ya.ru"}`)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.JSONEq(t, `{"result": "http://localhost:8080/def"}`, respBody)
+	})
+
+	t.Run("should error by service errors", func(t *testing.T) {
+		tests := []struct {
+			err  error
+			code int
+		}{
+			{err: core.ErrShortURLNotFound, code: http.StatusNotFound},
+			{err: core.ErrNotOwner, code: http.StatusForbidden},
+			{err: core.ErrShortURLDeleted, code: http.StatusGone},
+			{err: errors.New("db is down"), code: http.StatusInternalServerError},
+		}
+
+		for _, tt := range tests {
+			mockService := new(MyMockService)
+			mockService.On("Update", "123", "abc", "https://ya.ru", core.RedirectUpdate{}).Return(nil, tt.err)
+
+			ts := newServer(mockService)
+
+			resp, _ := testRequest(t, ts, http.MethodPatch, "/api/user/urls/abc", "application/json", "", `{"url": "https://ya.ru"}`)
+			resp.Body.Close()
+			ts.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode, tt.err.Error())
+		}
+	})
+
+	t.Run("should error for invalid url", func(t *testing.T) {
+		mockService := new(MyMockService)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, _ := testRequest(t, ts, http.MethodPatch, "/api/user/urls/abc", "application/json", "", `{"url": "not url"}`)
+		defer resp.Body.Close()
+
+		mockService.AssertNotCalled(t, "Update")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestClickStatsHandler_Get(t *testing.T) {
+	day := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	statsRange := core.StatsRange{From: day, To: day.AddDate(0, 0, 2)}
+
+	newServer := func(clickStatsService *ClickStatsMockService) *httptest.Server {
+		authMockService := new(AuthMockService)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", new(MyMockService), authMockService, nil, nil, nil, nil, clickStatsService, nil, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should return stats for owner", func(t *testing.T) {
+		clickStatsService := new(ClickStatsMockService)
+		ts := newServer(clickStatsService)
+		defer ts.Close()
+
+		clickStatsService.On("GetStats", "123", "ya", statsRange).Return(&core.ClickStats{
+			Total:          3,
+			UniqueVisitors: 2,
+			Days:           []core.DayCount{{Day: day, Count: 3}, {Day: day.AddDate(0, 0, 1)}},
+			TopReferrers:   []core.ValueCount{{Value: "https://vk.com", Count: 2}},
+			TopUserAgents:  []core.ValueCount{{Value: "curl", Count: 3}},
+		}, nil)
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/api/user/urls/ya/stats?from=2022-10-01&to=2022-10-02", "", "", "")
+		defer resp.Body.Close()
+
+		clickStatsService.AssertExpectations(t)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"id": "ya",
+			"from": "2022-10-01",
+			"to": "2022-10-02",
+			"total": 3,
+			"unique_visitors": 2,
+			"days": [{"day": "2022-10-01", "clicks": 3}, {"day": "2022-10-02", "clicks": 0}],
+			"top_referrers": [{"value": "https://vk.com", "count": 2}],
+			"top_user_agents": [{"value": "curl", "count": 3}]
+		}`, respBody)
+	})
+
+	t.Run("should error by service errors", func(t *testing.T) {
+		tests := []struct {
+			err  error
+			code int
+		}{
+			{err: core.ErrShortURLNotFound, code: http.StatusNotFound},
+			{err: core.ErrNotOwner, code: http.StatusForbidden},
+			{err: errors.New("db is down"), code: http.StatusInternalServerError},
+		}
+
+		for _, tt := range tests {
+			clickStatsService := new(ClickStatsMockService)
+			ts := newServer(clickStatsService)
+
+			clickStatsService.On("GetStats", "123", "ya", statsRange).Return(nil, tt.err)
+
+			resp, _ := testRequest(t, ts, http.MethodGet, "/api/user/urls/ya/stats?from=2022-10-01&to=2022-10-02", "", "", "")
+			resp.Body.Close()
+			ts.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode, tt.err.Error())
+		}
+	})
+
+	t.Run("should error for incorrect range", func(t *testing.T) {
+		clickStatsService := new(ClickStatsMockService)
+		ts := newServer(clickStatsService)
+		defer ts.Close()
+
+		resp, _ := testRequest(t, ts, http.MethodGet, "/api/user/urls/ya/stats?from=2022-10-02&to=2022-10-01", "", "", "")
+		defer resp.Body.Close()
+
+		clickStatsService.AssertNotCalled(t, "GetStats")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
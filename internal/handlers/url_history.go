@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/middlewares"
+)
+
+// URLVersionDTO version of target url in history
+type URLVersionDTO struct {
+	Version   int    `json:"version" example:"1"`
+	URL       string `json:"url" example:"https://ya.ru"`
+	UserID    string `json:"user_id" example:"b2a5f1a0"`
+	CreatedAt string `json:"created_at" example:"2022-10-01T12:00:00Z"`
+	Current   bool   `json:"current" example:"false"`
+}
+
+// URLHistoryResponseDTO history of target url of short url
+type URLHistoryResponseDTO struct {
+	ShortURL string          `json:"short_url" example:"http://localhost:8080/Sjfnwf"`
+	Versions []URLVersionDTO `json:"versions"`
+}
+
+// URLRollbackDTO data transfer object for request of rollback
+type URLRollbackDTO struct {
+	Version int `json:"version" example:"1"`
+}
+
+// APIUserURLHistory история оригинальной ссылки от создания до текущей версии.
+// История доступна владельцу и после удаления ссылки
+//
+//	@summary История оригинальной ссылки
+//	@tags    apiShorten
+//	@produce json
+//	@param   id  path     string true "Идентификатор короткой ссылки"
+//	@success 200 {object} URLHistoryResponseDTO
+//	@failure 403 {string} string message
+//	@failure 404 {string} string message
+//	@failure 500 {string} string message
+//	@router  /api/user/urls/{id}/history [get]
+func (sh *ShortedHandler) APIUserURLHistory(wr http.ResponseWriter, r *http.Request) {
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+	id := chi.URLParam(r, "id")
+
+	versions, err := sh.ShorterService.History(r.Context(), userID, id)
+
+	if errors.Is(err, core.ErrShortURLNotFound) {
+		http.Error(wr, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, core.ErrNotOwner) {
+		http.Error(wr, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if err != nil {
+		sh.log.Error("get url history error", zap.String("id", id), zap.Error(err))
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	responseDTO := URLHistoryResponseDTO{
+		ShortURL: fmt.Sprintf("%s/%s", sh.baseURL, id),
+		Versions: make([]URLVersionDTO, len(versions)),
+	}
+
+	for i, version := range versions {
+		responseDTO.Versions[i] = URLVersionDTO{
+			Version:   version.Version,
+			URL:       version.URL,
+			UserID:    version.UserID,
+			CreatedAt: version.CreatedAt.UTC().Format(time.RFC3339),
+			Current:   i == len(versions)-1,
+		}
+	}
+
+	responseBody, err := json.Marshal(responseDTO)
+
+	if err != nil {
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Add("Content-Type", contentTypeJSON)
+	wr.WriteHeader(http.StatusOK)
+
+	wr.Write(responseBody)
+}
+
+// APIUserURLRollback вернуть оригинальную ссылку к версии из истории.
+// Откат записывается в историю новой версией
+//
+//	@summary Откат оригинальной ссылки к версии
+//	@tags    apiShorten
+//	@accept  json
+//	@produce json
+//	@param   id      path     string         true "Идентификатор короткой ссылки"
+//	@param   request body     URLRollbackDTO true "Версия из истории"
+//	@success 200     {object} ShortedUpdateResponseDTO
+//	@failure 409     {object} ShortedResponseDTO Ранее созданная короткая ссылка с url версии
+//	@failure 400     {string} string             message
+//	@failure 403     {string} string             message
+//	@failure 404     {string} string             message
+//	@failure 410     {string} string             message
+//	@failure 500     {string} string             message
+//	@router  /api/user/urls/{id}/rollback [post]
+func (sh *ShortedHandler) APIUserURLRollback(wr http.ResponseWriter, r *http.Request) {
+	var rollbackDTO URLRollbackDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&rollbackDTO); err != nil {
+		http.Error(wr, "Error parse body", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+	id := chi.URLParam(r, "id")
+
+	shortURL, err := sh.ShorterService.Rollback(r.Context(), userID, id, rollbackDTO.Version)
+
+	if errors.Is(err, core.ErrURLVersionNotFound) {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sh.writeUpdatedURL(wr, id, shortURL, err)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+func TestShortedHandler_APIUserURLHistory(t *testing.T) {
+	createdAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	newServer := func(mockService *MyMockService) *httptest.Server {
+		authMockService := new(AuthMockService)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", mockService, authMockService, nil, nil, nil, nil, nil, nil, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should return versions of url", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("History", "123", "abc").Return([]*core.URLVersion{
+			{Version: 1, URL: "https://ya.ru/typo", UserID: "123", CreatedAt: createdAt},
+			{Version: 2, URL: "https://ya.ru", UserID: "123", CreatedAt: createdAt.Add(time.Hour)},
+		}, nil)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodGet, "/api/user/urls/abc/history", "", "", "")
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.JSONEq(t, `{
+			"short_url": "http://localhost:8080/abc",
+			"versions": [
+				{"version": 1, "url": "https://ya.ru/typo", "user_id": "123", "created_at": "2022-10-01T12:00:00Z", "current": false},
+				{"version": 2, "url": "https://ya.ru", "user_id": "123", "created_at": "2022-10-01T13:00:00Z", "current": true}
+			]
+		}`, respBody)
+	})
+
+	t.Run("should error by service errors", func(t *testing.T) {
+		tests := []struct {
+			err  error
+			code int
+		}{
+			{err: core.ErrShortURLNotFound, code: http.StatusNotFound},
+			{err: core.ErrNotOwner, code: http.StatusForbidden},
+			{err: errors.New("db is down"), code: http.StatusInternalServerError},
+		}
+
+		for _, tt := range tests {
+			mockService := new(MyMockService)
+			mockService.On("History", "123", "abc").Return(nil, tt.err)
+
+			ts := newServer(mockService)
+
+			resp, _ := testRequest(t, ts, http.MethodGet, "/api/user/urls/abc/history", "", "", "")
+			resp.Body.Close()
+			ts.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode, tt.err.Error())
+		}
+	})
+
+	t.Run("should rollback to version", func(t *testing.T) {
+		mockService := new(MyMockService)
+		mockService.On("Rollback", "123", "abc", 1).
+			Return(&core.ShortURL{ID: "abc", URL: "https://ya.ru/typo", UserID: sql.NullString{String: "123", Valid: true}}, nil)
+
+		ts := newServer(mockService)
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodPost, "/api/user/urls/abc/rollback", "application/json", "", `{"version": 1}`)
+		defer resp.Body.Close()
+
+		mockService.AssertExpectations(t)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.JSONEq(t, `{"short_url": "http://localhost:8080/abc", "original_url": "https://ya.ru/typo", "redirect_type": 307}`, respBody)
+	})
+
+	t.Run("should error rollback by service errors", func(t *testing.T) {
+		tests := []struct {
+			err  error
+			code int
+		}{
+			{err: core.ErrURLVersionNotFound, code: http.StatusBadRequest},
+			{err: core.ErrShortURLDeleted, code: http.StatusGone},
+			{err: core.ErrNotOwner, code: http.StatusForbidden},
+		}
+
+		for _, tt := range tests {
+			mockService := new(MyMockService)
+			mockService.On("Rollback", "123", "abc", 7).Return(nil, tt.err)
+
+			ts := newServer(mockService)
+
+			resp, _ := testRequest(t, ts, http.MethodPost, "/api/user/urls/abc/rollback", "application/json", "", `{"version": 7}`)
+			resp.Body.Close()
+			ts.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode, tt.err.Error())
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/middlewares"
+)
+
+type webhookService interface {
+	Create(ctx context.Context, userID, url string, events []string) (*core.Webhook, error)
+	List(ctx context.Context, userID string) ([]*core.Webhook, error)
+	Delete(ctx context.Context, userID, id string) error
+}
+
+// WebhooksHandler handlers of webhooks of user
+type WebhooksHandler struct {
+	log     *zap.Logger
+	service webhookService
+}
+
+// NewWebhooksHandler create instance
+func NewWebhooksHandler(log *zap.Logger, service webhookService) *WebhooksHandler {
+	return &WebhooksHandler{
+		log:     log,
+		service: service,
+	}
+}
+
+// WebhookRequestDTO data transfer object for create webhook
+type WebhookRequestDTO struct {
+	URL    string   `json:"url" example:"https://example.com/hook"`
+	Events []string `json:"events" example:"created,clicked"`
+}
+
+// WebhookDTO webhook of user. Secret is returned only on create
+type WebhookDTO struct {
+	ID        string   `json:"id" example:"Kd93jdLs0aQe"`
+	URL       string   `json:"url" example:"https://example.com/hook"`
+	Events    []string `json:"events" example:"created,clicked"`
+	Secret    string   `json:"secret,omitempty" example:"mD8s0cK2..."`
+	Enabled   bool     `json:"enabled" example:"true"`
+	Failures  int      `json:"failures" example:"0"`
+	CreatedAt string   `json:"created_at" example:"2022-10-01T12:00:00Z"`
+}
+
+func newWebhookDTO(webhook *core.Webhook) WebhookDTO {
+	events := make([]string, len(webhook.Events))
+
+	for i, event := range webhook.Events {
+		events[i] = string(event)
+	}
+
+	return WebhookDTO{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    events,
+		Enabled:   !webhook.Disabled,
+		Failures:  webhook.Failures,
+		CreatedAt: webhook.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// Create подписка на события своих коротких ссылок
+//
+//	@summary Создать webhook
+//	@tags    webhooks
+//	@accept  json
+//	@produce json
+//	@param   webhook body     WebhookRequestDTO true "URL получателя и типы событий: created, deleted, expired, clicked. По умолчанию все"
+//	@success 201     {object} WebhookDTO
+//	@failure 400     {string} string message
+//	@failure 500     {string} string message
+//	@router  /api/user/webhooks [post]
+func (h *WebhooksHandler) Create(wr http.ResponseWriter, r *http.Request) {
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		http.Error(wr, "error read body", http.StatusBadRequest)
+		return
+	}
+
+	var requestDTO WebhookRequestDTO
+
+	if err := json.Unmarshal(body, &requestDTO); err != nil {
+		http.Error(wr, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.service.Create(r.Context(), userID, requestDTO.URL, requestDTO.Events)
+
+	if errors.Is(err, core.ErrInvalidWebhook) || errors.Is(err, core.ErrTooManyWebhooks) {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		h.log.Error("create webhook error", zap.Error(err))
+		http.Error(wr, "error create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	responseDTO := newWebhookDTO(webhook)
+	responseDTO.Secret = webhook.Secret
+
+	h.writeJSON(wr, http.StatusCreated, responseDTO)
+}
+
+// List webhooks пользователя
+//
+//	@summary Список webhooks
+//	@tags    webhooks
+//	@produce json
+//	@success 200 {array}  WebhookDTO
+//	@failure 500 {string} string message
+//	@router  /api/user/webhooks [get]
+func (h *WebhooksHandler) List(wr http.ResponseWriter, r *http.Request) {
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+
+	webhooks, err := h.service.List(r.Context(), userID)
+
+	if err != nil {
+		h.log.Error("list webhooks error", zap.Error(err))
+		http.Error(wr, "error get webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	responseDTO := make([]WebhookDTO, len(webhooks))
+
+	for i, webhook := range webhooks {
+		responseDTO[i] = newWebhookDTO(webhook)
+	}
+
+	h.writeJSON(wr, http.StatusOK, responseDTO)
+}
+
+// Delete webhook пользователя
+//
+//	@summary Удалить webhook
+//	@tags    webhooks
+//	@param   id  path     string true "Идентификатор webhook"
+//	@success 204
+//	@failure 404 {string} string message
+//	@failure 500 {string} string message
+//	@router  /api/user/webhooks/{id} [delete]
+func (h *WebhooksHandler) Delete(wr http.ResponseWriter, r *http.Request) {
+	userID, _ := middlewares.GetUserIDCtx(r.Context())
+	id := chi.URLParam(r, "id")
+
+	err := h.service.Delete(r.Context(), userID, id)
+
+	if errors.Is(err, core.ErrWebhookNotFound) {
+		http.Error(wr, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		h.log.Error("delete webhook error", zap.String("id", id), zap.Error(err))
+		http.Error(wr, "error delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	wr.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhooksHandler) writeJSON(wr http.ResponseWriter, status int, data interface{}) {
+	body, err := json.Marshal(data)
+
+	if err != nil {
+		h.log.Error("json marshal webhooks error", zap.Error(err))
+		http.Error(wr, "error create response", http.StatusInternalServerError)
+		return
+	}
+
+	wr.Header().Add("Content-Type", "application/json")
+	wr.WriteHeader(status)
+	wr.Write(body)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/service"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+)
+
+func TestWebhooksHandler(t *testing.T) {
+	newServer := func() *httptest.Server {
+		authMockService := new(AuthMockService)
+		authMockService.On("GenerateUserID").Return("123")
+		authMockService.On("CreateToken", "123").Return("44444")
+
+		webhookService := service.NewWebhookService(storagememory.NewWebhookStore())
+
+		r := NewRouter(zap.NewNop(), "http://localhost:8080", new(MyMockService), authMockService, nil, nil, nil, nil, nil, webhookService, nil, "")
+
+		return httptest.NewServer(r)
+	}
+
+	t.Run("should create, list and delete webhook", func(t *testing.T) {
+		ts := newServer()
+		defer ts.Close()
+
+		resp, respBody := testRequest(t, ts, http.MethodPost, "/api/user/webhooks", "application/json", "", `{"url": "https://example.com/hook", "events": ["created", "clicked"]}`)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var created WebhookDTO
+		require.NoError(t, json.Unmarshal([]byte(respBody), &created))
+
+		assert.NotEmpty(t, created.ID)
+		assert.NotEmpty(t, created.Secret)
+		assert.Equal(t, "https://example.com/hook", created.URL)
+		assert.Equal(t, []string{"created", "clicked"}, created.Events)
+		assert.True(t, created.Enabled)
+
+		resp, respBody = testRequest(t, ts, http.MethodGet, "/api/user/webhooks", "", "", "")
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var list []WebhookDTO
+		require.NoError(t, json.Unmarshal([]byte(respBody), &list))
+		require.Equal(t, 1, len(list))
+		assert.Equal(t, created.ID, list[0].ID)
+		assert.Empty(t, list[0].Secret)
+
+		resp, _ = testRequest(t, ts, http.MethodDelete, "/api/user/webhooks/"+created.ID, "", "", "")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp, _ = testRequest(t, ts, http.MethodDelete, "/api/user/webhooks/"+created.ID, "", "", "")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("should error for incorrect webhook", func(t *testing.T) {
+		ts := newServer()
+		defer ts.Close()
+
+		for _, body := range []string{
+			`{"url": "ftp://example.com"}`,
+			`{"url": "https://example.com", "events": ["updated"]}`,
+			`{"url": `,
+		} {
+			resp, _ := testRequest(t, ts, http.MethodPost, "/api/user/webhooks", "application/json", "", body)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		}
+	})
+}
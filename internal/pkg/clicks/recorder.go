@@ -0,0 +1,147 @@
+// Package clicks async record of redirect events. Events go through bounded queue
+// and are written by background writer in batches, so redirect never wait store
+package clicks
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+var (
+	writeTimeout = 10 * time.Second
+)
+
+type clickWriter interface {
+	AddClicks(ctx context.Context, clicks []*core.Click) error
+}
+
+// Recorder queue of clicks with background writer
+type Recorder struct {
+	log   *zap.Logger
+	store clickWriter
+
+	queue         chan *core.Click
+	batchSize     int
+	flushInterval time.Duration
+
+	mutex  sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	dropped atomic.Uint64
+}
+
+// NewRecorder create recorder and start writer. queueSize is limit of not written clicks,
+// batch is written when has batchSize clicks or every flushInterval
+func NewRecorder(
+	log *zap.Logger,
+	store clickWriter,
+	queueSize int,
+	batchSize int,
+	flushInterval time.Duration,
+) *Recorder {
+	r := &Recorder{
+		log:           log,
+		store:         store,
+		queue:         make(chan *core.Click, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+
+	r.wg.Add(1)
+	go r.writer()
+
+	return r
+}
+
+// Record add click in queue without wait. When queue is full click is dropped and false returned
+func (r *Recorder) Record(click *core.Click) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return false
+	}
+
+	select {
+	case r.queue <- click:
+		return true
+	default:
+		if r.dropped.Add(1)%1000 == 1 {
+			r.log.Warn("click queue is full, clicks are dropped", zap.Uint64("dropped", r.dropped.Load()))
+		}
+
+		return false
+	}
+}
+
+// Dropped count of clicks which was dropped by full queue
+func (r *Recorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Close stop accept clicks and wait write of queue
+func (r *Recorder) Close() {
+	r.mutex.Lock()
+
+	if r.closed {
+		r.mutex.Unlock()
+		return
+	}
+
+	r.closed = true
+	close(r.queue)
+	r.mutex.Unlock()
+
+	r.wg.Wait()
+}
+
+func (r *Recorder) writer() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*core.Click, 0, r.batchSize)
+
+	for {
+		select {
+		case click, ok := <-r.queue:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+
+			batch = append(batch, click)
+
+			if len(batch) >= r.batchSize {
+				r.flush(batch)
+				batch = make([]*core.Click, 0, r.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.flush(batch)
+				batch = make([]*core.Click, 0, r.batchSize)
+			}
+		}
+	}
+}
+
+func (r *Recorder) flush(batch []*core.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	if err := r.store.AddClicks(ctx, batch); err != nil {
+		r.log.Error("error write clicks", zap.Int("count", len(batch)), zap.Error(err))
+	}
+}
@@ -0,0 +1,105 @@
+package clicks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+type testClickStore struct {
+	mutex   sync.Mutex
+	batches [][]*core.Click
+	block   chan struct{}
+}
+
+func (s *testClickStore) AddClicks(_ context.Context, clicks []*core.Click) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.batches = append(s.batches, clicks)
+
+	return nil
+}
+
+func (s *testClickStore) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	count := 0
+
+	for _, batch := range s.batches {
+		count += len(batch)
+	}
+
+	return count
+}
+
+func TestRecorder(t *testing.T) {
+	t.Run("should write clicks in batches", func(t *testing.T) {
+		store := &testClickStore{}
+		r := NewRecorder(zap.NewNop(), store, 100, 3, time.Hour)
+
+		for i := 0; i < 7; i++ {
+			require.True(t, r.Record(&core.Click{ShortURLID: "a"}))
+		}
+
+		r.Close()
+
+		require.Len(t, store.batches, 3)
+		assert.Len(t, store.batches[0], 3)
+		assert.Len(t, store.batches[1], 3)
+		assert.Len(t, store.batches[2], 1)
+	})
+
+	t.Run("should flush not full batch by interval", func(t *testing.T) {
+		store := &testClickStore{}
+		r := NewRecorder(zap.NewNop(), store, 100, 100, 10*time.Millisecond)
+		defer r.Close()
+
+		require.True(t, r.Record(&core.Click{ShortURLID: "a"}))
+
+		assert.Eventually(t, func() bool { return store.count() == 1 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("should drop clicks without blocking when queue is full", func(t *testing.T) {
+		store := &testClickStore{block: make(chan struct{})}
+		r := NewRecorder(zap.NewNop(), store, 2, 1, time.Hour)
+
+		recorded := 0
+
+		for i := 0; i < 10; i++ {
+			if r.Record(&core.Click{ShortURLID: "a"}) {
+				recorded++
+			}
+		}
+
+		assert.Less(t, recorded, 10)
+		assert.Equal(t, uint64(10-recorded), r.Dropped())
+
+		close(store.block)
+		r.Close()
+
+		assert.Equal(t, recorded, store.count())
+	})
+
+	t.Run("should not record after close", func(t *testing.T) {
+		store := &testClickStore{}
+		r := NewRecorder(zap.NewNop(), store, 10, 10, time.Hour)
+		r.Close()
+		r.Close()
+
+		assert.False(t, r.Record(&core.Click{ShortURLID: "a"}))
+		assert.Equal(t, 0, store.count())
+	})
+}
@@ -29,3 +29,8 @@ func NewDataBase(log *zap.Logger, dburi string) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// OpenDataBase returning sql.DB without check of connection, for connections which are checked later
+func OpenDataBase(dburi string) (*sql.DB, error) {
+	return sql.Open("pgx", dburi)
+}
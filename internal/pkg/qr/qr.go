@@ -0,0 +1,147 @@
+// Package qr render QR code of text into PNG or SVG with size and margin
+//
+//	code, err := qr.Encode("http://localhost:8080/Sjfnwf", qr.LevelMedium)
+//	png, err := code.PNG(256, 4)
+//	svg := code.SVG(256, 4)
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Level error correction level of QR code
+type Level = qrcode.RecoveryLevel
+
+// Levels of error correction, letters are same as in QR specification
+const (
+	LevelLow      Level = qrcode.Low
+	LevelMedium   Level = qrcode.Medium
+	LevelQuartile Level = qrcode.High
+	LevelHigh     Level = qrcode.Highest
+)
+
+// ErrInvalidLevel level isn't one of L, M, Q, H
+var ErrInvalidLevel = errors.New("error correction level should be L, M, Q or H")
+
+// ParseLevel level by letter L, M, Q or H in any case
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return LevelLow, nil
+	case "M":
+		return LevelMedium, nil
+	case "Q":
+		return LevelQuartile, nil
+	case "H":
+		return LevelHigh, nil
+	default:
+		return 0, ErrInvalidLevel
+	}
+}
+
+// Code encoded QR code, modules are without quiet zone
+type Code struct {
+	modules [][]bool
+}
+
+// Encode text into QR code with error correction level
+func Encode(text string, level Level) (*Code, error) {
+	q, err := qrcode.New(text, level)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q.DisableBorder = true
+
+	return &Code{modules: q.Bitmap()}, nil
+}
+
+// Modules count of modules by side without margin
+func (c *Code) Modules() int {
+	return len(c.modules)
+}
+
+// PNG render code into square image size x size with margin in modules.
+// Module is scaled by whole pixels, remainder of size is added to margin
+func (c *Code) PNG(size, margin int) ([]byte, error) {
+	side := c.Modules() + 2*margin
+	scale := size / side
+
+	if scale < 1 {
+		scale = 1
+	}
+
+	if size < side*scale {
+		size = side * scale
+	}
+
+	offset := (size - c.Modules()*scale) / 2
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+
+	for y, row := range c.modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// SVG render code into vector image size x size with margin in modules.
+// Dark modules of row are merged into one rect path
+func (c *Code) SVG(size, margin int) []byte {
+	side := c.Modules() + 2*margin
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(
+		&b,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, side, side,
+	)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, side, side)
+
+	for y, row := range c.modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+
+			start := x
+
+			for x < len(row) && row[x] {
+				x++
+			}
+
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start+margin, y+margin, x-start, x-start)
+		}
+	}
+
+	b.WriteString(`"/></svg>`)
+
+	return b.Bytes()
+}
@@ -0,0 +1,76 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	t.Run("should parse letters in any case", func(t *testing.T) {
+		for s, want := range map[string]Level{"L": LevelLow, "m": LevelMedium, "Q": LevelQuartile, "h": LevelHigh} {
+			level, err := ParseLevel(s)
+
+			require.NoError(t, err)
+			assert.Equal(t, want, level, s)
+		}
+
+		_, err := ParseLevel("X")
+		assert.ErrorIs(t, err, ErrInvalidLevel)
+	})
+}
+
+func TestCode(t *testing.T) {
+	code, err := Encode("http://localhost:8080/Sjfnwf", LevelMedium)
+	require.NoError(t, err)
+
+	t.Run("should encode without quiet zone", func(t *testing.T) {
+		// Версия 3 для 28 байт с уровнем M
+		assert.Equal(t, 29, code.Modules())
+
+		high, err := Encode("http://localhost:8080/Sjfnwf", LevelHigh)
+		require.NoError(t, err)
+		assert.Greater(t, high.Modules(), code.Modules())
+	})
+
+	t.Run("should render png of requested size with margin", func(t *testing.T) {
+		body, err := code.PNG(256, 4)
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(body))
+		require.NoError(t, err)
+
+		assert.Equal(t, 256, img.Bounds().Dx())
+		assert.Equal(t, 256, img.Bounds().Dy())
+
+		// 37 модулей по 6 пикселей, левый верхний модуль кода черный, отступ белый
+		offset := (256 - 29*6) / 2
+		r, _, _, _ := img.At(offset, offset).RGBA()
+		assert.Equal(t, uint32(0), r)
+
+		r, _, _, _ = img.At(offset-1, offset-1).RGBA()
+		assert.Equal(t, uint32(0xffff), r)
+	})
+
+	t.Run("should grow png when size is less than modules", func(t *testing.T) {
+		body, err := code.PNG(10, 2)
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(body))
+		require.NoError(t, err)
+
+		assert.Equal(t, 33, img.Bounds().Dx())
+	})
+
+	t.Run("should render svg with margin in view box", func(t *testing.T) {
+		svg := string(code.SVG(512, 2))
+
+		assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="512" height="512" viewBox="0 0 33 33"`))
+		assert.Contains(t, svg, `M2 2h7v1h-7z`)
+		assert.True(t, strings.HasSuffix(svg, `"/></svg>`))
+	})
+}
@@ -23,15 +23,20 @@ var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ123456
 
 // RandSeq random string by based letters
 func RandSeq(n int) string {
+	return RandSeqFrom(letters, n)
+}
+
+// RandSeqFrom random string by characters of alphabet
+func RandSeqFrom(alphabet []rune, n int) string {
 	b := make([]rune, n)
 	for i := range b {
-		rn, err := cryptoRandomInt(0, len(letters)-1)
+		rn, err := cryptoRandomInt(0, len(alphabet)-1)
 
 		if err != nil {
 			panic(err)
 		}
 
-		b[i] = letters[rn]
+		b[i] = alphabet[rn]
 	}
 	return string(b)
 }
@@ -0,0 +1,189 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// Headers of request to webhook
+const (
+	HeaderSignature = "X-Shortener-Signature" // sha256=<hex of HMAC-SHA256 of body with secret of webhook>
+	HeaderEvent     = "X-Shortener-Event"     // type of event
+	HeaderDelivery  = "X-Shortener-Delivery"  // ID of delivery, same for all attempts
+)
+
+// maxResponseBody read of response body for reuse connection
+const maxResponseBody = 64 << 10
+
+// errNotPublicAddress webhook host resolved into internal address
+var errNotPublicAddress = errors.New("webhook address is not public")
+
+// Payload body of request to webhook
+type Payload struct {
+	DeliveryID string `json:"delivery_id"`
+	EventID    int64  `json:"event_id,omitempty"`
+	Type       string `json:"type"`
+	ShortURLID string `json:"short_url_id"`
+	URL        string `json:"url,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	Referrer   string `json:"referrer,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+}
+
+// delivery one event for one webhook
+type delivery struct {
+	webhook   *core.Webhook
+	id        string
+	eventID   int64 // ID of event of feed, zero for clicks
+	eventType core.EventType
+	body      []byte
+	attempt   int
+}
+
+// newEventDelivery delivery of event of feed. ID depends only on event and webhook,
+// so receiver can drop duplicates after restart of dispatcher
+func newEventDelivery(webhook *core.Webhook, event *core.Event) (*delivery, error) {
+	id := strconv.FormatInt(event.ID, 10) + "-" + webhook.ID
+
+	job, err := newDelivery(webhook, event.Type, &Payload{
+		DeliveryID: id,
+		EventID:    event.ID,
+		Type:       string(event.Type),
+		ShortURLID: event.ShortURLID,
+		URL:        event.URL,
+		CreatedAt:  event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	job.eventID = event.ID
+
+	return job, nil
+}
+
+func newClickDelivery(webhook *core.Webhook, click *core.Click, id string) (*delivery, error) {
+	return newDelivery(webhook, core.EventClicked, &Payload{
+		DeliveryID: id,
+		Type:       string(core.EventClicked),
+		ShortURLID: click.ShortURLID,
+		CreatedAt:  click.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Referrer:   click.Referrer,
+		UserAgent:  click.UserAgent,
+	})
+}
+
+func newDelivery(webhook *core.Webhook, eventType core.EventType, payload *Payload) (*delivery, error) {
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery{
+		webhook:   webhook,
+		id:        payload.DeliveryID,
+		eventType: eventType,
+		body:      body,
+	}, nil
+}
+
+// Sign signature of body for header X-Shortener-Signature
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type sender struct {
+	client  *http.Client
+	timeout time.Duration
+}
+
+// newSender client of webhooks. Address is checked after resolve, right before connect,
+// so host can't point to internal network by DNS. Redirects are not followed
+func newSender(timeout time.Duration, allowPrivateNetworks bool) *sender {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	if !allowPrivateNetworks {
+		dialer.Control = checkPublicAddress
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &sender{
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		timeout: timeout,
+	}
+}
+
+// checkPublicAddress control of dialer, reject connect to not public IP
+func checkPublicAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !core.IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", errNotPublicAddress, host)
+	}
+
+	return nil
+}
+
+// send POST body to webhook. Any response except 2xx is error, redirect too
+func (s *sender) send(ctx context.Context, job *delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.webhook.URL, bytes.NewReader(job.body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(job.eventType))
+	req.Header.Set(HeaderDelivery, job.id)
+	req.Header.Set(HeaderSignature, Sign(job.webhook.Secret, job.body))
+
+	resp, err := s.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
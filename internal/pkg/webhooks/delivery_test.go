@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+func TestSender(t *testing.T) {
+	ctx := context.Background()
+
+	newJob := func(url string) *delivery {
+		return &delivery{
+			webhook:   &core.Webhook{ID: "hook1", URL: url, Secret: "secret"},
+			id:        "1-hook1",
+			eventType: core.EventCreated,
+			body:      []byte(`{}`),
+		}
+	}
+
+	t.Run("should not connect to internal address", func(t *testing.T) {
+		receiver := &testReceiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		err := newSender(time.Second, false).send(ctx, newJob(server.URL))
+
+		assert.ErrorIs(t, err, errNotPublicAddress)
+		assert.Empty(t, receiver.received())
+	})
+
+	t.Run("should not follow redirect", func(t *testing.T) {
+		receiver := &testReceiver{}
+		internal := httptest.NewServer(receiver)
+		defer internal.Close()
+
+		redirect := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusTemporaryRedirect))
+		defer redirect.Close()
+
+		err := newSender(time.Second, true).send(ctx, newJob(redirect.URL))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "307")
+		assert.Empty(t, receiver.received())
+	})
+}
+
+func Test_checkPublicAddress(t *testing.T) {
+	t.Run("should check resolved address", func(t *testing.T) {
+		for _, address := range []string{"127.0.0.1:80", "169.254.169.254:80", "192.168.0.1:443", "[::1]:80", "0.0.0.0:80"} {
+			assert.ErrorIs(t, checkPublicAddress("tcp", address, nil), errNotPublicAddress, address)
+		}
+
+		assert.NoError(t, checkPublicAddress("tcp", "93.184.216.34:443", nil))
+	})
+}
@@ -0,0 +1,461 @@
+// Package webhooks delivery of events of short urls to webhooks of their owners.
+//
+// Dispatcher tails feed of events from outbox (created, deleted, expired) and receives written clicks,
+// makes delivery for every subscribed webhook of owner and puts it into one queue, which is read by pool of workers
+// (fan-out as in package fans). Failed delivery is retried with exponential backoff, after DisableAfter
+// failed deliveries in a row webhook is disabled.
+//
+// Events of feed are delivered at least once: saved cursor is moved only over events all deliveries of which
+// are finished (delivered or given up), so after restart unfinished deliveries are sent again with same delivery ID
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/pkg/random"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+// Options params of delivery. Zero values are replaced by defaults
+type Options struct {
+	// Workers count of concurrent requests to webhooks
+	Workers int
+	// QueueSize limit of deliveries waiting worker
+	QueueSize int
+	// PollInterval interval of read of new events from feed
+	PollInterval time.Duration
+	// BatchSize count of events read from feed by one request
+	BatchSize int
+	// Timeout of one request to webhook
+	Timeout time.Duration
+	// MaxAttempts attempts of one delivery, include first
+	MaxAttempts int
+	// Backoff delay before first retry, every next delay is doubled up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// DisableAfter count of failed deliveries in a row after which webhook is disabled
+	DisableAfter int
+	// AllowPrivateNetworks allow delivery to loopback and private addresses, only for tests and local development
+	AllowPrivateNetworks bool
+}
+
+func (o *Options) setDefaults() {
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+
+	if o.DisableAfter <= 0 {
+		o.DisableAfter = 10
+	}
+}
+
+type shortURLGetter interface {
+	GetByID(ctx context.Context, id string) (*core.ShortURL, bool)
+}
+
+// ClickWriter store of clicks
+type ClickWriter interface {
+	AddClicks(ctx context.Context, clicks []*core.Click) error
+}
+
+// Dispatcher queue of deliveries with poller of feed and workers
+type Dispatcher struct {
+	log       *zap.Logger
+	events    repositories.EventRepository
+	webhooks  repositories.WebhookRepository
+	shortURLs shortURLGetter
+	sender    *sender
+	opts      Options
+
+	queue    chan *delivery
+	ctx      context.Context
+	cancel   context.CancelFunc
+	cursor   int64 // ID of last event read from feed
+	progress *progress
+
+	poller  sync.WaitGroup
+	workers sync.WaitGroup
+}
+
+// NewDispatcher create dispatcher and start poller of feed and workers.
+// Feed is read after cursor saved in webhooks store, so events are not lost between restarts
+func NewDispatcher(
+	log *zap.Logger,
+	events repositories.EventRepository,
+	webhooks repositories.WebhookRepository,
+	shortURLs shortURLGetter,
+	opts Options,
+) *Dispatcher {
+	opts.setDefaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &Dispatcher{
+		log:       log,
+		events:    events,
+		webhooks:  webhooks,
+		shortURLs: shortURLs,
+		sender:    newSender(opts.Timeout, opts.AllowPrivateNetworks),
+		opts:      opts,
+		queue:     make(chan *delivery, opts.QueueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		cursor:    -1,
+	}
+
+	for i := 0; i < opts.Workers; i++ {
+		d.workers.Add(1)
+		go d.worker()
+	}
+
+	d.poller.Add(1)
+	go d.pollLoop()
+
+	return d
+}
+
+// Close stop poller and workers. Deliveries in queue and waiting retry are dropped,
+// their events are after saved cursor and will be read from feed after restart
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.poller.Wait()
+	d.workers.Wait()
+}
+
+// NotifyClicks wrap store of clicks. Written clicks are passed to webhooks of owners of short urls.
+// Clicks are not in outbox, so their delivery is best effort: when queue is full they are dropped
+func (d *Dispatcher) NotifyClicks(store ClickWriter) ClickWriter {
+	return &notifyingClickWriter{store: store, dispatcher: d}
+}
+
+type notifyingClickWriter struct {
+	store      ClickWriter
+	dispatcher *Dispatcher
+}
+
+// AddClicks write clicks into store and then notify webhooks
+func (w *notifyingClickWriter) AddClicks(ctx context.Context, clicks []*core.Click) error {
+	if err := w.store.AddClicks(ctx, clicks); err != nil {
+		return err
+	}
+
+	w.dispatcher.dispatchClicks(ctx, clicks)
+
+	return nil
+}
+
+func (d *Dispatcher) pollLoop() {
+	defer d.poller.Done()
+
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.poll(); err != nil && d.ctx.Err() == nil {
+			d.log.Error("error poll events for webhooks", zap.Error(err))
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll pass to delivery all new events of feed. Cursor is saved when deliveries of events are finished
+func (d *Dispatcher) poll() error {
+	if d.cursor < 0 {
+		cursor, err := d.webhooks.WebhookCursor(d.ctx)
+
+		if err != nil {
+			return fmt.Errorf("error load cursor: %w", err)
+		}
+
+		d.cursor = cursor
+		d.progress = newProgress(cursor)
+	}
+
+	for {
+		events, err := d.events.EventsAfter(d.ctx, d.cursor, d.opts.BatchSize)
+
+		if err != nil {
+			return err
+		}
+
+		if len(events) == 0 {
+			return nil
+		}
+
+		userWebhooks := map[string][]*core.Webhook{}
+
+		for _, event := range events {
+			webhooks, err := d.webhooksOfUser(userWebhooks, event.UserID)
+
+			if err != nil {
+				return err
+			}
+
+			jobs := make([]*delivery, 0, len(webhooks))
+
+			for _, webhook := range webhooks {
+				if !webhook.Subscribed(event.Type) {
+					continue
+				}
+
+				job, err := newEventDelivery(webhook, event)
+
+				if err != nil {
+					return err
+				}
+
+				jobs = append(jobs, job)
+			}
+
+			// Событие учитывается до постановки в очередь, чтобы быстрая доставка не обогнала его
+			d.progress.add(event.ID, len(jobs))
+			d.cursor = event.ID
+
+			for _, job := range jobs {
+				if !d.enqueue(job) {
+					return nil
+				}
+			}
+		}
+
+		d.saveCursor()
+
+		if len(events) < d.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// dispatchClicks find owners of short urls and put deliveries of clicks without wait of queue
+func (d *Dispatcher) dispatchClicks(ctx context.Context, clicks []*core.Click) {
+	owners := map[string]string{}
+	userWebhooks := map[string][]*core.Webhook{}
+	dropped := 0
+
+	for _, click := range clicks {
+		owner, ok := owners[click.ShortURLID]
+
+		if !ok {
+			if shortURL, found := d.shortURLs.GetByID(ctx, click.ShortURLID); found && shortURL.UserID.Valid {
+				owner = shortURL.UserID.String
+			}
+
+			owners[click.ShortURLID] = owner
+		}
+
+		webhooks, err := d.webhooksOfUser(userWebhooks, owner)
+
+		if err != nil {
+			d.log.Error("error get webhooks for clicks", zap.String("userID", owner), zap.Error(err))
+
+			continue
+		}
+
+		for _, webhook := range webhooks {
+			if !webhook.Subscribed(core.EventClicked) {
+				continue
+			}
+
+			job, err := newClickDelivery(webhook, click, random.RandSeq(16))
+
+			if err != nil {
+				d.log.Error("error make delivery of click", zap.Error(err))
+
+				continue
+			}
+
+			select {
+			case d.queue <- job:
+			default:
+				dropped++
+			}
+		}
+	}
+
+	if dropped > 0 {
+		d.log.Warn("webhooks queue is full, deliveries of clicks dropped", zap.Int("dropped", dropped))
+	}
+}
+
+// webhooksOfUser webhooks of user with cache for one batch
+func (d *Dispatcher) webhooksOfUser(cache map[string][]*core.Webhook, userID string) ([]*core.Webhook, error) {
+	if userID == "" {
+		return nil, nil
+	}
+
+	if webhooks, ok := cache[userID]; ok {
+		return webhooks, nil
+	}
+
+	webhooks, err := d.webhooks.ListWebhooks(d.ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cache[userID] = webhooks
+
+	return webhooks, nil
+}
+
+// enqueue wait place in queue. Return false when dispatcher is closed
+func (d *Dispatcher) enqueue(job *delivery) bool {
+	select {
+	case d.queue <- job:
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.workers.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case job := <-d.queue:
+			d.deliver(job)
+		}
+	}
+}
+
+// deliver send request and schedule retry or account failed delivery
+func (d *Dispatcher) deliver(job *delivery) {
+	job.attempt++
+
+	err := d.sender.send(d.ctx, job)
+
+	if err == nil {
+		// Счетчик в job.webhook мог устареть, поэтому сброс решает хранилище по сохраненному значению
+		if err := d.webhooks.MarkWebhookDelivered(d.ctx, job.webhook.ID); err != nil {
+			d.log.Error("error reset failures of webhook", zap.String("webhookID", job.webhook.ID), zap.Error(err))
+		}
+
+		d.finish(job)
+
+		return
+	}
+
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	d.log.Warn(
+		"error deliver webhook",
+		zap.String("webhookID", job.webhook.ID),
+		zap.String("deliveryID", job.id),
+		zap.Int("attempt", job.attempt),
+		zap.Error(err),
+	)
+
+	if job.attempt < d.opts.MaxAttempts {
+		d.retry(job)
+
+		return
+	}
+
+	// Доставка больше не повторяется, событие не держит курсор
+	defer d.finish(job)
+
+	disabled, err := d.webhooks.MarkWebhookFailed(d.ctx, job.webhook.ID, d.opts.DisableAfter)
+
+	if err != nil {
+		d.log.Error("error mark failed webhook", zap.String("webhookID", job.webhook.ID), zap.Error(err))
+
+		return
+	}
+
+	if disabled {
+		d.log.Warn("webhook disabled after failed deliveries", zap.String("webhookID", job.webhook.ID), zap.String("url", job.webhook.URL))
+	}
+}
+
+// retry put delivery into queue after backoff
+func (d *Dispatcher) retry(job *delivery) {
+	delay := d.opts.Backoff << (job.attempt - 1)
+
+	if delay > d.opts.MaxBackoff || delay <= 0 {
+		delay = d.opts.MaxBackoff
+	}
+
+	d.workers.Add(1)
+
+	go func() {
+		defer d.workers.Done()
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-d.ctx.Done():
+		case <-timer.C:
+			d.enqueue(job)
+		}
+	}()
+}
+
+// finish account finished delivery of event of feed and save cursor when it is moved
+func (d *Dispatcher) finish(job *delivery) {
+	if job.eventID == 0 {
+		return
+	}
+
+	d.progress.finish(job.eventID)
+	d.saveCursor()
+}
+
+// saveCursor save cursor after events all deliveries of which are finished
+func (d *Dispatcher) saveCursor() {
+	d.progress.save(func(cursor int64) error {
+		if err := d.webhooks.SaveWebhookCursor(d.ctx, cursor); err != nil {
+			if d.ctx.Err() == nil {
+				d.log.Error("error save cursor of webhooks", zap.Int64("cursor", cursor), zap.Error(err))
+			}
+
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,350 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// testReceiver local receiver of webhooks which answer by statuses in order, last status is repeated
+type testReceiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+
+	status := http.StatusOK
+
+	if len(r.statuses) > 0 {
+		status = r.statuses[0]
+
+		if len(r.statuses) > 1 {
+			r.statuses = r.statuses[1:]
+		}
+	}
+
+	w.WriteHeader(status)
+}
+
+func (r *testReceiver) received() []receivedRequest {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+func newShortURL(id, userID string) *core.ShortURL {
+	return &core.ShortURL{
+		ID:     id,
+		URL:    "https://vk.com/" + id,
+		UserID: sql.NullString{String: userID, Valid: userID != ""},
+	}
+}
+
+func testOptions() Options {
+	return Options{
+		Workers:      2,
+		PollInterval: 10 * time.Millisecond,
+		Timeout:      time.Second,
+		MaxAttempts:  2,
+		Backoff:      time.Millisecond,
+		DisableAfter: 2,
+		// Получатели в тестах слушают 127.0.0.1
+		AllowPrivateNetworks: true,
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should deliver signed events to webhook of owner", func(t *testing.T) {
+		receiver := &testReceiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		shortURLs := storagememory.NewShortURLStore()
+		webhooks := storagememory.NewWebhookStore()
+
+		require.NoError(t, webhooks.AddWebhook(ctx, &core.Webhook{
+			ID: "hook1", UserID: "user1", URL: server.URL, Secret: "secret", Events: []core.EventType{core.EventCreated},
+		}))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("own", "user1")))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("other", "user2")))
+		require.NoError(t, shortURLs.DeleteURLsUserByIds(ctx, "user1", []string{"own"}))
+
+		d := NewDispatcher(zap.NewNop(), shortURLs, webhooks, shortURLs, testOptions())
+		defer d.Close()
+
+		require.Eventually(t, func() bool {
+			cursor, _ := webhooks.WebhookCursor(ctx)
+			return cursor == 3 && len(receiver.received()) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		request := receiver.received()[0]
+
+		assert.Equal(t, Sign("secret", request.body), request.header.Get(HeaderSignature))
+		assert.Equal(t, "created", request.header.Get(HeaderEvent))
+		assert.Equal(t, "1-hook1", request.header.Get(HeaderDelivery))
+
+		var payload Payload
+		require.NoError(t, json.Unmarshal(request.body, &payload))
+
+		assert.Equal(t, "1-hook1", payload.DeliveryID)
+		assert.Equal(t, int64(1), payload.EventID)
+		assert.Equal(t, "created", payload.Type)
+		assert.Equal(t, "own", payload.ShortURLID)
+		assert.Equal(t, "https://vk.com/own", payload.URL)
+	})
+
+	t.Run("should continue feed after saved cursor", func(t *testing.T) {
+		receiver := &testReceiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		shortURLs := storagememory.NewShortURLStore()
+		webhooks := storagememory.NewWebhookStore()
+
+		require.NoError(t, webhooks.AddWebhook(ctx, &core.Webhook{
+			ID: "hook1", UserID: "user1", URL: server.URL, Events: []core.EventType{core.EventCreated},
+		}))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("old", "user1")))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("new", "user1")))
+		require.NoError(t, webhooks.SaveWebhookCursor(ctx, 1))
+
+		d := NewDispatcher(zap.NewNop(), shortURLs, webhooks, shortURLs, testOptions())
+		defer d.Close()
+
+		require.Eventually(t, func() bool {
+			return len(receiver.received()) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, "2-hook1", receiver.received()[0].header.Get(HeaderDelivery))
+	})
+
+	t.Run("should keep events in feed for other consumers after dispatch", func(t *testing.T) {
+		shortURLs := storagememory.NewShortURLStore()
+		webhooks := storagememory.NewWebhookStore()
+
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("first", "user1")))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("second", "user1")))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("third", "user1")))
+
+		d := NewDispatcher(zap.NewNop(), shortURLs, webhooks, shortURLs, testOptions())
+		defer d.Close()
+
+		require.Eventually(t, func() bool {
+			cursor, err := webhooks.WebhookCursor(ctx)
+
+			return err == nil && cursor == 3
+		}, time.Second, 10*time.Millisecond)
+
+		events, err := shortURLs.EventsAfter(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(events))
+		assert.Equal(t, "first", events[0].ShortURLID)
+	})
+
+	t.Run("should keep cursor before unfinished delivery and deliver it after restart", func(t *testing.T) {
+		receiver := &testReceiver{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK}}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		shortURLs := storagememory.NewShortURLStore()
+		webhooks := storagememory.NewWebhookStore()
+
+		require.NoError(t, webhooks.AddWebhook(ctx, &core.Webhook{
+			ID: "hook1", UserID: "user1", URL: server.URL, Events: []core.EventType{core.EventCreated},
+		}))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("own", "user1")))
+
+		opts := testOptions()
+		opts.MaxAttempts = 5
+		opts.Backoff = time.Hour
+		opts.MaxBackoff = time.Hour
+
+		d := NewDispatcher(zap.NewNop(), shortURLs, webhooks, shortURLs, opts)
+
+		require.Eventually(t, func() bool {
+			return len(receiver.received()) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		d.Close()
+
+		cursor, err := webhooks.WebhookCursor(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), cursor)
+
+		opts.Backoff = time.Millisecond
+		opts.MaxBackoff = time.Millisecond
+
+		d = NewDispatcher(zap.NewNop(), shortURLs, webhooks, shortURLs, opts)
+		defer d.Close()
+
+		require.Eventually(t, func() bool {
+			cursor, _ := webhooks.WebhookCursor(ctx)
+			return cursor == 1
+		}, time.Second, 10*time.Millisecond)
+
+		requests := receiver.received()
+		require.Equal(t, 3, len(requests))
+		assert.Equal(t, "1-hook1", requests[2].header.Get(HeaderDelivery))
+	})
+
+	t.Run("should reset stored failures after success", func(t *testing.T) {
+		receiver := &testReceiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		shortURLs := storagememory.NewShortURLStore()
+		webhooks := storagememory.NewWebhookStore()
+
+		require.NoError(t, webhooks.AddWebhook(ctx, &core.Webhook{
+			ID: "hook1", UserID: "user1", URL: server.URL, Events: []core.EventType{core.EventCreated},
+		}))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("own", "user1")))
+
+		// Неудача другой доставки записана после того, как webhook был прочитан для события
+		_, err := webhooks.MarkWebhookFailed(ctx, "hook1", 10)
+		require.NoError(t, err)
+
+		d := &Dispatcher{log: zap.NewNop(), webhooks: webhooks, sender: newSender(time.Second, true), opts: testOptions(), ctx: ctx}
+
+		d.deliver(&delivery{webhook: &core.Webhook{ID: "hook1", URL: server.URL}, id: "1-hook1", body: []byte(`{}`)})
+
+		got, err := webhooks.ListWebhooks(ctx, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, 0, got[0].Failures)
+	})
+
+	t.Run("should retry failed delivery and reset failures after success", func(t *testing.T) {
+		receiver := &testReceiver{statuses: []int{http.StatusInternalServerError, http.StatusOK}}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		shortURLs := storagememory.NewShortURLStore()
+		webhooks := storagememory.NewWebhookStore()
+
+		require.NoError(t, webhooks.AddWebhook(ctx, &core.Webhook{
+			ID: "hook1", UserID: "user1", URL: server.URL, Events: []core.EventType{core.EventCreated}, Failures: 1,
+		}))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("own", "user1")))
+
+		d := NewDispatcher(zap.NewNop(), shortURLs, webhooks, shortURLs, testOptions())
+		defer d.Close()
+
+		require.Eventually(t, func() bool {
+			got, _ := webhooks.ListWebhooks(ctx, "user1")
+			return len(receiver.received()) == 2 && got[0].Failures == 0
+		}, time.Second, 10*time.Millisecond)
+
+		requests := receiver.received()
+		assert.Equal(t, requests[0].header.Get(HeaderDelivery), requests[1].header.Get(HeaderDelivery))
+	})
+
+	t.Run("should disable webhook after failed deliveries in a row", func(t *testing.T) {
+		receiver := &testReceiver{statuses: []int{http.StatusBadGateway}}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		shortURLs := storagememory.NewShortURLStore()
+		webhooks := storagememory.NewWebhookStore()
+
+		require.NoError(t, webhooks.AddWebhook(ctx, &core.Webhook{
+			ID: "hook1", UserID: "user1", URL: server.URL, Events: []core.EventType{core.EventCreated},
+		}))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("first", "user1")))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("second", "user1")))
+
+		d := NewDispatcher(zap.NewNop(), shortURLs, webhooks, shortURLs, testOptions())
+		defer d.Close()
+
+		require.Eventually(t, func() bool {
+			got, _ := webhooks.ListWebhooks(ctx, "user1")
+			return got[0].Disabled
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, 4, len(receiver.received()))
+
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("third", "user1")))
+
+		require.Eventually(t, func() bool {
+			cursor, _ := webhooks.WebhookCursor(ctx)
+			return cursor == 3
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, 4, len(receiver.received()))
+	})
+
+	t.Run("should deliver clicks to webhook of owner", func(t *testing.T) {
+		receiver := &testReceiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		shortURLs := storagememory.NewShortURLStore()
+		webhooks := storagememory.NewWebhookStore()
+		clicks := storagememory.NewClickStore()
+
+		require.NoError(t, webhooks.AddWebhook(ctx, &core.Webhook{
+			ID: "hook1", UserID: "user1", URL: server.URL, Secret: "secret", Events: []core.EventType{core.EventClicked},
+		}))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("own", "user1")))
+		require.NoError(t, shortURLs.Add(ctx, newShortURL("other", "user2")))
+
+		d := NewDispatcher(zap.NewNop(), shortURLs, webhooks, shortURLs, testOptions())
+		defer d.Close()
+
+		clickedAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		require.NoError(t, d.NotifyClicks(clicks).AddClicks(ctx, []*core.Click{
+			{ShortURLID: "own", CreatedAt: clickedAt, Referrer: "https://ya.ru", UserAgent: "curl", IP: "10.0.0.1"},
+			{ShortURLID: "other", CreatedAt: clickedAt},
+			{ShortURLID: "unknown", CreatedAt: clickedAt},
+		}))
+
+		stats, err := clicks.GetClickStats(ctx, "own", clickedAt.Add(-time.Hour), clickedAt.Add(time.Hour), 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Total)
+
+		require.Eventually(t, func() bool {
+			return len(receiver.received()) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		request := receiver.received()[0]
+
+		assert.Equal(t, Sign("secret", request.body), request.header.Get(HeaderSignature))
+		assert.JSONEq(t, `{
+			"delivery_id": "`+request.header.Get(HeaderDelivery)+`",
+			"type": "clicked",
+			"short_url_id": "own",
+			"created_at": "2022-10-01T12:00:00Z",
+			"referrer": "https://ya.ru",
+			"user_agent": "curl"
+		}`, string(request.body))
+	})
+}
@@ -0,0 +1,75 @@
+package webhooks
+
+import "sync"
+
+// pendingEvent event of feed with count of unfinished deliveries
+type pendingEvent struct {
+	id        int64
+	remaining int
+}
+
+// progress deliveries of events read from feed in order of feed.
+// Cursor is moved only over prefix of events all deliveries of which are finished
+type progress struct {
+	mutex   sync.Mutex
+	pending []*pendingEvent
+	byID    map[int64]*pendingEvent
+	done    int64
+	saved   int64
+}
+
+func newProgress(cursor int64) *progress {
+	return &progress{
+		byID:  map[int64]*pendingEvent{},
+		done:  cursor,
+		saved: cursor,
+	}
+}
+
+// add event with count of deliveries, event without deliveries is finished at once
+func (p *progress) add(id int64, deliveries int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	event := &pendingEvent{id: id, remaining: deliveries}
+
+	p.pending = append(p.pending, event)
+	p.byID[id] = event
+
+	p.advance()
+}
+
+// finish one delivery of event
+func (p *progress) finish(id int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if event, ok := p.byID[id]; ok {
+		event.remaining--
+	}
+
+	p.advance()
+}
+
+func (p *progress) advance() {
+	for len(p.pending) > 0 && p.pending[0].remaining <= 0 {
+		p.done = p.pending[0].id
+		delete(p.byID, p.done)
+		p.pending = p.pending[1:]
+	}
+}
+
+// save call fn with cursor when it is moved after last save. Calls are serialized,
+// so older cursor is never saved after newer
+func (p *progress) save(fn func(cursor int64) error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.done == p.saved {
+		return
+	}
+
+	if err := fn(p.done); err == nil {
+		p.saved = p.done
+	}
+}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// Day begin of day in UTC for time
+func Day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// FillDays count by days for all days of range, counts is keyed by Day
+func FillDays(counts map[time.Time]int, statsRange core.StatsRange) []core.DayCount {
+	days := make([]core.DayCount, 0, statsRange.Days())
+
+	for day := statsRange.From; day.Before(statsRange.To); day = day.AddDate(0, 0, 1) {
+		days = append(days, core.DayCount{Day: day, Count: counts[day]})
+	}
+
+	return days
+}
+
+// AggregateShortURLs stats of short urls for stores which read urls in memory. StorageSize isn't filled
+func AggregateShortURLs(shortURLs []*core.ShortURL, statsRange core.StatsRange) *core.ShortStats {
+	var stats core.ShortStats
+
+	users := map[string]struct{}{}
+	activeUsers := map[string]struct{}{}
+	days := map[time.Time]int{}
+
+	for _, shortURL := range shortURLs {
+		stats.URLs++
+
+		if shortURL.IsDeleted {
+			stats.DeletedURLs++
+		}
+
+		inRange := !shortURL.CreatedAt.Before(statsRange.From) && shortURL.CreatedAt.Before(statsRange.To)
+
+		if inRange {
+			days[Day(shortURL.CreatedAt)]++
+		}
+
+		if !shortURL.UserID.Valid || shortURL.UserID.String == "" {
+			continue
+		}
+
+		users[shortURL.UserID.String] = struct{}{}
+
+		if inRange {
+			activeUsers[shortURL.UserID.String] = struct{}{}
+		}
+	}
+
+	stats.Users = len(users)
+	stats.ActiveUsers = len(activeUsers)
+	stats.CreatedPerDay = FillDays(days, statsRange)
+
+	return &stats
+}
+
+// AggregateClicks statistic of clicks of short url for stores which read clicks in memory
+func AggregateClicks(clicks []*core.Click, shortURLID string, from, to time.Time, top int) *core.ClickStats {
+	var stats core.ClickStats
+
+	visitors := map[string]struct{}{}
+	days := map[time.Time]int{}
+	referrers := map[string]int{}
+	userAgents := map[string]int{}
+
+	for _, click := range clicks {
+		if click.ShortURLID != shortURLID || click.CreatedAt.Before(from) || !click.CreatedAt.Before(to) {
+			continue
+		}
+
+		stats.Total++
+		days[Day(click.CreatedAt)]++
+
+		if click.IP != "" {
+			visitors[click.IP] = struct{}{}
+		}
+
+		if click.Referrer != "" {
+			referrers[click.Referrer]++
+		}
+
+		if click.UserAgent != "" {
+			userAgents[click.UserAgent]++
+		}
+	}
+
+	stats.UniqueVisitors = len(visitors)
+
+	for day, count := range days {
+		stats.Days = append(stats.Days, core.DayCount{Day: day, Count: count})
+	}
+
+	sort.Slice(stats.Days, func(i, j int) bool {
+		return stats.Days[i].Day.Before(stats.Days[j].Day)
+	})
+
+	stats.TopReferrers = TopValues(referrers, top)
+	stats.TopUserAgents = TopValues(userAgents, top)
+
+	return &stats
+}
+
+// TopValues no more top values with max count, equal count are ordered by value
+func TopValues(counts map[string]int, top int) []core.ValueCount {
+	values := make([]core.ValueCount, 0, len(counts))
+
+	for value, count := range counts {
+		values = append(values, core.ValueCount{Value: value, Count: count})
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+
+		return values[i].Value < values[j].Value
+	})
+
+	if len(values) > top {
+		values = values[:top]
+	}
+
+	return values
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// Limits of page size
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+var (
+	// ErrInvalidCursor cursor was broken or created for other sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Cursor position after last short url of page
+type Cursor struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	SortBy    int       `json:"s"`
+	Desc      bool      `json:"d,omitempty"`
+}
+
+// EncodeCursor create opaque cursor after short url
+func EncodeCursor(shortURL *core.ShortURL, opts core.ListOptions) string {
+	cursor := Cursor{
+		ID:     shortURL.ID,
+		SortBy: int(opts.SortBy),
+		Desc:   opts.Desc,
+	}
+
+	if opts.SortBy == core.SortByCreatedAt {
+		cursor.CreatedAt = shortURL.CreatedAt
+	}
+
+	data, _ := json.Marshal(&cursor)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parse cursor from ListOptions. Return nil for first page
+func DecodeCursor(opts core.ListOptions) (*Cursor, error) {
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.SortBy != int(opts.SortBy) || cursor.Desc != opts.Desc || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// NormalizeLimit return default limit for empty and cut too large
+func NormalizeLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+
+	if limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+
+	return limit
+}
+
+// LessShortURL compare short urls by sort of options
+func LessShortURL(a, b *core.ShortURL, opts core.ListOptions) bool {
+	if opts.Desc {
+		a, b = b, a
+	}
+
+	if opts.SortBy == core.SortByCreatedAt && !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+
+	return a.ID < b.ID
+}
+
+// PageShortURLs cut page from all short urls. Used by stores without ordered indexes
+func PageShortURLs(shortURLs []*core.ShortURL, opts core.ListOptions) (*core.ShortURLPage, error) {
+	cursor, err := DecodeCursor(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	limit := NormalizeLimit(opts.Limit)
+
+	sort.Slice(shortURLs, func(i, j int) bool {
+		return LessShortURL(shortURLs[i], shortURLs[j], opts)
+	})
+
+	start := 0
+
+	if cursor != nil {
+		after := &core.ShortURL{ID: cursor.ID, CreatedAt: cursor.CreatedAt}
+
+		start = sort.Search(len(shortURLs), func(i int) bool {
+			return LessShortURL(after, shortURLs[i], opts)
+		})
+	}
+
+	end := start + limit
+
+	if end > len(shortURLs) {
+		end = len(shortURLs)
+	}
+
+	page := core.ShortURLPage{
+		ShortURLs: shortURLs[start:end],
+	}
+
+	if end < len(shortURLs) {
+		page.NextCursor = EncodeCursor(shortURLs[end-1], opts)
+	}
+
+	return &page, nil
+}
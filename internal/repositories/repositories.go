@@ -2,16 +2,77 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/shreyner/go-shortener/internal/core"
 )
 
-// ShortURLRepository base contract for all repositories
+// ShortURLRepository base contract for all repositories.
+// Url is unique only among active short urls (core.ShortURL.IsActive), deleted or expired short url
+// doesn't conflict with new short url of same url
 type ShortURLRepository interface {
+	// Add short url or return ShortURLCreateConflictError with ID of active short url of same url
 	Add(ctx context.Context, shortedURL *core.ShortURL) error
 	GetByID(ctx context.Context, id string) (*core.ShortURL, bool)
 	AllByUserID(ctx context.Context, id string) ([]*core.ShortURL, error)
+	ListByUserID(ctx context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error)
+	// CreateBatch add all short urls or nothing. Errors are same as in Add. Short url with IsDeleted
+	// is stored as deleted with events created and deleted, it's used for copy data between stores
 	CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error
 	DeleteURLsUserByIds(ctx context.Context, userID string, ids []string) error
-	GetStats(ctx context.Context) (*core.ShortStats, error)
+	// GetStats return totals of store and created urls with active users in range of stats
+	GetStats(ctx context.Context, statsRange core.StatsRange) (*core.ShortStats, error)
+	// DeleteExpired mark as deleted short urls with expiration before or equal now. Return count of marked
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+	// UpdateURL change url of short url of change.UserID to change.NewURL and redirect options by redirect,
+	// url, redirect options, change and event are written in same transaction and change gets ID and OldURL.
+	// Return core.ErrShortURLNotFound, core.ErrNotOwner, core.ErrShortURLDeleted or ShortURLCreateConflictError
+	// when url is used by other short url. Change to same url isn't recorded and change.ID stays zero
+	UpdateURL(ctx context.Context, change *core.URLChange, redirect core.RedirectUpdate) (*core.ShortURL, error)
+	// URLChanges changes of url of short url ordered by ID, include changes of deleted short url
+	URLChanges(ctx context.Context, shortURLID string) ([]*core.URLChange, error)
+	// SetRedirect change redirect type and max-age of short url of user by changed fields of update. Fields are merged
+	// with current options under same lock or transaction as write. Return same errors as UpdateURL,
+	// change isn't recorded in URLChanges
+	SetRedirect(ctx context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error)
+}
+
+// ShortURLScanner repositories which can iterate over all short urls.
+// Used for copy data between stores
+type ShortURLScanner interface {
+	// ScanAll call fn for every short url with ID greater than afterID ordered by ID.
+	// Empty afterID mean from begin
+	ScanAll(ctx context.Context, afterID string, fn func(shortURL *core.ShortURL) error) error
+}
+
+// ClickRepository store of redirect events
+type ClickRepository interface {
+	// AddClicks save batch of clicks
+	AddClicks(ctx context.Context, clicks []*core.Click) error
+	// GetClickStats aggregate clicks of short url from from inclusive to to exclusive,
+	// days have only days with clicks, top has no more top values
+	GetClickStats(ctx context.Context, shortURLID string, from, to time.Time, top int) (*core.ClickStats, error)
+}
+
+// EventRepository change feed of lifecycle events of short urls.
+// Stores write events in same transaction as Add, CreateBatch, DeleteURLsUserByIds, DeleteExpired and UpdateURL
+type EventRepository interface {
+	// EventsAfter return no more limit events with ID greater than after ordered by ID
+	EventsAfter(ctx context.Context, after int64, limit int) ([]*core.Event, error)
+}
+
+// WebhookRepository subscriptions of users on events of short urls and state of delivery
+type WebhookRepository interface {
+	AddWebhook(ctx context.Context, webhook *core.Webhook) error
+	// ListWebhooks webhooks of user in order of creation, include disabled
+	ListWebhooks(ctx context.Context, userID string) ([]*core.Webhook, error)
+	// DeleteWebhook delete webhook of user or return core.ErrWebhookNotFound
+	DeleteWebhook(ctx context.Context, userID, id string) error
+	// MarkWebhookFailed increment failed deliveries in a row and disable webhook when they reach disableAfter
+	MarkWebhookFailed(ctx context.Context, id string, disableAfter int) (disabled bool, err error)
+	// MarkWebhookDelivered reset failed deliveries in a row, webhook without failures is not changed
+	MarkWebhookDelivered(ctx context.Context, id string) error
+	// WebhookCursor ID of event of feed before which all deliveries are finished
+	WebhookCursor(ctx context.Context) (int64, error)
+	SaveWebhookCursor(ctx context.Context, cursor int64) error
 }
@@ -0,0 +1,88 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+// ClickFactory create new empty repository of clicks for every test case
+type ClickFactory func(t *testing.T) repositories.ClickRepository
+
+// RunClicks all conformance tests for repository of clicks
+func RunClicks(t *testing.T, newRepository ClickFactory) {
+	day := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should return empty stats without clicks", func(t *testing.T) {
+		s := newRepository(t)
+
+		stats, err := s.GetClickStats(context.Background(), "1", day, day.AddDate(0, 0, 7), 10)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, stats.Total)
+		assert.Equal(t, 0, stats.UniqueVisitors)
+		assert.Empty(t, stats.Days)
+		assert.Empty(t, stats.TopReferrers)
+		assert.Empty(t, stats.TopUserAgents)
+	})
+
+	t.Run("should aggregate clicks of short url in range", func(t *testing.T) {
+		s := newRepository(t)
+
+		require.NoError(t, s.AddClicks(context.Background(), []*core.Click{
+			{ShortURLID: "1", CreatedAt: day.Add(time.Hour), Referrer: "https://ya.ru", UserAgent: "curl", IP: "10.0.0.1"},
+			{ShortURLID: "1", CreatedAt: day.Add(2 * time.Hour), Referrer: "https://ya.ru", UserAgent: "firefox", IP: "10.0.0.1"},
+			{ShortURLID: "1", CreatedAt: day.Add(26 * time.Hour), Referrer: "https://vk.com", UserAgent: "curl", IP: "10.0.0.2"},
+			{ShortURLID: "1", CreatedAt: day.Add(27 * time.Hour), UserAgent: "curl"},
+			{ShortURLID: "2", CreatedAt: day.Add(time.Hour), Referrer: "https://ok.ru", UserAgent: "chrome", IP: "10.0.0.3"},
+		}))
+		require.NoError(t, s.AddClicks(context.Background(), []*core.Click{
+			{ShortURLID: "1", CreatedAt: day.Add(-time.Minute), Referrer: "https://ok.ru", IP: "10.0.0.4"},
+			{ShortURLID: "1", CreatedAt: day.AddDate(0, 0, 7), Referrer: "https://ok.ru", IP: "10.0.0.5"},
+		}))
+
+		stats, err := s.GetClickStats(context.Background(), "1", day, day.AddDate(0, 0, 7), 10)
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, stats.Total)
+		assert.Equal(t, 2, stats.UniqueVisitors)
+
+		require.Len(t, stats.Days, 2)
+		assert.True(t, day.Equal(stats.Days[0].Day), stats.Days[0].Day)
+		assert.Equal(t, 2, stats.Days[0].Count)
+		assert.True(t, day.AddDate(0, 0, 1).Equal(stats.Days[1].Day), stats.Days[1].Day)
+		assert.Equal(t, 2, stats.Days[1].Count)
+
+		assert.Equal(t, []core.ValueCount{
+			{Value: "https://ya.ru", Count: 2},
+			{Value: "https://vk.com", Count: 1},
+		}, stats.TopReferrers)
+		assert.Equal(t, []core.ValueCount{
+			{Value: "curl", Count: 3},
+			{Value: "firefox", Count: 1},
+		}, stats.TopUserAgents)
+	})
+
+	t.Run("should limit top values", func(t *testing.T) {
+		s := newRepository(t)
+
+		require.NoError(t, s.AddClicks(context.Background(), []*core.Click{
+			{ShortURLID: "1", CreatedAt: day, Referrer: "c", UserAgent: "a"},
+			{ShortURLID: "1", CreatedAt: day, Referrer: "b", UserAgent: "a"},
+			{ShortURLID: "1", CreatedAt: day, Referrer: "a", UserAgent: "b"},
+			{ShortURLID: "1", CreatedAt: day, Referrer: "c", UserAgent: "c"},
+		}))
+
+		stats, err := s.GetClickStats(context.Background(), "1", day, day.AddDate(0, 0, 1), 2)
+		require.NoError(t, err)
+
+		assert.Equal(t, []core.ValueCount{{Value: "c", Count: 2}, {Value: "a", Count: 1}}, stats.TopReferrers)
+		assert.Equal(t, []core.ValueCount{{Value: "a", Count: 2}, {Value: "b", Count: 1}}, stats.TopUserAgents)
+	})
+}
@@ -0,0 +1,1194 @@
+// Package repotest conformance test suite for implementations of repositories.ShortURLRepository and repositories.ClickRepository
+//
+// Example use in tests of storage:
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repositories.ShortURLRepository {
+//			return NewShortURLStore()
+//		})
+//	}
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
+)
+
+// Factory create new empty repository for every test case
+type Factory func(t *testing.T) repositories.ShortURLRepository
+
+// NewShortURL helper for create short url owned by user. Empty userID mean without owner
+func NewShortURL(id, url, userID string) *core.ShortURL {
+	return &core.ShortURL{
+		ID:  id,
+		URL: url,
+		UserID: sql.NullString{
+			String: userID,
+			Valid:  userID != "",
+		},
+	}
+}
+
+// Run all conformance tests for repository
+func Run(t *testing.T, newRepository Factory) {
+	t.Run("Add", func(t *testing.T) {
+		testAdd(t, newRepository)
+	})
+
+	t.Run("CreateBatch", func(t *testing.T) {
+		testCreateBatch(t, newRepository)
+	})
+
+	t.Run("DeleteURLsUserByIds", func(t *testing.T) {
+		testDeleteURLsUserByIds(t, newRepository)
+	})
+
+	t.Run("AllByUserID", func(t *testing.T) {
+		testAllByUserID(t, newRepository)
+	})
+
+	t.Run("ListByUserID", func(t *testing.T) {
+		testListByUserID(t, newRepository)
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		testDeleteExpired(t, newRepository)
+	})
+
+	t.Run("GetStats", func(t *testing.T) {
+		testGetStats(t, newRepository)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		testConcurrent(t, newRepository)
+	})
+
+	t.Run("ScanAll", func(t *testing.T) {
+		testScanAll(t, newRepository)
+	})
+
+	t.Run("EventsAfter", func(t *testing.T) {
+		testEventsAfter(t, newRepository)
+	})
+
+	t.Run("UpdateURL", func(t *testing.T) {
+		testUpdateURL(t, newRepository)
+	})
+
+	t.Run("SetRedirect", func(t *testing.T) {
+		testSetRedirect(t, newRepository)
+	})
+}
+
+func testAdd(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("should success add and get by id", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("add1", "https://vk.com/add1", "user1")))
+
+		got, ok := r.GetByID(ctx, "add1")
+
+		require.True(t, ok)
+		assert.Equal(t, "add1", got.ID)
+		assert.Equal(t, "https://vk.com/add1", got.URL)
+		assert.Equal(t, sql.NullString{String: "user1", Valid: true}, got.UserID)
+		assert.False(t, got.IsDeleted)
+	})
+
+	t.Run("should not found unknown id", func(t *testing.T) {
+		r := newRepository(t)
+
+		_, ok := r.GetByID(ctx, "unknown")
+
+		assert.False(t, ok)
+	})
+
+	t.Run("should return conflict with origin id for same url", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("add1", "https://vk.com/add", "user1")))
+
+		err := r.Add(ctx, NewShortURL("add2", "https://vk.com/add", "user2"))
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.True(t, errors.As(err, &conflictError), "got error %v", err)
+		assert.Equal(t, "add1", conflictError.OriginID)
+
+		_, ok := r.GetByID(ctx, "add2")
+		assert.False(t, ok)
+
+		got, ok := r.GetByID(ctx, "add1")
+		require.True(t, ok)
+		assert.Equal(t, "user1", got.UserID.String)
+	})
+
+	t.Run("should return id conflict when id was taken", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("spring-sale", "https://vk.com/add1", "user1")))
+
+		err := r.Add(ctx, NewShortURL("spring-sale", "https://vk.com/add2", "user2"))
+
+		var conflictError *storeerrors.ShortURLIDConflictError
+		require.True(t, errors.As(err, &conflictError), "got error %v", err)
+		assert.Equal(t, "spring-sale", conflictError.ID)
+
+		got, ok := r.GetByID(ctx, "spring-sale")
+		require.True(t, ok)
+		assert.Equal(t, "https://vk.com/add1", got.URL)
+		assert.Equal(t, "user1", got.UserID.String)
+	})
+
+	t.Run("should add url of deleted and expired short urls", func(t *testing.T) {
+		r := newRepository(t)
+
+		expired := NewShortURL("expired1", "https://vk.com/expired", "user1")
+		expired.ExpiresAt = sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}
+
+		require.NoError(t, r.Add(ctx, NewShortURL("deleted1", "https://vk.com/deleted", "user1")))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"deleted1"}))
+		require.NoError(t, r.Add(ctx, expired))
+
+		require.NoError(t, r.Add(ctx, NewShortURL("deleted2", "https://vk.com/deleted", "user2")))
+		require.NoError(t, r.Add(ctx, NewShortURL("expired2", "https://vk.com/expired", "user2")))
+
+		err := r.Add(ctx, NewShortURL("deleted3", "https://vk.com/deleted", "user2"))
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.ErrorAs(t, err, &conflictError)
+		assert.Equal(t, "deleted2", conflictError.OriginID)
+
+		got, ok := r.GetByID(ctx, "deleted1")
+		require.True(t, ok)
+		assert.True(t, got.IsDeleted)
+		assert.Equal(t, "https://vk.com/deleted", got.URL)
+
+		for _, id := range []string{"deleted2", "expired2"} {
+			got, ok := r.GetByID(ctx, id)
+			require.True(t, ok, id)
+			assert.False(t, got.IsDeleted, id)
+		}
+	})
+}
+
+func testCreateBatch(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("should add all urls", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("batch1", "https://vk.com/batch1", "user1"),
+			NewShortURL("batch2", "https://vk.com/batch2", "user1"),
+		}))
+
+		for _, id := range []string{"batch1", "batch2"} {
+			_, ok := r.GetByID(ctx, id)
+			assert.True(t, ok, "id %s", id)
+		}
+	})
+
+	t.Run("should add nothing when url was created before", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("batch1", "https://vk.com/batch1", "user1")))
+
+		err := r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("batch2", "https://vk.com/batch2", "user1"),
+			NewShortURL("batch3", "https://vk.com/batch1", "user1"),
+		})
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.ErrorAs(t, err, &conflictError)
+		assert.Equal(t, "batch1", conflictError.OriginID)
+
+		for _, id := range []string{"batch2", "batch3"} {
+			_, ok := r.GetByID(ctx, id)
+			assert.False(t, ok, "id %s", id)
+		}
+	})
+
+	t.Run("should add nothing when id was taken", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("batch1", "https://vk.com/batch1", "user1")))
+
+		err := r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("batch2", "https://vk.com/batch2", "user1"),
+			NewShortURL("batch1", "https://vk.com/batch3", "user1"),
+		})
+
+		var conflictError *storeerrors.ShortURLIDConflictError
+		require.True(t, errors.As(err, &conflictError), "got error %v", err)
+		assert.Equal(t, "batch1", conflictError.ID)
+
+		_, ok := r.GetByID(ctx, "batch2")
+		assert.False(t, ok)
+	})
+
+	t.Run("should add nothing when batch has same ids", func(t *testing.T) {
+		r := newRepository(t)
+
+		err := r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("batch1", "https://vk.com/batch1", "user1"),
+			NewShortURL("batch1", "https://vk.com/batch2", "user1"),
+		})
+
+		var conflictError *storeerrors.ShortURLIDConflictError
+		require.True(t, errors.As(err, &conflictError), "got error %v", err)
+
+		_, ok := r.GetByID(ctx, "batch1")
+		assert.False(t, ok)
+	})
+
+	t.Run("should add nothing when batch has same urls", func(t *testing.T) {
+		r := newRepository(t)
+
+		err := r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("batch1", "https://vk.com/batch", "user1"),
+			NewShortURL("batch2", "https://vk.com/batch", "user1"),
+		})
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.ErrorAs(t, err, &conflictError)
+
+		for _, id := range []string{"batch1", "batch2"} {
+			_, ok := r.GetByID(ctx, id)
+			assert.False(t, ok, "id %s", id)
+		}
+	})
+
+	t.Run("should add deleted short urls without taking url", func(t *testing.T) {
+		r := newRepository(t)
+
+		deleted := NewShortURL("batch1", "https://vk.com/batch", "user1")
+		deleted.IsDeleted = true
+
+		require.NoError(t, r.CreateBatch(ctx, &[]*core.ShortURL{
+			deleted,
+			NewShortURL("batch2", "https://vk.com/batch", "user1"),
+		}))
+
+		for id, isDeleted := range map[string]bool{"batch1": true, "batch2": false} {
+			got, ok := r.GetByID(ctx, id)
+			require.True(t, ok, id)
+			assert.Equal(t, isDeleted, got.IsDeleted, id)
+			assert.Equal(t, "https://vk.com/batch", got.URL, id)
+		}
+
+		err := r.Add(ctx, NewShortURL("batch3", "https://vk.com/batch", "user1"))
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.ErrorAs(t, err, &conflictError)
+		assert.Equal(t, "batch2", conflictError.OriginID)
+	})
+}
+
+func testDeleteURLsUserByIds(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("should delete only urls of user", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("del1", "https://vk.com/del1", "user1"),
+			NewShortURL("del2", "https://vk.com/del2", "user1"),
+			NewShortURL("del3", "https://vk.com/del3", "user3"),
+			NewShortURL("del4", "https://vk.com/del4", ""),
+		}))
+
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"del1", "del3", "del4", "unknown"}))
+
+		want := map[string]bool{"del1": true, "del2": false, "del3": false, "del4": false}
+
+		for id, isDeleted := range want {
+			got, ok := r.GetByID(ctx, id)
+
+			require.True(t, ok, "id %s", id)
+			assert.Equal(t, isDeleted, got.IsDeleted, "id %s", id)
+		}
+	})
+
+	t.Run("should do nothing for empty ids", func(t *testing.T) {
+		r := newRepository(t)
+
+		assert.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{}))
+	})
+}
+
+func testAllByUserID(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("should return only urls of user with deleted flag", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("list1", "https://vk.com/list1", "user1"),
+			NewShortURL("list2", "https://vk.com/list2", "user1"),
+			NewShortURL("list3", "https://vk.com/list3", "user3"),
+		}))
+		require.NoError(t, r.Add(ctx, NewShortURL("list4", "https://vk.com/list4", "user1")))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"list2"}))
+
+		got, err := r.AllByUserID(ctx, "user1")
+		require.NoError(t, err)
+		require.Equal(t, 3, len(got))
+
+		sort.Slice(got, func(i, j int) bool {
+			return got[i].ID < got[j].ID
+		})
+
+		assert.Equal(t, "list1", got[0].ID)
+		assert.Equal(t, "https://vk.com/list1", got[0].URL)
+		assert.Equal(t, "user1", got[0].UserID.String)
+		assert.False(t, got[0].IsDeleted)
+		assert.True(t, got[1].IsDeleted)
+		assert.Equal(t, "list4", got[2].ID)
+	})
+
+	t.Run("should return empty list for unknown user", func(t *testing.T) {
+		r := newRepository(t)
+
+		got, err := r.AllByUserID(ctx, "unknown")
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(got))
+	})
+}
+
+func testListByUserID(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+	createdAt := time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC)
+
+	newRepositoryWithURLs := func(t *testing.T) repositories.ShortURLRepository {
+		r := newRepository(t)
+
+		// id и время создания упорядочены по-разному, у page3 и page4 время совпадает
+		for i, minutes := range []int{30, 10, 40, 40, 20} {
+			shortURL := NewShortURL(
+				fmt.Sprintf("page%d", i+1),
+				fmt.Sprintf("https://vk.com/page%d", i+1),
+				"user1",
+			)
+			shortURL.CreatedAt = createdAt.Add(time.Duration(minutes) * time.Minute)
+
+			require.NoError(t, r.Add(ctx, shortURL))
+		}
+
+		require.NoError(t, r.Add(ctx, NewShortURL("page6", "https://vk.com/page6", "user2")))
+
+		return r
+	}
+
+	listAll := func(t *testing.T, r repositories.ShortURLRepository, opts core.ListOptions) []string {
+		var ids []string
+
+		for i := 0; i < 10; i++ {
+			page, err := r.ListByUserID(ctx, "user1", opts)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(page.ShortURLs), opts.Limit)
+
+			for _, shortURL := range page.ShortURLs {
+				ids = append(ids, shortURL.ID)
+			}
+
+			if page.NextCursor == "" {
+				return ids
+			}
+
+			opts.Cursor = page.NextCursor
+		}
+
+		t.Fatal("too many pages")
+
+		return nil
+	}
+
+	tests := []struct {
+		name string
+		opts core.ListOptions
+		want []string
+	}{
+		{
+			name: "should list by id",
+			opts: core.ListOptions{Limit: 2, SortBy: core.SortByID},
+			want: []string{"page1", "page2", "page3", "page4", "page5"},
+		},
+		{
+			name: "should list by id desc",
+			opts: core.ListOptions{Limit: 2, SortBy: core.SortByID, Desc: true},
+			want: []string{"page5", "page4", "page3", "page2", "page1"},
+		},
+		{
+			name: "should list by created at",
+			opts: core.ListOptions{Limit: 2, SortBy: core.SortByCreatedAt},
+			want: []string{"page2", "page5", "page1", "page3", "page4"},
+		},
+		{
+			name: "should list by created at desc",
+			opts: core.ListOptions{Limit: 2, SortBy: core.SortByCreatedAt, Desc: true},
+			want: []string{"page4", "page3", "page1", "page5", "page2"},
+		},
+		{
+			name: "should list in one page",
+			opts: core.ListOptions{Limit: 5, SortBy: core.SortByCreatedAt},
+			want: []string{"page2", "page5", "page1", "page3", "page4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRepositoryWithURLs(t)
+
+			assert.Equal(t, tt.want, listAll(t, r, tt.opts))
+		})
+	}
+
+	t.Run("should keep created at", func(t *testing.T) {
+		r := newRepositoryWithURLs(t)
+
+		page, err := r.ListByUserID(ctx, "user1", core.ListOptions{Limit: 1})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(page.ShortURLs))
+		assert.True(t, createdAt.Add(30*time.Minute).Equal(page.ShortURLs[0].CreatedAt), page.ShortURLs[0].CreatedAt)
+	})
+
+	t.Run("should return empty page for unknown user", func(t *testing.T) {
+		r := newRepositoryWithURLs(t)
+
+		page, err := r.ListByUserID(ctx, "unknown", core.ListOptions{Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(page.ShortURLs))
+		assert.Equal(t, "", page.NextCursor)
+	})
+
+	t.Run("should reject broken cursor and cursor of other sort", func(t *testing.T) {
+		r := newRepositoryWithURLs(t)
+
+		_, err := r.ListByUserID(ctx, "user1", core.ListOptions{Limit: 2, Cursor: "broken"})
+		assert.ErrorIs(t, err, repositories.ErrInvalidCursor)
+
+		page, err := r.ListByUserID(ctx, "user1", core.ListOptions{Limit: 2})
+		require.NoError(t, err)
+
+		_, err = r.ListByUserID(ctx, "user1", core.ListOptions{Limit: 2, Cursor: page.NextCursor, Desc: true})
+		assert.ErrorIs(t, err, repositories.ErrInvalidCursor)
+	})
+}
+
+func testDeleteExpired(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+	now := time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC)
+
+	withExpiration := func(shortURL *core.ShortURL, expiresAt time.Time) *core.ShortURL {
+		shortURL.ExpiresAt = sql.NullTime{Time: expiresAt, Valid: true}
+
+		return shortURL
+	}
+
+	t.Run("should mark expired urls as deleted", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.CreateBatch(ctx, &[]*core.ShortURL{
+			withExpiration(NewShortURL("exp1", "https://vk.com/exp1", "user1"), now.Add(-time.Hour)),
+			withExpiration(NewShortURL("exp2", "https://vk.com/exp2", ""), now),
+			withExpiration(NewShortURL("exp3", "https://vk.com/exp3", "user1"), now.Add(time.Hour)),
+			NewShortURL("exp4", "https://vk.com/exp4", "user1"),
+		}))
+
+		count, err := r.DeleteExpired(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		for id, isDeleted := range map[string]bool{"exp1": true, "exp2": true, "exp3": false, "exp4": false} {
+			got, ok := r.GetByID(ctx, id)
+
+			require.True(t, ok, id)
+			assert.Equal(t, isDeleted, got.IsDeleted, id)
+		}
+
+		got, ok := r.GetByID(ctx, "exp3")
+		require.True(t, ok)
+		require.True(t, got.ExpiresAt.Valid)
+		assert.True(t, now.Add(time.Hour).Equal(got.ExpiresAt.Time), got.ExpiresAt.Time)
+
+		got, ok = r.GetByID(ctx, "exp4")
+		require.True(t, ok)
+		assert.False(t, got.ExpiresAt.Valid)
+
+		count, err = r.DeleteExpired(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func testGetStats(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+	day := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	statsRange := core.StatsRange{From: day, To: day.AddDate(0, 0, 3)}
+
+	newShortURLAt := func(id, userID string, createdAt time.Time) *core.ShortURL {
+		shortURL := NewShortURL(id, "https://vk.com/"+id, userID)
+		shortURL.CreatedAt = createdAt
+
+		return shortURL
+	}
+
+	t.Run("should return zero for empty repository", func(t *testing.T) {
+		r := newRepository(t)
+
+		got, err := r.GetStats(ctx, statsRange)
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, got.URLs)
+		assert.Equal(t, 0, got.Users)
+		assert.Equal(t, 0, got.DeletedURLs)
+		assert.Equal(t, 0, got.ActiveUsers)
+		assert.Equal(t, []core.DayCount{{Day: day}, {Day: day.AddDate(0, 0, 1)}, {Day: day.AddDate(0, 0, 2)}}, got.CreatedPerDay)
+	})
+
+	t.Run("should count urls and distinct users", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.CreateBatch(ctx, &[]*core.ShortURL{
+			newShortURLAt("stats1", "user1", day.Add(time.Hour)),
+			newShortURLAt("stats2", "user1", day.Add(2*time.Hour)),
+			newShortURLAt("stats3", "user3", day.Add(50*time.Hour)),
+			newShortURLAt("stats4", "", day.Add(50*time.Hour)),
+			newShortURLAt("stats5", "user5", day.Add(-time.Hour)),
+			newShortURLAt("stats6", "user6", day.AddDate(0, 0, 3)),
+		}))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user3", []string{"stats3"}))
+
+		got, err := r.GetStats(ctx, statsRange)
+
+		require.NoError(t, err)
+		assert.Equal(t, 6, got.URLs)
+		assert.Equal(t, 4, got.Users)
+		assert.Equal(t, 1, got.DeletedURLs)
+		assert.Equal(t, 2, got.ActiveUsers)
+		assert.Equal(t, []core.DayCount{
+			{Day: day, Count: 2},
+			{Day: day.AddDate(0, 0, 1)},
+			{Day: day.AddDate(0, 0, 2), Count: 2},
+		}, got.CreatedPerDay)
+		assert.Greater(t, got.StorageSize, int64(0))
+	})
+}
+
+func testConcurrent(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		r := newRepository(t)
+
+		const (
+			users       = 4
+			urlsPerUser = 25
+		)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, users*urlsPerUser*2)
+
+		for u := 0; u < users; u++ {
+			wg.Add(1)
+
+			go func(u int) {
+				defer wg.Done()
+
+				userID := fmt.Sprintf("user%d", u)
+
+				for i := 0; i < urlsPerUser; i++ {
+					id := fmt.Sprintf("c%d_%d", u, i)
+
+					if err := r.Add(ctx, NewShortURL(id, "https://vk.com/"+id, userID)); err != nil {
+						errs <- err
+						continue
+					}
+
+					if _, ok := r.GetByID(ctx, id); !ok {
+						errs <- fmt.Errorf("short url %s not found after add", id)
+					}
+
+					if _, err := r.AllByUserID(ctx, userID); err != nil {
+						errs <- err
+					}
+
+					if i%5 == 0 {
+						if err := r.DeleteURLsUserByIds(ctx, userID, []string{id}); err != nil {
+							errs <- err
+						}
+					}
+				}
+			}(u)
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+
+		for u := 0; u < users; u++ {
+			got, err := r.AllByUserID(ctx, fmt.Sprintf("user%d", u))
+			require.NoError(t, err)
+			require.Equal(t, urlsPerUser, len(got))
+
+			deleted := 0
+
+			for _, shortURL := range got {
+				if shortURL.IsDeleted {
+					deleted++
+				}
+			}
+
+			assert.Equal(t, urlsPerUser/5, deleted)
+		}
+
+		stats, err := r.GetStats(ctx, core.StatsRange{})
+		require.NoError(t, err)
+		assert.Equal(t, users*urlsPerUser, stats.URLs)
+		assert.Equal(t, users, stats.Users)
+		assert.Equal(t, users*urlsPerUser/5, stats.DeletedURLs)
+	})
+}
+
+func testScanAll(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("should iterate by id after cursor", func(t *testing.T) {
+		r := newRepository(t)
+
+		scanner, ok := r.(repositories.ShortURLScanner)
+
+		if !ok {
+			t.Skip("repository does not implement ShortURLScanner")
+		}
+
+		require.NoError(t, r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("scan3", "https://vk.com/scan3", "user1"),
+			NewShortURL("scan1", "https://vk.com/scan1", "user1"),
+			NewShortURL("scan2", "https://vk.com/scan2", ""),
+		}))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"scan3"}))
+
+		var got []*core.ShortURL
+
+		require.NoError(t, scanner.ScanAll(ctx, "", func(shortURL *core.ShortURL) error {
+			got = append(got, shortURL)
+			return nil
+		}))
+
+		require.Equal(t, 3, len(got))
+		assert.Equal(t, "scan1", got[0].ID)
+		assert.Equal(t, "user1", got[0].UserID.String)
+		assert.False(t, got[1].UserID.Valid)
+		assert.Equal(t, "scan3", got[2].ID)
+		assert.True(t, got[2].IsDeleted)
+
+		var ids []string
+
+		require.NoError(t, scanner.ScanAll(ctx, "scan1", func(shortURL *core.ShortURL) error {
+			ids = append(ids, shortURL.ID)
+			return nil
+		}))
+
+		assert.Equal(t, []string{"scan2", "scan3"}, ids)
+	})
+}
+
+func testEventsAfter(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	newEventRepository := func(t *testing.T) (repositories.ShortURLRepository, repositories.EventRepository) {
+		r := newRepository(t)
+		events, ok := r.(repositories.EventRepository)
+
+		if !ok {
+			t.Skip("repository does not implement EventRepository")
+		}
+
+		return r, events
+	}
+
+	t.Run("should write event of every change in order", func(t *testing.T) {
+		r, events := newEventRepository(t)
+		now := time.Now()
+
+		expiring := NewShortURL("event3", "https://vk.com/event3", "")
+		expiring.ExpiresAt = sql.NullTime{Time: now.Add(-time.Minute), Valid: true}
+
+		require.NoError(t, r.Add(ctx, NewShortURL("event1", "https://vk.com/event1", "user1")))
+		require.Error(t, r.Add(ctx, NewShortURL("event1", "https://vk.com/conflict", "user1")))
+		require.NoError(t, r.CreateBatch(ctx, &[]*core.ShortURL{
+			NewShortURL("event2", "https://vk.com/event2", "user2"),
+			expiring,
+		}))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"event1", "event2"}))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"event1"}))
+
+		count, err := r.DeleteExpired(ctx, now)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		got, err := events.EventsAfter(ctx, 0, 100)
+		require.NoError(t, err)
+
+		type change struct {
+			eventType  core.EventType
+			shortURLID string
+			userID     string
+		}
+
+		var changes []change
+
+		for i, event := range got {
+			changes = append(changes, change{event.Type, event.ShortURLID, event.UserID})
+
+			assert.False(t, event.CreatedAt.IsZero())
+
+			if i > 0 {
+				assert.Greater(t, event.ID, got[i-1].ID)
+			}
+		}
+
+		assert.Equal(t, []change{
+			{core.EventCreated, "event1", "user1"},
+			{core.EventCreated, "event2", "user2"},
+			{core.EventCreated, "event3", ""},
+			{core.EventDeleted, "event1", "user1"},
+			{core.EventExpired, "event3", ""},
+		}, changes)
+		assert.Equal(t, "https://vk.com/event1", got[0].URL)
+	})
+
+	t.Run("should read feed by cursor", func(t *testing.T) {
+		r, events := newEventRepository(t)
+
+		for i := 0; i < 5; i++ {
+			id := fmt.Sprintf("cursor%d", i)
+			require.NoError(t, r.Add(ctx, NewShortURL(id, "https://vk.com/"+id, "user1")))
+		}
+
+		first, err := events.EventsAfter(ctx, 0, 2)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(first))
+		assert.Equal(t, "cursor0", first[0].ShortURLID)
+		assert.Equal(t, "cursor1", first[1].ShortURLID)
+
+		next, err := events.EventsAfter(ctx, first[1].ID, 10)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(next))
+		assert.Equal(t, "cursor2", next[0].ShortURLID)
+		assert.Equal(t, "cursor4", next[2].ShortURLID)
+
+		rest, err := events.EventsAfter(ctx, next[2].ID, 10)
+		require.NoError(t, err)
+		assert.Empty(t, rest)
+	})
+}
+
+// NewURLChange helper for change of url of short url by user
+func NewURLChange(shortURLID, userID, url string) *core.URLChange {
+	return &core.URLChange{
+		ShortURLID: shortURLID,
+		UserID:     userID,
+		NewURL:     url,
+		ChangedAt:  time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func testUpdateURL(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+
+	t.Run("should change url and record changes in order", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("update1", "https://vk.com/update1", "user1")))
+
+		first := NewURLChange("update1", "user1", "https://vk.com/update2")
+		updated, err := r.UpdateURL(ctx, first, core.RedirectUpdate{})
+		require.NoError(t, err)
+
+		assert.Equal(t, "update1", updated.ID)
+		assert.Equal(t, "https://vk.com/update2", updated.URL)
+		assert.Equal(t, "https://vk.com/update1", first.OldURL)
+		assert.NotZero(t, first.ID)
+
+		second := NewURLChange("update1", "user1", "https://vk.com/update3")
+		_, err = r.UpdateURL(ctx, second, core.RedirectUpdate{})
+		require.NoError(t, err)
+		assert.Greater(t, second.ID, first.ID)
+
+		got, ok := r.GetByID(ctx, "update1")
+		require.True(t, ok)
+		assert.Equal(t, "https://vk.com/update3", got.URL)
+
+		changes, err := r.URLChanges(ctx, "update1")
+		require.NoError(t, err)
+		require.Equal(t, 2, len(changes))
+
+		assert.Equal(t, first.ID, changes[0].ID)
+		assert.Equal(t, "update1", changes[0].ShortURLID)
+		assert.Equal(t, "user1", changes[0].UserID)
+		assert.Equal(t, "https://vk.com/update1", changes[0].OldURL)
+		assert.Equal(t, "https://vk.com/update2", changes[0].NewURL)
+		assert.True(t, first.ChangedAt.Equal(changes[0].ChangedAt))
+		assert.Equal(t, "https://vk.com/update2", changes[1].OldURL)
+		assert.Equal(t, "https://vk.com/update3", changes[1].NewURL)
+
+		// Прежний url освобождается и может быть сокращен заново
+		require.NoError(t, r.Add(ctx, NewShortURL("update2", "https://vk.com/update1", "user2")))
+
+		err = r.Add(ctx, NewShortURL("update3", "https://vk.com/update3", "user2"))
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.True(t, errors.As(err, &conflictError))
+		assert.Equal(t, "update1", conflictError.OriginID)
+	})
+
+	t.Run("should not record change to same url", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("same1", "https://vk.com/same1", "user1")))
+
+		change := NewURLChange("same1", "user1", "https://vk.com/same1")
+		updated, err := r.UpdateURL(ctx, change, core.RedirectUpdate{})
+		require.NoError(t, err)
+
+		assert.Equal(t, "https://vk.com/same1", updated.URL)
+		assert.Zero(t, change.ID)
+
+		changes, err := r.URLChanges(ctx, "same1")
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("should return conflict for url of other short url", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("conflict1", "https://vk.com/conflict1", "user1")))
+		require.NoError(t, r.Add(ctx, NewShortURL("conflict2", "https://vk.com/conflict2", "user2")))
+
+		_, err := r.UpdateURL(ctx, NewURLChange("conflict1", "user1", "https://vk.com/conflict2"), core.RedirectUpdate{})
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.True(t, errors.As(err, &conflictError), "error %v", err)
+		assert.Equal(t, "conflict2", conflictError.OriginID)
+
+		got, ok := r.GetByID(ctx, "conflict1")
+		require.True(t, ok)
+		assert.Equal(t, "https://vk.com/conflict1", got.URL)
+	})
+
+	t.Run("should change to url of deleted short url", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("free1", "https://vk.com/free1", "user1")))
+		require.NoError(t, r.Add(ctx, NewShortURL("free2", "https://vk.com/free2", "user2")))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user2", []string{"free2"}))
+
+		updated, err := r.UpdateURL(ctx, NewURLChange("free1", "user1", "https://vk.com/free2"), core.RedirectUpdate{})
+		require.NoError(t, err)
+		assert.Equal(t, "https://vk.com/free2", updated.URL)
+
+		err = r.Add(ctx, NewShortURL("free3", "https://vk.com/free2", "user2"))
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.ErrorAs(t, err, &conflictError)
+		assert.Equal(t, "free1", conflictError.OriginID)
+	})
+
+	t.Run("should change url and redirect options together", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("both1", "https://vk.com/both1", "user1")))
+
+		redirectType := http.StatusMovedPermanently
+		maxAge := sql.NullInt64{Int64: 60, Valid: true}
+		redirect := core.RedirectUpdate{RedirectType: &redirectType, CacheMaxAge: &maxAge}
+
+		updated, err := r.UpdateURL(ctx, NewURLChange("both1", "user1", "https://vk.com/both2"), redirect)
+		require.NoError(t, err)
+		assert.Equal(t, "https://vk.com/both2", updated.URL)
+		assert.Equal(t, http.StatusMovedPermanently, updated.RedirectType)
+		assert.Equal(t, maxAge, updated.CacheMaxAge)
+
+		got, ok := r.GetByID(ctx, "both1")
+		require.True(t, ok)
+		assert.Equal(t, "https://vk.com/both2", got.URL)
+		assert.Equal(t, http.StatusMovedPermanently, got.RedirectType)
+		assert.Equal(t, maxAge, got.CacheMaxAge)
+
+		redirectType = http.StatusFound
+		updated, err = r.UpdateURL(ctx, NewURLChange("both1", "user1", "https://vk.com/both2"), core.RedirectUpdate{RedirectType: &redirectType})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusFound, updated.RedirectType)
+		assert.Equal(t, maxAge, updated.CacheMaxAge)
+
+		got, ok = r.GetByID(ctx, "both1")
+		require.True(t, ok)
+		assert.Equal(t, http.StatusFound, got.RedirectType)
+		assert.Equal(t, maxAge, got.CacheMaxAge)
+	})
+
+	t.Run("should not change redirect options on url conflict", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("keep1", "https://vk.com/keep1", "user1")))
+		require.NoError(t, r.Add(ctx, NewShortURL("keep2", "https://vk.com/keep2", "user1")))
+
+		redirectType := http.StatusMovedPermanently
+		_, err := r.UpdateURL(ctx, NewURLChange("keep1", "user1", "https://vk.com/keep2"), core.RedirectUpdate{RedirectType: &redirectType})
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.ErrorAs(t, err, &conflictError)
+
+		got, ok := r.GetByID(ctx, "keep1")
+		require.True(t, ok)
+		assert.Equal(t, "https://vk.com/keep1", got.URL)
+		assert.Equal(t, 0, got.RedirectType)
+	})
+
+	t.Run("should check owner and deleted", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("owner1", "https://vk.com/owner1", "user1")))
+		require.NoError(t, r.Add(ctx, NewShortURL("owner2", "https://vk.com/owner2", "")))
+		require.NoError(t, r.Add(ctx, NewShortURL("owner3", "https://vk.com/owner3", "user1")))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"owner3"}))
+
+		_, err := r.UpdateURL(ctx, NewURLChange("unknown", "user1", "https://vk.com/new"), core.RedirectUpdate{})
+		assert.ErrorIs(t, err, core.ErrShortURLNotFound)
+
+		_, err = r.UpdateURL(ctx, NewURLChange("owner1", "user2", "https://vk.com/new"), core.RedirectUpdate{})
+		assert.ErrorIs(t, err, core.ErrNotOwner)
+
+		_, err = r.UpdateURL(ctx, NewURLChange("owner2", "user1", "https://vk.com/new"), core.RedirectUpdate{})
+		assert.ErrorIs(t, err, core.ErrNotOwner)
+
+		_, err = r.UpdateURL(ctx, NewURLChange("owner3", "user1", "https://vk.com/new"), core.RedirectUpdate{})
+		assert.ErrorIs(t, err, core.ErrShortURLDeleted)
+
+		got, ok := r.GetByID(ctx, "owner1")
+		require.True(t, ok)
+		assert.Equal(t, "https://vk.com/owner1", got.URL)
+	})
+
+	t.Run("should keep changes after delete", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("history1", "https://vk.com/history1", "user1")))
+
+		expired := NewShortURL("history2", "https://vk.com/history2", "user1")
+		expired.ExpiresAt = sql.NullTime{Time: time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond), Valid: true}
+		require.NoError(t, r.Add(ctx, expired))
+
+		for _, id := range []string{"history1", "history2"} {
+			_, err := r.UpdateURL(ctx, NewURLChange(id, "user1", "https://vk.com/"+id+"-new"), core.RedirectUpdate{})
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"history1"}))
+
+		_, err := r.DeleteExpired(ctx, time.Now().Add(2*time.Hour))
+		require.NoError(t, err)
+
+		for _, id := range []string{"history1", "history2"} {
+			changes, err := r.URLChanges(ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(changes), id)
+			assert.Equal(t, "https://vk.com/"+id, changes[0].OldURL)
+		}
+	})
+
+	t.Run("should write updated event", func(t *testing.T) {
+		r := newRepository(t)
+		events, ok := r.(repositories.EventRepository)
+
+		if !ok {
+			t.Skip("repository does not implement EventRepository")
+		}
+
+		require.NoError(t, r.Add(ctx, NewShortURL("feed1", "https://vk.com/feed1", "user1")))
+
+		_, err := r.UpdateURL(ctx, NewURLChange("feed1", "user1", "https://vk.com/feed2"), core.RedirectUpdate{})
+		require.NoError(t, err)
+
+		got, err := events.EventsAfter(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(got))
+
+		assert.Equal(t, core.EventUpdated, got[1].Type)
+		assert.Equal(t, "feed1", got[1].ShortURLID)
+		assert.Equal(t, "https://vk.com/feed2", got[1].URL)
+		assert.Equal(t, "user1", got[1].UserID)
+	})
+}
+
+func testSetRedirect(t *testing.T, newRepository Factory) {
+	ctx := context.Background()
+	maxAge := sql.NullInt64{Int64: 3600, Valid: true}
+
+	t.Run("should keep redirect options of created short urls", func(t *testing.T) {
+		r := newRepository(t)
+
+		added := NewShortURL("redirect1", "https://vk.com/redirect1", "user1")
+		added.RedirectType = http.StatusMovedPermanently
+		added.CacheMaxAge = maxAge
+		require.NoError(t, r.Add(ctx, added))
+
+		batched := NewShortURL("redirect2", "https://vk.com/redirect2", "user1")
+		batched.RedirectType = http.StatusFound
+		batched.CacheMaxAge = sql.NullInt64{Valid: true}
+		shortURLs := []*core.ShortURL{batched, NewShortURL("redirect3", "https://vk.com/redirect3", "user1")}
+		require.NoError(t, r.CreateBatch(ctx, &shortURLs))
+
+		got, ok := r.GetByID(ctx, "redirect1")
+		require.True(t, ok)
+		assert.Equal(t, http.StatusMovedPermanently, got.RedirectType)
+		assert.Equal(t, maxAge, got.CacheMaxAge)
+
+		got, ok = r.GetByID(ctx, "redirect2")
+		require.True(t, ok)
+		assert.Equal(t, http.StatusFound, got.RedirectType)
+		assert.Equal(t, sql.NullInt64{Valid: true}, got.CacheMaxAge)
+
+		got, ok = r.GetByID(ctx, "redirect3")
+		require.True(t, ok)
+		assert.Equal(t, 0, got.RedirectType)
+		assert.False(t, got.CacheMaxAge.Valid)
+
+		page, err := r.ListByUserID(ctx, "user1", core.ListOptions{Limit: 10, SortBy: core.SortByID})
+		require.NoError(t, err)
+		require.Equal(t, 3, len(page.ShortURLs))
+		assert.Equal(t, http.StatusMovedPermanently, page.ShortURLs[0].RedirectType)
+		assert.Equal(t, maxAge, page.ShortURLs[0].CacheMaxAge)
+	})
+
+	t.Run("should change and reset redirect options", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("redirect1", "https://vk.com/redirect1", "user1")))
+
+		redirectType := http.StatusPermanentRedirect
+		updated, err := r.SetRedirect(ctx, "user1", "redirect1", core.RedirectUpdate{RedirectType: &redirectType, CacheMaxAge: &maxAge})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusPermanentRedirect, updated.RedirectType)
+		assert.Equal(t, "https://vk.com/redirect1", updated.URL)
+
+		got, ok := r.GetByID(ctx, "redirect1")
+		require.True(t, ok)
+		assert.Equal(t, http.StatusPermanentRedirect, got.RedirectType)
+		assert.Equal(t, maxAge, got.CacheMaxAge)
+
+		redirectType = 0
+		_, err = r.SetRedirect(ctx, "user1", "redirect1", core.RedirectUpdate{RedirectType: &redirectType, CacheMaxAge: &sql.NullInt64{}})
+		require.NoError(t, err)
+
+		got, ok = r.GetByID(ctx, "redirect1")
+		require.True(t, ok)
+		assert.Equal(t, 0, got.RedirectType)
+		assert.False(t, got.CacheMaxAge.Valid)
+
+		// Прежний url остается занят этой ссылкой
+		err = r.Add(ctx, NewShortURL("redirect2", "https://vk.com/redirect1", "user2"))
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.True(t, errors.As(err, &conflictError))
+		assert.Equal(t, "redirect1", conflictError.OriginID)
+
+		changes, err := r.URLChanges(ctx, "redirect1")
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("should keep fields not set in update", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("merge1", "https://vk.com/merge1", "user1")))
+
+		redirectType := http.StatusMovedPermanently
+		_, err := r.SetRedirect(ctx, "user1", "merge1", core.RedirectUpdate{RedirectType: &redirectType})
+		require.NoError(t, err)
+
+		updated, err := r.SetRedirect(ctx, "user1", "merge1", core.RedirectUpdate{CacheMaxAge: &maxAge})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusMovedPermanently, updated.RedirectType)
+		assert.Equal(t, maxAge, updated.CacheMaxAge)
+
+		got, ok := r.GetByID(ctx, "merge1")
+		require.True(t, ok)
+		assert.Equal(t, http.StatusMovedPermanently, got.RedirectType)
+		assert.Equal(t, maxAge, got.CacheMaxAge)
+	})
+
+	t.Run("should keep both of concurrent updates of different fields", func(t *testing.T) {
+		r := newRepository(t)
+
+		require.NoError(t, r.Add(ctx, NewShortURL("race1", "https://vk.com/race1", "user1")))
+
+		redirectType := http.StatusFound
+		updates := []core.RedirectUpdate{{RedirectType: &redirectType}, {CacheMaxAge: &maxAge}}
+
+		var wg sync.WaitGroup
+
+		for _, update := range updates {
+			wg.Add(1)
+
+			go func(update core.RedirectUpdate) {
+				defer wg.Done()
+
+				_, err := r.SetRedirect(ctx, "user1", "race1", update)
+				assert.NoError(t, err)
+			}(update)
+		}
+
+		wg.Wait()
+
+		got, ok := r.GetByID(ctx, "race1")
+		require.True(t, ok)
+		assert.Equal(t, http.StatusFound, got.RedirectType)
+		assert.Equal(t, maxAge, got.CacheMaxAge)
+	})
+
+	t.Run("should check owner and deleted", func(t *testing.T) {
+		r := newRepository(t)
+		redirectType := http.StatusMovedPermanently
+		opts := core.RedirectUpdate{RedirectType: &redirectType}
+
+		require.NoError(t, r.Add(ctx, NewShortURL("owner1", "https://vk.com/owner1", "user1")))
+		require.NoError(t, r.Add(ctx, NewShortURL("owner2", "https://vk.com/owner2", "user1")))
+		require.NoError(t, r.DeleteURLsUserByIds(ctx, "user1", []string{"owner2"}))
+
+		_, err := r.SetRedirect(ctx, "user1", "unknown", opts)
+		assert.ErrorIs(t, err, core.ErrShortURLNotFound)
+
+		_, err = r.SetRedirect(ctx, "user2", "owner1", opts)
+		assert.ErrorIs(t, err, core.ErrNotOwner)
+
+		_, err = r.SetRedirect(ctx, "user1", "owner2", opts)
+		assert.ErrorIs(t, err, core.ErrShortURLDeleted)
+
+		got, ok := r.GetByID(ctx, "owner1")
+		require.True(t, ok)
+		assert.Equal(t, 0, got.RedirectType)
+	})
+}
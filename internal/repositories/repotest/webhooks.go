@@ -0,0 +1,129 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+// WebhookFactory create new empty repository of webhooks for every test case
+type WebhookFactory func(t *testing.T) repositories.WebhookRepository
+
+// NewWebhook helper for create webhook of user subscribed on events
+func NewWebhook(id, userID string, events ...core.EventType) *core.Webhook {
+	return &core.Webhook{
+		ID:        id,
+		UserID:    userID,
+		URL:       "https://example.com/" + id,
+		Secret:    "secret-" + id,
+		Events:    events,
+		CreatedAt: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// RunWebhooks all conformance tests for repository of webhooks
+func RunWebhooks(t *testing.T, newRepository WebhookFactory) {
+	ctx := context.Background()
+
+	t.Run("should list webhooks of user in order of creation", func(t *testing.T) {
+		s := newRepository(t)
+
+		later := NewWebhook("hook1", "user1", core.EventDeleted)
+		later.CreatedAt = later.CreatedAt.Add(time.Minute)
+
+		require.NoError(t, s.AddWebhook(ctx, NewWebhook("hook2", "user1", core.EventCreated, core.EventClicked)))
+		require.NoError(t, s.AddWebhook(ctx, later))
+		require.NoError(t, s.AddWebhook(ctx, NewWebhook("hook3", "user2", core.EventCreated)))
+
+		got, err := s.ListWebhooks(ctx, "user1")
+		require.NoError(t, err)
+		require.Equal(t, 2, len(got))
+
+		assert.Equal(t, "hook2", got[0].ID)
+		assert.Equal(t, "user1", got[0].UserID)
+		assert.Equal(t, "https://example.com/hook2", got[0].URL)
+		assert.Equal(t, "secret-hook2", got[0].Secret)
+		assert.Equal(t, []core.EventType{core.EventCreated, core.EventClicked}, got[0].Events)
+		assert.True(t, got[0].CreatedAt.Equal(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)), got[0].CreatedAt)
+		assert.Equal(t, "hook1", got[1].ID)
+
+		empty, err := s.ListWebhooks(ctx, "user3")
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+	})
+
+	t.Run("should delete only own webhook", func(t *testing.T) {
+		s := newRepository(t)
+
+		require.NoError(t, s.AddWebhook(ctx, NewWebhook("hook1", "user1", core.EventCreated)))
+
+		assert.ErrorIs(t, s.DeleteWebhook(ctx, "user2", "hook1"), core.ErrWebhookNotFound)
+		assert.ErrorIs(t, s.DeleteWebhook(ctx, "user1", "hook2"), core.ErrWebhookNotFound)
+		require.NoError(t, s.DeleteWebhook(ctx, "user1", "hook1"))
+
+		got, err := s.ListWebhooks(ctx, "user1")
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("should disable webhook after failed deliveries in a row", func(t *testing.T) {
+		s := newRepository(t)
+
+		require.NoError(t, s.AddWebhook(ctx, NewWebhook("hook1", "user1", core.EventCreated)))
+
+		disabled, err := s.MarkWebhookFailed(ctx, "hook1", 2)
+		require.NoError(t, err)
+		assert.False(t, disabled)
+
+		require.NoError(t, s.MarkWebhookDelivered(ctx, "hook1"))
+
+		disabled, err = s.MarkWebhookFailed(ctx, "hook1", 2)
+		require.NoError(t, err)
+		assert.False(t, disabled)
+
+		got, err := s.ListWebhooks(ctx, "user1")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(got))
+		assert.Equal(t, 1, got[0].Failures)
+		assert.False(t, got[0].Disabled)
+
+		disabled, err = s.MarkWebhookFailed(ctx, "hook1", 2)
+		require.NoError(t, err)
+		assert.True(t, disabled)
+
+		got, err = s.ListWebhooks(ctx, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, 2, got[0].Failures)
+		assert.True(t, got[0].Disabled)
+	})
+
+	t.Run("should ignore state of unknown webhook", func(t *testing.T) {
+		s := newRepository(t)
+
+		disabled, err := s.MarkWebhookFailed(ctx, "unknown", 1)
+		require.NoError(t, err)
+		assert.False(t, disabled)
+		require.NoError(t, s.MarkWebhookDelivered(ctx, "unknown"))
+	})
+
+	t.Run("should save cursor of delivery", func(t *testing.T) {
+		s := newRepository(t)
+
+		cursor, err := s.WebhookCursor(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), cursor)
+
+		require.NoError(t, s.SaveWebhookCursor(ctx, 42))
+		require.NoError(t, s.SaveWebhookCursor(ctx, 43))
+
+		cursor, err = s.WebhookCursor(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(43), cursor)
+	})
+}
@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// CheckURLChange check short url exists, owned by user and isn't deleted or expired. Nil shortURL mean not found
+func CheckURLChange(shortURL *core.ShortURL, userID string) error {
+	switch {
+	case shortURL == nil:
+		return core.ErrShortURLNotFound
+	case !shortURL.UserID.Valid || shortURL.UserID.String != userID:
+		return core.ErrNotOwner
+	case !shortURL.IsActive(time.Now()):
+		return core.ErrShortURLDeleted
+	default:
+		return nil
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
@@ -14,6 +15,7 @@ import (
 	"github.com/shreyner/go-shortener/internal/core"
 	"github.com/shreyner/go-shortener/internal/middlewares"
 	"github.com/shreyner/go-shortener/internal/pkg/fans"
+	"github.com/shreyner/go-shortener/internal/repositories"
 	sdb "github.com/shreyner/go-shortener/internal/storage/store_errors"
 	pb "github.com/shreyner/go-shortener/proto"
 )
@@ -23,31 +25,41 @@ var (
 )
 
 type shortedService interface {
-	Create(ctx context.Context, userID, url string) (*core.ShortURL, error)
+	Create(ctx context.Context, userID, url string, opts core.CreateOptions) (*core.ShortURL, error)
 	CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error
 	GetByID(ctx context.Context, key string) (*core.ShortURL, bool)
 	AllByUser(ctx context.Context, id string) ([]*core.ShortURL, error)
+	ListByUser(ctx context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error)
+	Update(ctx context.Context, userID, id, url string, redirect core.RedirectUpdate) (*core.ShortURL, error)
+	SetRedirect(ctx context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error)
+}
+
+type clickStatsService interface {
+	GetStats(ctx context.Context, userID, id string, statsRange core.StatsRange) (*core.ClickStats, error)
 }
 
 // ShortenerServer base shortner handler for grpc server
 type ShortenerServer struct {
 	pb.UnimplementedShortenerServer
 
-	log              *zap.Logger
-	service          shortedService
-	fansShortService *fans.FansShortService
+	log               *zap.Logger
+	service           shortedService
+	clickStatsService clickStatsService
+	fansShortService  *fans.FansShortService
 }
 
 // NewShortenerServer constructor
 func NewShortenerServer(
 	log *zap.Logger,
 	service shortedService,
+	clickStatsService clickStatsService,
 	fansShortService *fans.FansShortService,
 ) *ShortenerServer {
 	return &ShortenerServer{
-		log:              log,
-		service:          service,
-		fansShortService: fansShortService,
+		log:               log,
+		service:           service,
+		clickStatsService: clickStatsService,
+		fansShortService:  fansShortService,
 	}
 }
 
@@ -69,7 +81,31 @@ func (s *ShortenerServer) CreateShort(
 		return &response, nil
 	}
 
-	shortURL, err := s.service.Create(ctx, userID, in.Url)
+	expiresAt, err := core.ParseExpiration(in.Ttl, in.ExpiresAt, time.Now())
+
+	if err != nil {
+		response.Error = err.Error()
+		return &response, nil
+	}
+
+	shortURL, err := s.service.Create(ctx, userID, in.Url, core.CreateOptions{
+		ExpiresAt: expiresAt,
+		Alias:     in.Alias,
+		RedirectOptions: core.RedirectOptions{
+			RedirectType: int(in.RedirectType),
+			CacheMaxAge:  core.NewCacheMaxAge(in.CacheMaxAge),
+		},
+	})
+
+	if errors.Is(err, core.ErrInvalidAlias) || core.IsInvalidRedirect(err) {
+		response.Error = err.Error()
+		return &response, nil
+	}
+
+	var shortURLIDConflictError *sdb.ShortURLIDConflictError
+	if errors.As(err, &shortURLIDConflictError) {
+		return nil, status.Errorf(codes.AlreadyExists, "alias %s is taken", shortURLIDConflictError.ID)
+	}
 
 	var shortURLCreateConflictError *sdb.ShortURLCreateConflictError
 	if errors.As(err, &shortURLCreateConflictError) {
@@ -97,6 +133,7 @@ func (s *ShortenerServer) CreateBatchShort(
 	var response pb.CreateBatchShortResponse
 
 	shoredURLs := make([]*core.ShortURL, len(in.Urls))
+	now := time.Now()
 
 	for i, v := range in.Urls {
 		_, err := url.Parse(v.Url)
@@ -106,19 +143,47 @@ func (s *ShortenerServer) CreateBatchShort(
 			return &response, nil
 		}
 
+		expiresAt, err := core.ParseExpiration(v.Ttl, v.ExpiresAt, now)
+
+		if err != nil {
+			response.Error = fmt.Sprintf("%s for CorrelationID: %v", err, v.CorrelationId)
+			return &response, nil
+		}
+
 		shortURL := core.ShortURL{
+			ID:  v.Alias,
 			URL: v.Url,
 			UserID: sql.NullString{
 				String: userID,
 				Valid:  userID != "",
 			},
 			CorrelationID: v.CorrelationId,
+			ExpiresAt:     expiresAt,
+			RedirectType:  int(v.RedirectType),
+			CacheMaxAge:   core.NewCacheMaxAge(v.CacheMaxAge),
 		}
 
 		shoredURLs[i] = &shortURL
 	}
 
-	if err := s.service.CreateBatch(ctx, &shoredURLs); err != nil {
+	err := s.service.CreateBatch(ctx, &shoredURLs)
+
+	if errors.Is(err, core.ErrInvalidAlias) || core.IsInvalidRedirect(err) {
+		response.Error = err.Error()
+		return &response, nil
+	}
+
+	var shortURLIDConflictError *sdb.ShortURLIDConflictError
+	if errors.As(err, &shortURLIDConflictError) {
+		return nil, status.Errorf(codes.AlreadyExists, "alias %s is taken", shortURLIDConflictError.ID)
+	}
+
+	var shortURLCreateConflictError *sdb.ShortURLCreateConflictError
+	if errors.As(err, &shortURLCreateConflictError) {
+		return nil, status.Errorf(codes.AlreadyExists, "url is shorted as %s", shortURLCreateConflictError.OriginID)
+	}
+
+	if err != nil {
 		s.log.Error("unhandled error when create short url", zap.Error(err))
 		return nil, status.Error(codes.Internal, "unhandled error when create short url")
 	}
@@ -139,10 +204,10 @@ func (s *ShortenerServer) CreateBatchShort(
 	return &response, nil
 }
 
-// ListUserURLs return list shorted was created user
+// ListUserURLs return list shorted was created user. With limit, cursor or sort return page
 func (s *ShortenerServer) ListUserURLs(
 	ctx context.Context,
-	_ *pb.ListUserURLsRequest,
+	in *pb.ListUserURLsRequest,
 ) (*pb.ListUserURLsResponse, error) {
 	userID, ok := middlewares.GetUserIDCtx(ctx)
 	var listUserURLsResponse pb.ListUserURLsResponse
@@ -152,11 +217,45 @@ func (s *ShortenerServer) ListUserURLs(
 		return nil, status.Error(codes.Unauthenticated, "missing token")
 	}
 
-	list, err := s.service.AllByUser(ctx, userID)
+	var list []*core.ShortURL
 
-	if err != nil {
-		s.log.Error("unhandled error when get list urls by userID", zap.Error(err))
-		return nil, status.Error(codes.Internal, "unhandled error")
+	if in.Limit != 0 || in.Cursor != "" || in.Sort != "" {
+		if in.Limit < 0 || in.Limit > repositories.MaxPageLimit {
+			return nil, status.Errorf(codes.InvalidArgument, "limit should be from 1 to %d", repositories.MaxPageLimit)
+		}
+
+		sortBy, desc, err := core.ParseSort(in.Sort)
+
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		page, err := s.service.ListByUser(ctx, userID, core.ListOptions{
+			Limit:  int(in.Limit),
+			Cursor: in.Cursor,
+			SortBy: sortBy,
+			Desc:   desc,
+		})
+
+		if errors.Is(err, repositories.ErrInvalidCursor) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		if err != nil {
+			s.log.Error("unhandled error when get page urls by userID", zap.Error(err))
+			return nil, status.Error(codes.Internal, "unhandled error")
+		}
+
+		list = page.ShortURLs
+		listUserURLsResponse.NextCursor = page.NextCursor
+	} else {
+		var err error
+		list, err = s.service.AllByUser(ctx, userID)
+
+		if err != nil {
+			s.log.Error("unhandled error when get list urls by userID", zap.Error(err))
+			return nil, status.Error(codes.Internal, "unhandled error")
+		}
 	}
 
 	responseList := make([]*pb.ListUserURLsResponse_URL, len(list))
@@ -193,3 +292,147 @@ func (s *ShortenerServer) DeleteByIDs(
 
 	return &deleteByIDsResponse, nil
 }
+
+// UpdateShort change original url, redirect type or max-age of short url of current user. Empty url keeps original url.
+// On conflict error contains ID of short url with this url
+func (s *ShortenerServer) UpdateShort(
+	ctx context.Context,
+	in *pb.UpdateShortRequest,
+) (*pb.UpdateShortResponse, error) {
+	userID, ok := middlewares.GetUserIDCtx(ctx)
+
+	if !ok || userID == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+
+	var redirectType *int
+
+	if in.RedirectType != nil {
+		value := int(*in.RedirectType)
+		redirectType = &value
+	}
+
+	redirectUpdate, err := core.NewRedirectUpdate(redirectType, in.CacheMaxAge, in.ClearCacheMaxAge)
+
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if in.Url == "" && redirectUpdate.IsEmpty() {
+		return nil, status.Error(codes.InvalidArgument, "url, redirectType or cacheMaxAge is required")
+	}
+
+	if in.Url != "" {
+		if _, err := url.ParseRequestURI(in.Url); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid url")
+		}
+	}
+
+	var shortURL *core.ShortURL
+
+	if in.Url != "" {
+		shortURL, err = s.service.Update(ctx, userID, in.Id, in.Url, redirectUpdate)
+	} else if !redirectUpdate.IsEmpty() {
+		shortURL, err = s.service.SetRedirect(ctx, userID, in.Id, redirectUpdate)
+	}
+
+	if core.IsInvalidRedirect(err) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if errors.Is(err, core.ErrShortURLNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	if errors.Is(err, core.ErrNotOwner) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if errors.Is(err, core.ErrShortURLDeleted) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	var shortURLCreateConflictError *sdb.ShortURLCreateConflictError
+	if errors.As(err, &shortURLCreateConflictError) {
+		return nil, status.Errorf(codes.AlreadyExists, "url is shorted as %s", shortURLCreateConflictError.OriginID)
+	}
+
+	if err != nil {
+		s.log.Error("unhandled error when update short url", zap.String("id", in.Id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unhandled error")
+	}
+
+	response := pb.UpdateShortResponse{
+		Id:           shortURL.ID,
+		OriginalURL:  shortURL.URL,
+		RedirectType: int32(shortURL.RedirectStatus()),
+	}
+
+	if shortURL.CacheMaxAge.Valid {
+		response.CacheMaxAge = &shortURL.CacheMaxAge.Int64
+	}
+
+	return &response, nil
+}
+
+// GetURLStats return stats of clicks by short url of current user
+func (s *ShortenerServer) GetURLStats(
+	ctx context.Context,
+	in *pb.GetURLStatsRequest,
+) (*pb.GetURLStatsResponse, error) {
+	userID, ok := middlewares.GetUserIDCtx(ctx)
+
+	if !ok || userID == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+
+	statsRange, err := core.ParseStatsRange(in.From, in.To, time.Now())
+
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	stats, err := s.clickStatsService.GetStats(ctx, userID, in.Id, statsRange)
+
+	if errors.Is(err, core.ErrShortURLNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	if errors.Is(err, core.ErrNotOwner) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if err != nil {
+		s.log.Error("unhandled error when get click stats", zap.String("id", in.Id), zap.Error(err))
+		return nil, status.Error(codes.Internal, "unhandled error")
+	}
+
+	response := pb.GetURLStatsResponse{
+		From:           statsRange.From.Format(core.StatsDayLayout),
+		To:             statsRange.To.AddDate(0, 0, -1).Format(core.StatsDayLayout),
+		Total:          int64(stats.Total),
+		UniqueVisitors: int64(stats.UniqueVisitors),
+		Days:           make([]*pb.GetURLStatsResponse_Day, len(stats.Days)),
+		TopReferrers:   newValueCounts(stats.TopReferrers),
+		TopUserAgents:  newValueCounts(stats.TopUserAgents),
+	}
+
+	for i, day := range stats.Days {
+		response.Days[i] = &pb.GetURLStatsResponse_Day{
+			Day:    day.Day.Format(core.StatsDayLayout),
+			Clicks: int64(day.Count),
+		}
+	}
+
+	return &response, nil
+}
+
+func newValueCounts(values []core.ValueCount) []*pb.GetURLStatsResponse_ValueCount {
+	result := make([]*pb.GetURLStatsResponse_ValueCount, len(values))
+
+	for i, value := range values {
+		result[i] = &pb.GetURLStatsResponse_ValueCount{Value: value.Value, Count: int64(value.Count)}
+	}
+
+	return result
+}
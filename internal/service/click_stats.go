@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+// clickStatsTop count of top referrers and user agents in stats
+var clickStatsTop = 10
+
+// ClickStatsService statistic of clicks by short urls for owners
+type ClickStatsService struct {
+	shorterRepository repositories.ShortURLRepository
+	clickRepository   repositories.ClickRepository
+}
+
+// NewClickStatsService create service
+func NewClickStatsService(
+	shorterRepository repositories.ShortURLRepository,
+	clickRepository repositories.ClickRepository,
+) *ClickStatsService {
+	return &ClickStatsService{
+		shorterRepository: shorterRepository,
+		clickRepository:   clickRepository,
+	}
+}
+
+// GetStats return stats of clicks by short url in range. Stats is available only for owner,
+// days without clicks are filled by zero
+func (s *ClickStatsService) GetStats(
+	ctx context.Context,
+	userID, id string,
+	statsRange core.StatsRange,
+) (*core.ClickStats, error) {
+	shortURL, ok := s.shorterRepository.GetByID(ctx, id)
+
+	if !ok {
+		return nil, core.ErrShortURLNotFound
+	}
+
+	if !shortURL.UserID.Valid || shortURL.UserID.String != userID {
+		return nil, core.ErrNotOwner
+	}
+
+	stats, err := s.clickRepository.GetClickStats(ctx, id, statsRange.From, statsRange.To, clickStatsTop)
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[time.Time]int, len(stats.Days))
+
+	for _, day := range stats.Days {
+		counts[repositories.Day(day.Day)] += day.Count
+	}
+
+	stats.Days = repositories.FillDays(counts, statsRange)
+
+	return stats, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+)
+
+func TestClickStatsService_GetStats(t *testing.T) {
+	day := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	statsRange := core.StatsRange{From: day, To: day.AddDate(0, 0, 3)}
+
+	newService := func(t *testing.T) (*ClickStatsService, *core.ShortURL) {
+		store := storagememory.NewShortURLStore()
+		clickStore := storagememory.NewClickStore()
+
+		shortURL, err := NewShorter(store, nil).Create(context.Background(), "1", "https://ya.ru", core.CreateOptions{})
+		require.NoError(t, err)
+
+		require.NoError(t, clickStore.AddClicks(context.Background(), []*core.Click{
+			{ShortURLID: shortURL.ID, CreatedAt: day.Add(time.Hour), IP: "10.0.0.1"},
+			{ShortURLID: shortURL.ID, CreatedAt: day.Add(50 * time.Hour), IP: "10.0.0.2"},
+		}))
+
+		return NewClickStatsService(store, clickStore), shortURL
+	}
+
+	t.Run("should return stats with all days of range", func(t *testing.T) {
+		s, shortURL := newService(t)
+
+		stats, err := s.GetStats(context.Background(), "1", shortURL.ID, statsRange)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, stats.Total)
+		assert.Equal(t, 2, stats.UniqueVisitors)
+		assert.Equal(t, []core.DayCount{
+			{Day: day, Count: 1},
+			{Day: day.AddDate(0, 0, 1), Count: 0},
+			{Day: day.AddDate(0, 0, 2), Count: 1},
+		}, stats.Days)
+	})
+
+	t.Run("should error for other user", func(t *testing.T) {
+		s, shortURL := newService(t)
+
+		_, err := s.GetStats(context.Background(), "2", shortURL.ID, statsRange)
+
+		assert.ErrorIs(t, err, core.ErrNotOwner)
+	})
+
+	t.Run("should error for not found", func(t *testing.T) {
+		s, _ := newService(t)
+
+		_, err := s.GetStats(context.Background(), "1", "unknown", statsRange)
+
+		assert.ErrorIs(t, err, core.ErrShortURLNotFound)
+	})
+}
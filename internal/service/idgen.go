@@ -0,0 +1,163 @@
+package service
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	rand "github.com/shreyner/go-shortener/internal/pkg/random"
+)
+
+// Strategies of generate ID for config
+const (
+	IDGeneratorRandom  = "random"
+	IDGeneratorCounter = "counter"
+	IDGeneratorHash    = "hash"
+)
+
+// Limits of generated ID
+const (
+	MinIDLength = 4
+	MaxIDLength = 32
+)
+
+// DefaultIDAlphabet base62 in ascending order of ASCII, so counter IDs of same length are ordered as strings
+const DefaultIDAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// counterEpoch start of count for counter generator
+var counterEpoch = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// IDGenerator strategy of generate ID for new short url.
+// attempt is number of retry after collision, deterministic generators should return other ID for other attempt
+type IDGenerator interface {
+	Generate(url string, attempt int) string
+}
+
+// NewIDGenerator create generator by name of strategy with length and alphabet.
+// Alphabet can have only characters allowed in ID without escaping (see core.IsIDChar) without duplicates
+func NewIDGenerator(strategy string, length int, alphabet string) (IDGenerator, error) {
+	if length < MinIDLength || length > MaxIDLength {
+		return nil, fmt.Errorf("length of id should be from %d to %d", MinIDLength, MaxIDLength)
+	}
+
+	if alphabet == "" {
+		alphabet = DefaultIDAlphabet
+	}
+
+	chars := []rune(alphabet)
+	unique := make(map[rune]struct{}, len(chars))
+
+	for _, c := range chars {
+		if !core.IsIDChar(c) {
+			return nil, fmt.Errorf("alphabet of id has character %q, only latin letters, digits, \"-\" and \"_\" are allowed", c)
+		}
+
+		if _, ok := unique[c]; ok {
+			return nil, fmt.Errorf("alphabet of id has duplicate character %q", c)
+		}
+
+		unique[c] = struct{}{}
+	}
+
+	if len(chars) < 2 {
+		return nil, fmt.Errorf("alphabet of id should have at least 2 characters")
+	}
+
+	switch strategy {
+	case "", IDGeneratorRandom:
+		return NewRandomIDGenerator(length, chars), nil
+	case IDGeneratorCounter:
+		// Старт от миллисекунд с counterEpoch, чтобы после рестарта не начинать с уже выданных ID
+		return NewCounterIDGenerator(length, chars, uint64(time.Since(counterEpoch).Milliseconds())), nil
+	case IDGeneratorHash:
+		return NewHashIDGenerator(length, chars), nil
+	default:
+		return nil, fmt.Errorf("unknown id generator: %s", strategy)
+	}
+}
+
+// RandomIDGenerator random ID from characters of alphabet
+type RandomIDGenerator struct {
+	length   int
+	alphabet []rune
+}
+
+// NewRandomIDGenerator constructor
+func NewRandomIDGenerator(length int, alphabet []rune) *RandomIDGenerator {
+	return &RandomIDGenerator{length: length, alphabet: alphabet}
+}
+
+// Generate new random ID
+func (g *RandomIDGenerator) Generate(_ string, _ int) string {
+	return rand.RandSeqFrom(g.alphabet, g.length)
+}
+
+// CounterIDGenerator monotonic counter in base of alphabet, padded by first character to length.
+// When counter overflow length ID become longer
+type CounterIDGenerator struct {
+	length   int
+	alphabet []rune
+	counter  atomic.Uint64
+}
+
+// NewCounterIDGenerator constructor. start is first value of counter
+func NewCounterIDGenerator(length int, alphabet []rune, start uint64) *CounterIDGenerator {
+	g := &CounterIDGenerator{length: length, alphabet: alphabet}
+	g.counter.Store(start)
+
+	return g
+}
+
+// Generate next ID of counter
+func (g *CounterIDGenerator) Generate(_ string, _ int) string {
+	n := g.counter.Add(1) - 1
+
+	return encodeBase(new(big.Int).SetUint64(n), g.alphabet, g.length)
+}
+
+// HashIDGenerator deterministic ID from sha256 of url and attempt
+type HashIDGenerator struct {
+	length   int
+	alphabet []rune
+}
+
+// NewHashIDGenerator constructor
+func NewHashIDGenerator(length int, alphabet []rune) *HashIDGenerator {
+	return &HashIDGenerator{length: length, alphabet: alphabet}
+}
+
+// Generate ID by url. Same url and attempt always give same ID
+func (g *HashIDGenerator) Generate(url string, attempt int) string {
+	sum := sha256.Sum256([]byte(url + "\x00" + strconv.Itoa(attempt)))
+	id := encodeBase(new(big.Int).SetBytes(sum[:]), g.alphabet, g.length)
+
+	// sha256 дает больше символов, чем MaxIDLength, берем младшие
+	return string([]rune(id)[len([]rune(id))-g.length:])
+}
+
+// encodeBase write number in base of alphabet, padded by first character to length
+func encodeBase(n *big.Int, alphabet []rune, length int) string {
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+
+	var digits []rune
+
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		digits = append(digits, alphabet[mod.Int64()])
+	}
+
+	for len(digits) < length {
+		digits = append(digits, alphabet[0])
+	}
+
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+
+	return string(digits)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIDGenerator(t *testing.T) {
+	t.Run("should create all strategies", func(t *testing.T) {
+		for _, strategy := range []string{"", IDGeneratorRandom, IDGeneratorCounter, IDGeneratorHash} {
+			g, err := NewIDGenerator(strategy, 8, "")
+			require.NoError(t, err, strategy)
+
+			id := g.Generate("https://ya.ru", 0)
+			assert.Equal(t, 8, len(id), strategy)
+
+			for _, c := range id {
+				assert.True(t, strings.ContainsRune(DefaultIDAlphabet, c), strategy)
+			}
+		}
+	})
+
+	t.Run("should error for incorrect params", func(t *testing.T) {
+		_, err := NewIDGenerator("uuid", 8, "")
+		assert.Error(t, err)
+
+		_, err = NewIDGenerator(IDGeneratorRandom, MinIDLength-1, "")
+		assert.Error(t, err)
+
+		_, err = NewIDGenerator(IDGeneratorRandom, MaxIDLength+1, "")
+		assert.Error(t, err)
+
+		_, err = NewIDGenerator(IDGeneratorRandom, 8, "a")
+		assert.Error(t, err)
+
+		_, err = NewIDGenerator(IDGeneratorRandom, 8, "abca")
+		assert.Error(t, err)
+
+		for _, alphabet := range []string{"abc+", "abc/", "abc?", "abc#", "abc%", "abc d", "abc\t", "abcж"} {
+			_, err = NewIDGenerator(IDGeneratorRandom, 8, alphabet)
+			assert.Error(t, err, alphabet)
+		}
+
+		_, err = NewIDGenerator(IDGeneratorRandom, 8, "abc-_")
+		assert.NoError(t, err)
+	})
+}
+
+func TestCounterIDGenerator_Generate(t *testing.T) {
+	t.Run("should generate monotonic ids", func(t *testing.T) {
+		g := NewCounterIDGenerator(4, []rune(DefaultIDAlphabet), 61)
+
+		assert.Equal(t, "000z", g.Generate("", 0))
+		assert.Equal(t, "0010", g.Generate("", 0))
+		assert.Equal(t, "0011", g.Generate("", 0))
+	})
+
+	t.Run("should use alphabet", func(t *testing.T) {
+		g := NewCounterIDGenerator(4, []rune("ab"), 5)
+
+		assert.Equal(t, "abab", g.Generate("", 0))
+	})
+}
+
+func TestHashIDGenerator_Generate(t *testing.T) {
+	t.Run("should be deterministic by url and attempt", func(t *testing.T) {
+		g := NewHashIDGenerator(10, []rune(DefaultIDAlphabet))
+
+		assert.Equal(t, g.Generate("https://ya.ru", 0), g.Generate("https://ya.ru", 0))
+		assert.NotEqual(t, g.Generate("https://ya.ru", 0), g.Generate("https://ya.ru", 1))
+		assert.NotEqual(t, g.Generate("https://ya.ru", 0), g.Generate("https://vk.com", 0))
+		assert.Equal(t, 10, len(g.Generate("https://ya.ru", 0)))
+	})
+}
@@ -0,0 +1,692 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	rand "github.com/shreyner/go-shortener/internal/pkg/random"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
+)
+
+var (
+	// importChunkSize count of rows created by one CreateBatch
+	importChunkSize = 500
+	// importMaxSize max size of uploaded file in bytes
+	importMaxSize int64 = 1 << 30
+	// importWorkers count of imports processed at the same time, other wait in pending
+	importWorkers = 2
+	// maxActiveImportsPerUser pending and running imports of one user
+	maxActiveImportsPerUser = 2
+	// importJobTTL finished jobs and their results are kept so long
+	importJobTTL   = 24 * time.Hour
+	lengthImportID = 12
+)
+
+var errImportInterrupted = errors.New("import was interrupted by shutdown")
+
+// importRow row of imported file, fields are same as in batch create
+type importRow struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+	TTL           int64  `json:"ttl"`
+	ExpiresAt     string `json:"expires_at"`
+	Alias         string `json:"alias"`
+}
+
+type importJob struct {
+	mu  sync.Mutex
+	job core.ImportJob
+
+	compressed  bool
+	spoolPath   string
+	resultsPath string
+	// resultsSize bytes of results file with complete rows
+	resultsSize int64
+}
+
+func (j *importJob) update(fn func(job *core.ImportJob)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fn(&j.job)
+}
+
+func (j *importJob) snapshot() (core.ImportJob, int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.job, j.resultsSize
+}
+
+// Importer bulk import of short urls of user from uploaded csv or ndjson file.
+// Uploaded file is saved in dir and processed in background by chunks through CreateBatch,
+// result of every row is written in results file. Jobs are kept in memory and lost on restart
+type Importer struct {
+	log     *zap.Logger
+	shorter *Shorter
+	dir     string
+
+	slots chan struct{}
+	ctx   context.Context
+	stop  context.CancelFunc
+	wg    sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*importJob
+}
+
+// NewImporter create importer. Empty dir mean default directory for temporary files
+func NewImporter(log *zap.Logger, shorter *Shorter, dir string) *Importer {
+	ctx, stop := context.WithCancel(context.Background())
+
+	return &Importer{
+		log:     log,
+		shorter: shorter,
+		dir:     dir,
+		slots:   make(chan struct{}, importWorkers),
+		ctx:     ctx,
+		stop:    stop,
+		jobs:    make(map[string]*importJob),
+	}
+}
+
+// Start save body to file and start import in background. Compressed body is gzip, it's decompressed while import
+func (im *Importer) Start(userID string, format core.ImportFormat, compressed bool, body io.Reader) (*core.ImportJob, error) {
+	if format != core.ImportCSV && format != core.ImportNDJSON {
+		return nil, core.ErrInvalidImportFormat
+	}
+
+	im.purge(time.Now())
+
+	if im.activeByUser(userID) >= maxActiveImportsPerUser {
+		return nil, core.ErrTooManyImports
+	}
+
+	spool, err := os.CreateTemp(im.dir, "import-*.spool")
+
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := io.Copy(spool, io.LimitReader(body, importMaxSize+1))
+
+	if errClose := spool.Close(); err == nil {
+		err = errClose
+	}
+
+	if err == nil && size > importMaxSize {
+		err = core.ErrImportTooLarge
+	}
+
+	if err != nil {
+		os.Remove(spool.Name())
+		return nil, err
+	}
+
+	results, err := os.CreateTemp(im.dir, "import-*.results")
+
+	if err != nil {
+		os.Remove(spool.Name())
+		return nil, err
+	}
+
+	results.Close()
+
+	j := &importJob{
+		job: core.ImportJob{
+			ID:        rand.RandSeq(lengthImportID),
+			UserID:    userID,
+			Format:    format,
+			Status:    core.ImportPending,
+			Size:      size,
+			CreatedAt: now(),
+		},
+		compressed:  compressed,
+		spoolPath:   spool.Name(),
+		resultsPath: results.Name(),
+	}
+
+	im.mu.Lock()
+	im.jobs[j.job.ID] = j
+	im.mu.Unlock()
+
+	im.wg.Add(1)
+	go im.run(j)
+
+	job, _ := j.snapshot()
+
+	return &job, nil
+}
+
+// Get progress of import of user
+func (im *Importer) Get(userID, id string) (*core.ImportJob, error) {
+	j, err := im.get(userID, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	job, _ := j.snapshot()
+
+	return &job, nil
+}
+
+// Results of processed rows of import of user as ndjson of core.ImportRowResult in order of rows.
+// For running import only already processed rows
+func (im *Importer) Results(userID, id string) (io.ReadCloser, error) {
+	j, err := im.get(userID, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, size := j.snapshot()
+
+	file, err := os.Open(j.resultsPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, size), file}, nil
+}
+
+// Close interrupt running imports, wait them and remove files of all jobs
+func (im *Importer) Close() {
+	im.stop()
+	im.wg.Wait()
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	for id, j := range im.jobs {
+		os.Remove(j.resultsPath)
+		delete(im.jobs, id)
+	}
+}
+
+func (im *Importer) get(userID, id string) (*importJob, error) {
+	im.mu.Lock()
+	j, ok := im.jobs[id]
+	im.mu.Unlock()
+
+	if !ok {
+		return nil, core.ErrImportNotFound
+	}
+
+	if job, _ := j.snapshot(); job.UserID != userID {
+		return nil, core.ErrImportNotFound
+	}
+
+	return j, nil
+}
+
+func (im *Importer) activeByUser(userID string) int {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	count := 0
+
+	for _, j := range im.jobs {
+		if job, _ := j.snapshot(); job.UserID == userID && job.FinishedAt.IsZero() {
+			count++
+		}
+	}
+
+	return count
+}
+
+// purge remove jobs finished before importJobTTL
+func (im *Importer) purge(now time.Time) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	for id, j := range im.jobs {
+		if job, _ := j.snapshot(); !job.FinishedAt.IsZero() && now.Sub(job.FinishedAt) > importJobTTL {
+			os.Remove(j.resultsPath)
+			delete(im.jobs, id)
+		}
+	}
+}
+
+func (im *Importer) run(j *importJob) {
+	defer im.wg.Done()
+	defer os.Remove(j.spoolPath)
+
+	var err error
+
+	select {
+	case im.slots <- struct{}{}:
+		j.update(func(job *core.ImportJob) {
+			job.Status = core.ImportRunning
+		})
+
+		err = im.process(j)
+
+		<-im.slots
+	case <-im.ctx.Done():
+		err = errImportInterrupted
+	}
+
+	j.update(func(job *core.ImportJob) {
+		job.Status = core.ImportDone
+		job.FinishedAt = now()
+
+		if err != nil {
+			job.Status = core.ImportFailed
+			job.Error = err.Error()
+		}
+	})
+
+	job, _ := j.snapshot()
+
+	if err != nil {
+		im.log.Error("import failed", zap.String("id", job.ID), zap.Int("rows", job.Rows), zap.Error(err))
+		return
+	}
+
+	im.log.Info(
+		"import done",
+		zap.String("id", job.ID),
+		zap.Int("rows", job.Rows),
+		zap.Int("created", job.Created),
+		zap.Int("conflicts", job.Conflicts),
+		zap.Int("invalid", job.Invalid),
+		zap.Int("failed", job.Failed),
+	)
+}
+
+// pendingRow parsed row waiting creation in chunk. Nil shortURL for invalid row
+type pendingRow struct {
+	result   core.ImportRowResult
+	shortURL *core.ShortURL
+	alias    string
+}
+
+func (im *Importer) process(j *importJob) error {
+	spool, err := os.Open(j.spoolPath)
+
+	if err != nil {
+		return err
+	}
+
+	defer spool.Close()
+
+	results, err := os.OpenFile(j.resultsPath, os.O_WRONLY|os.O_APPEND, 0)
+
+	if err != nil {
+		return err
+	}
+
+	defer results.Close()
+
+	var r io.Reader = bufio.NewReader(&progressReader{r: spool, j: j})
+
+	if j.compressed {
+		gr, errGzip := gzip.NewReader(r)
+
+		if errGzip != nil {
+			return fmt.Errorf("invalid gzip: %w", errGzip)
+		}
+
+		defer gr.Close()
+
+		r = gr
+	}
+
+	job, _ := j.snapshot()
+
+	var rows importRowReader
+
+	if job.Format == core.ImportCSV {
+		rows = newCSVRowReader(r)
+	} else {
+		rows = newNDJSONRowReader(r)
+	}
+
+	w := bufio.NewWriter(results)
+	chunk := make([]*pendingRow, 0, importChunkSize)
+
+	for number := 1; ; number++ {
+		if im.ctx.Err() != nil {
+			return errImportInterrupted
+		}
+
+		row, rowErr, err := rows.Next()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		chunk = append(chunk, newPendingRow(job.UserID, number, row, rowErr))
+
+		if len(chunk) == importChunkSize {
+			if err := im.flush(j, w, chunk); err != nil {
+				return err
+			}
+
+			chunk = chunk[:0]
+		}
+	}
+
+	return im.flush(j, w, chunk)
+}
+
+func newPendingRow(userID string, number int, row importRow, rowErr error) *pendingRow {
+	p := &pendingRow{
+		result: core.ImportRowResult{
+			Row:           number,
+			CorrelationID: row.CorrelationID,
+			URL:           row.OriginalURL,
+			Status:        core.ImportRowInvalid,
+		},
+		alias: row.Alias,
+	}
+
+	if rowErr == nil {
+		if _, err := url.ParseRequestURI(row.OriginalURL); err != nil {
+			rowErr = errors.New("invalid url")
+		}
+	}
+
+	var expiresAt sql.NullTime
+
+	if rowErr == nil {
+		expiresAt, rowErr = core.ParseExpiration(row.TTL, row.ExpiresAt, time.Now())
+	}
+
+	if rowErr == nil && row.Alias != "" {
+		rowErr = core.ValidateAlias(row.Alias)
+	}
+
+	if rowErr != nil {
+		p.result.Error = rowErr.Error()
+		return p
+	}
+
+	p.shortURL = &core.ShortURL{
+		ID:            row.Alias,
+		URL:           row.OriginalURL,
+		CorrelationID: row.CorrelationID,
+		UserID:        sql.NullString{String: userID, Valid: userID != ""},
+		ExpiresAt:     expiresAt,
+	}
+
+	return p
+}
+
+// flush create valid rows of chunk by one batch. If batch failed, for example one url was already shortened,
+// rows are created one by one to find result of each row
+func (im *Importer) flush(j *importJob, w *bufio.Writer, chunk []*pendingRow) error {
+	shortURLs := make([]*core.ShortURL, 0, len(chunk))
+
+	for _, p := range chunk {
+		if p.shortURL != nil {
+			shortURLs = append(shortURLs, p.shortURL)
+		}
+	}
+
+	var errBatch error
+
+	if len(shortURLs) > 0 {
+		errBatch = im.shorter.CreateBatch(im.ctx, &shortURLs)
+	}
+
+	for _, p := range chunk {
+		if p.shortURL == nil {
+			continue
+		}
+
+		if errBatch == nil {
+			p.result.Status = core.ImportRowCreated
+			p.result.ID = p.shortURL.ID
+
+			continue
+		}
+
+		if im.ctx.Err() != nil {
+			return errImportInterrupted
+		}
+
+		im.createOne(p)
+	}
+
+	var written int64
+
+	for _, p := range chunk {
+		line, err := json.Marshal(p.result)
+
+		if err != nil {
+			return err
+		}
+
+		w.Write(line)
+		w.WriteByte('\n')
+
+		written += int64(len(line)) + 1
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.resultsSize += written
+
+	for _, p := range chunk {
+		j.job.Rows++
+
+		switch p.result.Status {
+		case core.ImportRowCreated:
+			j.job.Created++
+		case core.ImportRowConflict:
+			j.job.Conflicts++
+		case core.ImportRowInvalid:
+			j.job.Invalid++
+		default:
+			j.job.Failed++
+		}
+	}
+
+	return nil
+}
+
+func (im *Importer) createOne(p *pendingRow) {
+	shortURL, err := im.shorter.Create(im.ctx, p.shortURL.UserID.String, p.shortURL.URL, core.CreateOptions{
+		ExpiresAt: p.shortURL.ExpiresAt,
+		Alias:     p.alias,
+	})
+
+	var shortURLCreateConflictError *storeerrors.ShortURLCreateConflictError
+	var shortURLIDConflictError *storeerrors.ShortURLIDConflictError
+
+	switch {
+	case err == nil:
+		p.result.Status = core.ImportRowCreated
+		p.result.ID = shortURL.ID
+	case errors.As(err, &shortURLCreateConflictError):
+		p.result.Status = core.ImportRowConflict
+		p.result.ID = shortURLCreateConflictError.OriginID
+		p.result.Error = "url was already shortened"
+	case errors.As(err, &shortURLIDConflictError):
+		p.result.Status = core.ImportRowConflict
+		p.result.Error = fmt.Sprintf("alias %s is taken", shortURLIDConflictError.ID)
+	default:
+		im.log.Error("import row error", zap.Int("row", p.result.Row), zap.Error(err))
+
+		p.result.Status = core.ImportRowFailed
+		p.result.Error = "error create short url"
+	}
+}
+
+// progressReader count read bytes of uploaded file as progress of job
+type progressReader struct {
+	r io.Reader
+	j *importJob
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+
+	pr.j.update(func(job *core.ImportJob) {
+		job.Read += int64(n)
+	})
+
+	return n, err
+}
+
+// importRowReader return next row or io.EOF. rowErr is error of one row, err stop import
+type importRowReader interface {
+	Next() (row importRow, rowErr error, err error)
+}
+
+// csvRowReader rows of csv with header, columns are named as fields of batch create: original_url, correlation_id,
+// alias, ttl, expires_at. Only original_url is required, unknown columns are ignored
+type csvRowReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVRowReader(r io.Reader) *csvRowReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	return &csvRowReader{r: cr}
+}
+
+func (c *csvRowReader) Next() (importRow, error, error) {
+	if c.columns == nil {
+		if err := c.readHeader(); err != nil {
+			return importRow{}, nil, err
+		}
+	}
+
+	record, err := c.r.Read()
+
+	var parseError *csv.ParseError
+
+	if errors.As(err, &parseError) {
+		return importRow{}, fmt.Errorf("invalid csv: %w", parseError.Err), nil
+	}
+
+	if err != nil {
+		return importRow{}, nil, err
+	}
+
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+
+		return ""
+	}
+
+	row := importRow{
+		CorrelationID: field("correlation_id"),
+		OriginalURL:   field("original_url"),
+		ExpiresAt:     field("expires_at"),
+		Alias:         field("alias"),
+	}
+
+	if ttl := field("ttl"); ttl != "" {
+		if row.TTL, err = strconv.ParseInt(ttl, 10, 64); err != nil {
+			return row, core.ErrInvalidTTL, nil
+		}
+	}
+
+	return row, nil, nil
+}
+
+func (c *csvRowReader) readHeader() error {
+	header, err := c.r.Read()
+
+	if errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid csv header: %w", err)
+	}
+
+	c.columns = make(map[string]int, len(header))
+
+	for i, name := range header {
+		if i == 0 {
+			// BOM of files saved by Excel
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+
+		c.columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := c.columns["original_url"]; !ok {
+		return errors.New("csv header should have column original_url")
+	}
+
+	return nil
+}
+
+// ndjsonRowReader rows of json objects separated by new line, empty lines are skipped
+type ndjsonRowReader struct {
+	r *bufio.Reader
+}
+
+func newNDJSONRowReader(r io.Reader) *ndjsonRowReader {
+	return &ndjsonRowReader{r: bufio.NewReader(r)}
+}
+
+func (n *ndjsonRowReader) Next() (importRow, error, error) {
+	for {
+		line, err := n.r.ReadBytes('\n')
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return importRow{}, nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+
+		if len(line) == 0 {
+			if err != nil {
+				return importRow{}, nil, err
+			}
+
+			continue
+		}
+
+		var row importRow
+
+		if errJSON := json.Unmarshal(line, &row); errJSON != nil {
+			return importRow{}, errors.New("invalid json"), nil
+		}
+
+		return row, nil, nil
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+)
+
+func waitImport(t *testing.T, importer *Importer, userID, id string) *core.ImportJob {
+	t.Helper()
+
+	var job *core.ImportJob
+
+	require.Eventually(t, func() bool {
+		var err error
+
+		job, err = importer.Get(userID, id)
+		require.NoError(t, err)
+
+		return !job.FinishedAt.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return job
+}
+
+func importResults(t *testing.T, importer *Importer, userID, id string) []core.ImportRowResult {
+	t.Helper()
+
+	results, err := importer.Results(userID, id)
+	require.NoError(t, err)
+
+	defer results.Close()
+
+	var rows []core.ImportRowResult
+
+	scanner := bufio.NewScanner(results)
+
+	for scanner.Scan() {
+		var row core.ImportRowResult
+
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+
+		rows = append(rows, row)
+	}
+
+	require.NoError(t, scanner.Err())
+
+	return rows
+}
+
+func TestImporter(t *testing.T) {
+	t.Run("should import csv and return result of each row", func(t *testing.T) {
+		shorter := NewShorter(storagememory.NewShortURLStore(), nil)
+		importer := NewImporter(zap.NewNop(), shorter, t.TempDir())
+		defer importer.Close()
+
+		existing, err := shorter.Create(context.Background(), "1", "https://ya.ru/exists", core.CreateOptions{})
+		require.NoError(t, err)
+
+		_, err = shorter.Create(context.Background(), "1", "https://ya.ru/taken", core.CreateOptions{Alias: "taken"})
+		require.NoError(t, err)
+
+		body := strings.Join([]string{
+			"\ufefforiginal_url,correlation_id,alias,ttl",
+			"https://ya.ru/1,a,,",
+			"https://ya.ru/exists,b,,",
+			"not url,c,,",
+			"https://ya.ru/2,d,taken,",
+			"https://ya.ru/3,e,own-alias,3600",
+			"https://ya.ru/4,f,,abc",
+		}, "\n")
+
+		job, err := importer.Start("1", core.ImportCSV, false, strings.NewReader(body))
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(body)), job.Size)
+
+		job = waitImport(t, importer, "1", job.ID)
+
+		assert.Equal(t, core.ImportDone, job.Status)
+		assert.Equal(t, job.Size, job.Read)
+		assert.Equal(t, 6, job.Rows)
+		assert.Equal(t, 2, job.Created)
+		assert.Equal(t, 2, job.Conflicts)
+		assert.Equal(t, 2, job.Invalid)
+		assert.Equal(t, 0, job.Failed)
+
+		rows := importResults(t, importer, "1", job.ID)
+		require.Equal(t, 6, len(rows))
+
+		assert.Equal(t, core.ImportRowCreated, rows[0].Status)
+		assert.Equal(t, "a", rows[0].CorrelationID)
+		assert.NotEmpty(t, rows[0].ID)
+
+		assert.Equal(t, core.ImportRowConflict, rows[1].Status)
+		assert.Equal(t, existing.ID, rows[1].ID)
+
+		assert.Equal(t, core.ImportRowInvalid, rows[2].Status)
+		assert.Equal(t, core.ImportRowConflict, rows[3].Status)
+		assert.Equal(t, "alias taken is taken", rows[3].Error)
+
+		assert.Equal(t, core.ImportRowCreated, rows[4].Status)
+		assert.Equal(t, "own-alias", rows[4].ID)
+
+		assert.Equal(t, core.ImportRowInvalid, rows[5].Status)
+
+		for i, row := range rows {
+			assert.Equal(t, i+1, row.Row)
+		}
+
+		created, ok := shorter.GetByID(context.Background(), "own-alias")
+		require.True(t, ok)
+		assert.True(t, created.ExpiresAt.Valid)
+	})
+
+	t.Run("should import gzip ndjson by chunks", func(t *testing.T) {
+		defer func(size int) { importChunkSize = size }(importChunkSize)
+		importChunkSize = 3
+
+		shorter := NewShorter(storagememory.NewShortURLStore(), nil)
+		importer := NewImporter(zap.NewNop(), shorter, t.TempDir())
+		defer importer.Close()
+
+		var body bytes.Buffer
+
+		gw := gzip.NewWriter(&body)
+
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(gw, `{"original_url": "https://ya.ru/%d", "correlation_id": "%d"}`+"\n", i%8, i)
+		}
+
+		fmt.Fprintln(gw, `{"original_url": `)
+		fmt.Fprintln(gw)
+		require.NoError(t, gw.Close())
+
+		job, err := importer.Start("1", core.ImportNDJSON, true, &body)
+		require.NoError(t, err)
+
+		job = waitImport(t, importer, "1", job.ID)
+
+		assert.Equal(t, core.ImportDone, job.Status)
+		assert.Equal(t, 11, job.Rows)
+		assert.Equal(t, 8, job.Created)
+		assert.Equal(t, 2, job.Conflicts)
+		assert.Equal(t, 1, job.Invalid)
+
+		rows := importResults(t, importer, "1", job.ID)
+		require.Equal(t, 11, len(rows))
+
+		assert.Equal(t, core.ImportRowConflict, rows[8].Status)
+		assert.Equal(t, rows[0].ID, rows[8].ID)
+		assert.Equal(t, core.ImportRowInvalid, rows[10].Status)
+
+		urls, err := shorter.AllByUser(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, 8, len(urls))
+	})
+
+	t.Run("should fail import with broken file", func(t *testing.T) {
+		importer := NewImporter(zap.NewNop(), NewShorter(storagememory.NewShortURLStore(), nil), t.TempDir())
+		defer importer.Close()
+
+		for _, c := range []struct {
+			format     core.ImportFormat
+			compressed bool
+			body       string
+		}{
+			{core.ImportCSV, false, "url,alias\nhttps://ya.ru,"},
+			{core.ImportNDJSON, true, `{"original_url": "https://ya.ru"}`},
+		} {
+			job, err := importer.Start("1", c.format, c.compressed, strings.NewReader(c.body))
+			require.NoError(t, err)
+
+			job = waitImport(t, importer, "1", job.ID)
+
+			assert.Equal(t, core.ImportFailed, job.Status)
+			assert.NotEmpty(t, job.Error)
+		}
+	})
+
+	t.Run("should hide import of other user", func(t *testing.T) {
+		importer := NewImporter(zap.NewNop(), NewShorter(storagememory.NewShortURLStore(), nil), t.TempDir())
+		defer importer.Close()
+
+		job, err := importer.Start("1", core.ImportCSV, false, strings.NewReader("original_url\nhttps://ya.ru"))
+		require.NoError(t, err)
+
+		_, err = importer.Get("2", job.ID)
+		assert.ErrorIs(t, err, core.ErrImportNotFound)
+
+		_, err = importer.Results("2", job.ID)
+		assert.ErrorIs(t, err, core.ErrImportNotFound)
+	})
+
+	t.Run("should error for too large file", func(t *testing.T) {
+		defer func(size int64) { importMaxSize = size }(importMaxSize)
+		importMaxSize = 10
+
+		importer := NewImporter(zap.NewNop(), NewShorter(storagememory.NewShortURLStore(), nil), t.TempDir())
+		defer importer.Close()
+
+		_, err := importer.Start("1", core.ImportCSV, false, strings.NewReader(strings.Repeat("a", 11)))
+		assert.ErrorIs(t, err, core.ErrImportTooLarge)
+	})
+}
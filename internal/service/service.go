@@ -8,15 +8,20 @@ import (
 
 // Services include all services
 type Services struct {
-	ShorterService *Shorter
-	AuthService    *AuthService
+	ShorterService    *Shorter
+	AuthService       *AuthService
+	ClickStatsService *ClickStatsService
+	WebhookService    *WebhookService
 }
 
 // NewService return one struct with all services
 func NewService(
 	log *zap.Logger,
 	shorterRepository repositories.ShortURLRepository,
+	clickRepository repositories.ClickRepository,
+	webhookRepository repositories.WebhookRepository,
 	signKey []byte,
+	idGenerator IDGenerator,
 ) (*Services, error) {
 	authService, err := NewAuthService(log, signKey)
 
@@ -25,8 +30,10 @@ func NewService(
 	}
 
 	services := Services{
-		ShorterService: NewShorter(shorterRepository),
-		AuthService:    authService,
+		ShorterService:    NewShorter(shorterRepository, idGenerator),
+		AuthService:       authService,
+		ClickStatsService: NewClickStatsService(shorterRepository, clickRepository),
+		WebhookService:    NewWebhookService(webhookRepository),
 	}
 
 	return &services, nil
@@ -3,56 +3,222 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/shreyner/go-shortener/internal/core"
-	rand "github.com/shreyner/go-shortener/internal/pkg/random"
 	"github.com/shreyner/go-shortener/internal/repositories"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
 )
 
 var (
 	lengthShortID = 10
+	// maxIDAttempts count of tries create short url with new generated ID after collision
+	maxIDAttempts = 5
 )
 
 // Shorter service include business logic for work with short URLs
 type Shorter struct {
 	shorterRepository repositories.ShortURLRepository
+	idGenerator       IDGenerator
 }
 
-// NewShorter create service
-func NewShorter(shorterRepository repositories.ShortURLRepository) *Shorter {
+// NewShorter create service. Nil idGenerator mean random ID with default length and alphabet
+func NewShorter(shorterRepository repositories.ShortURLRepository, idGenerator IDGenerator) *Shorter {
+	if idGenerator == nil {
+		idGenerator = NewRandomIDGenerator(lengthShortID, []rune(DefaultIDAlphabet))
+	}
+
 	return &Shorter{
 		shorterRepository: shorterRepository,
+		idGenerator:       idGenerator,
 	}
 }
 
-// Create new short url by user
-func (s *Shorter) Create(ctx context.Context, userID, url string) (*core.ShortURL, error) {
-	id := generateURLID()
-	shortURL := &core.ShortURL{ID: id, URL: url, UserID: sql.NullString{
+// Create new short url by user. With opts.Alias short url will be created with this ID,
+// otherwise ID is generated and regenerated on collision or reserved ID up to maxIDAttempts
+func (s *Shorter) Create(ctx context.Context, userID, url string, opts core.CreateOptions) (*core.ShortURL, error) {
+	if opts.Alias != "" {
+		if err := core.ValidateAlias(opts.Alias); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := opts.RedirectOptions.Validate(); err != nil {
+		return nil, err
+	}
+
+	shortURL := &core.ShortURL{ID: opts.Alias, URL: url, UserID: sql.NullString{
 		String: userID,
 		Valid:  true,
-	}}
+	}, CreatedAt: now(), ExpiresAt: opts.ExpiresAt, RedirectType: opts.RedirectType, CacheMaxAge: opts.CacheMaxAge}
+
+	for attempt := 0; ; attempt++ {
+		if opts.Alias == "" {
+			id, used, err := s.generateID(url, attempt)
+
+			if err != nil {
+				return nil, err
+			}
+
+			shortURL.ID, attempt = id, used
+		}
+
+		err := s.shorterRepository.Add(ctx, shortURL)
+
+		var shortURLIDConflictError *storeerrors.ShortURLIDConflictError
+
+		if errors.As(err, &shortURLIDConflictError) && opts.Alias == "" && attempt+1 < maxIDAttempts {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return shortURL, nil
+	}
+}
+
+// CreateBatch more URLs by user. Filled ID is custom alias, for empty ID will be generated new.
+// Batch is retried with new ID for generated ID which has collision, reserved ID is regenerated before write
+func (s *Shorter) CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error {
+	createdAt := now()
+	attempts := make(map[*core.ShortURL]int, len(*shortURLs))
+	generated := make(map[string]*core.ShortURL, len(*shortURLs))
+
+	for _, v := range *shortURLs {
+		if v.ID == "" {
+			id, used, err := s.generateID(v.URL, 0)
+
+			if err != nil {
+				return fmt.Errorf("%w for correlation_id: %s", err, v.CorrelationID)
+			}
+
+			v.ID = id
+			attempts[v] = used
+			generated[v.ID] = v
+		} else if err := core.ValidateAlias(v.ID); err != nil {
+			return fmt.Errorf("%w for correlation_id: %s", err, v.CorrelationID)
+		}
+
+		redirectOptions := core.RedirectOptions{RedirectType: v.RedirectType, CacheMaxAge: v.CacheMaxAge}
+
+		if err := redirectOptions.Validate(); err != nil {
+			return fmt.Errorf("%w for correlation_id: %s", err, v.CorrelationID)
+		}
+
+		v.CreatedAt = createdAt
+	}
+
+	for {
+		err := s.shorterRepository.CreateBatch(ctx, shortURLs)
+
+		var shortURLIDConflictError *storeerrors.ShortURLIDConflictError
+
+		if !errors.As(err, &shortURLIDConflictError) {
+			return err
+		}
+
+		v, ok := generated[shortURLIDConflictError.ID]
+
+		if !ok || attempts[v]+1 >= maxIDAttempts {
+			return err
+		}
+
+		id, used, errGenerate := s.generateID(v.URL, attempts[v]+1)
+
+		if errGenerate != nil {
+			return err
+		}
+
+		delete(generated, v.ID)
+
+		v.ID = id
+		attempts[v] = used
+		generated[v.ID] = v
+	}
+}
 
-	err := s.shorterRepository.Add(ctx, shortURL)
+// generateID generate ID starting from attempt, reserved ID is regenerated with next attempt.
+// Return ID and its attempt or error when all of maxIDAttempts are reserved
+func (s *Shorter) generateID(url string, attempt int) (string, int, error) {
+	for ; attempt < maxIDAttempts; attempt++ {
+		id := s.idGenerator.Generate(url, attempt)
+
+		if !core.IsReservedID(id) {
+			return id, attempt, nil
+		}
+	}
+
+	return "", attempt, fmt.Errorf("generated id is reserved after %d attempts", maxIDAttempts)
+}
+
+// Update change original url and redirect options of short url by owner in one write, fields not set in redirect are kept.
+// Empty url keeps original url. Change of url is recorded with user and time
+func (s *Shorter) Update(ctx context.Context, userID, id, url string, redirect core.RedirectUpdate) (*core.ShortURL, error) {
+	if url == "" {
+		return s.SetRedirect(ctx, userID, id, redirect)
+	}
+
+	if err := redirect.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.shorterRepository.UpdateURL(ctx, &core.URLChange{
+		ShortURLID: id,
+		UserID:     userID,
+		NewURL:     url,
+		ChangedAt:  now(),
+	}, redirect)
+}
+
+// SetRedirect change redirect type and max-age of short url by owner, fields not set in update are kept
+func (s *Shorter) SetRedirect(ctx context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error) {
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.shorterRepository.SetRedirect(ctx, userID, id, update)
+}
+
+// History versions of target url of short url from first to current. History is available only for owner,
+// also after delete of short url
+func (s *Shorter) History(ctx context.Context, userID, id string) ([]*core.URLVersion, error) {
+	shortURL, ok := s.shorterRepository.GetByID(ctx, id)
+
+	if !ok {
+		return nil, core.ErrShortURLNotFound
+	}
+
+	if !shortURL.UserID.Valid || shortURL.UserID.String != userID {
+		return nil, core.ErrNotOwner
+	}
+
+	changes, err := s.shorterRepository.URLChanges(ctx, id)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return shortURL, nil
+	return core.NewURLVersions(shortURL, changes), nil
 }
 
-// CreateBatch more URLs by user
-func (s *Shorter) CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error {
-	for _, v := range *shortURLs {
-		v.ID = generateURLID()
+// Rollback change url of short url to url of version from history.
+// Rollback is recorded as new change, so versions after it stay in history
+func (s *Shorter) Rollback(ctx context.Context, userID, id string, version int) (*core.ShortURL, error) {
+	versions, err := s.History(ctx, userID, id)
+
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.shorterRepository.CreateBatch(ctx, shortURLs); err != nil {
-		return err
+	if version < 1 || version > len(versions) {
+		return nil, core.ErrURLVersionNotFound
 	}
 
-	return nil
+	return s.Update(ctx, userID, id, versions[version-1].URL, core.RedirectUpdate{})
 }
 
 // GetByID find by short URL and return original url or error with not found
@@ -65,6 +231,38 @@ func (s *Shorter) AllByUser(ctx context.Context, id string) ([]*core.ShortURL, e
 	return s.shorterRepository.AllByUserID(ctx, id)
 }
 
-func generateURLID() string {
-	return rand.RandSeq(lengthShortID)
+// ListByUser return page of urls was created user
+func (s *Shorter) ListByUser(ctx context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error) {
+	return s.shorterRepository.ListByUserID(ctx, id, opts)
+}
+
+// EachByUser call fn for every url was created user ordered by ID, include deleted.
+// Urls are read from store by pages, so all urls of user aren't kept in memory
+func (s *Shorter) EachByUser(ctx context.Context, id string, fn func(shortURL *core.ShortURL) error) error {
+	opts := core.ListOptions{Limit: repositories.MaxPageLimit, SortBy: core.SortByID}
+
+	for {
+		page, err := s.shorterRepository.ListByUserID(ctx, id, opts)
+
+		if err != nil {
+			return err
+		}
+
+		for _, shortURL := range page.ShortURLs {
+			if err := fn(shortURL); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// now time of create with precision supported by all stores
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
 }
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
+)
+
+// sequenceIDGenerator return ids by order of calls
+type sequenceIDGenerator struct {
+	ids []string
+}
+
+func (g *sequenceIDGenerator) Generate(_ string, _ int) string {
+	id := g.ids[0]
+	g.ids = g.ids[1:]
+
+	return id
+}
+
+func TestShorter_Create(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should retry on id collision", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, &sequenceIDGenerator{ids: []string{"taken", "taken", "free"}})
+
+		_, err := shorter.Create(ctx, "1", "https://ya.ru/1", core.CreateOptions{Alias: "taken"})
+		require.NoError(t, err)
+
+		got, err := shorter.Create(ctx, "1", "https://ya.ru/2", core.CreateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "free", got.ID)
+	})
+
+	t.Run("should stop after max attempts", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, &sequenceIDGenerator{ids: []string{"taken", "taken", "taken", "taken", "taken", "free"}})
+
+		_, err := shorter.Create(ctx, "1", "https://ya.ru/1", core.CreateOptions{Alias: "taken"})
+		require.NoError(t, err)
+
+		_, err = shorter.Create(ctx, "1", "https://ya.ru/2", core.CreateOptions{})
+
+		var conflictError *storeerrors.ShortURLIDConflictError
+		assert.True(t, errors.As(err, &conflictError))
+	})
+
+	t.Run("should regenerate reserved id", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, &sequenceIDGenerator{ids: []string{"ping", "Swagger", "free"}})
+
+		got, err := shorter.Create(ctx, "1", "https://ya.ru/1", core.CreateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "free", got.ID)
+	})
+
+	t.Run("should not retry taken alias", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, &sequenceIDGenerator{})
+
+		_, err := shorter.Create(ctx, "1", "https://ya.ru/1", core.CreateOptions{Alias: "taken"})
+		require.NoError(t, err)
+
+		_, err = shorter.Create(ctx, "1", "https://ya.ru/2", core.CreateOptions{Alias: "taken"})
+
+		var conflictError *storeerrors.ShortURLIDConflictError
+		assert.True(t, errors.As(err, &conflictError))
+	})
+}
+
+func TestShorter_CreateBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should regenerate only collided id", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, &sequenceIDGenerator{ids: []string{"first", "taken", "second"}})
+
+		_, err := shorter.Create(ctx, "1", "https://ya.ru/0", core.CreateOptions{Alias: "taken"})
+		require.NoError(t, err)
+
+		shortURLs := []*core.ShortURL{
+			{URL: "https://ya.ru/1", CorrelationID: "1"},
+			{URL: "https://ya.ru/2", CorrelationID: "2"},
+			{URL: "https://ya.ru/3", CorrelationID: "3", ID: "custom"},
+		}
+
+		require.NoError(t, shorter.CreateBatch(ctx, &shortURLs))
+
+		assert.Equal(t, "first", shortURLs[0].ID)
+		assert.Equal(t, "second", shortURLs[1].ID)
+		assert.Equal(t, "custom", shortURLs[2].ID)
+
+		for _, v := range shortURLs {
+			got, ok := shorter.GetByID(ctx, v.ID)
+			require.True(t, ok)
+			assert.Equal(t, v.URL, got.URL)
+		}
+	})
+
+	t.Run("should regenerate reserved id", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, &sequenceIDGenerator{ids: []string{"debug", "first", "api", "second"}})
+
+		shortURLs := []*core.ShortURL{
+			{URL: "https://ya.ru/1", CorrelationID: "1"},
+			{URL: "https://ya.ru/2", CorrelationID: "2"},
+		}
+
+		require.NoError(t, shorter.CreateBatch(ctx, &shortURLs))
+
+		assert.Equal(t, "first", shortURLs[0].ID)
+		assert.Equal(t, "second", shortURLs[1].ID)
+	})
+}
+
+func TestShorter_EachByUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should iterate all urls of user by pages", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, nil)
+
+		count := repositories.MaxPageLimit + 5
+		shortURLs := make([]*core.ShortURL, count)
+
+		for i := range shortURLs {
+			shortURLs[i] = &core.ShortURL{URL: fmt.Sprintf("https://ya.ru/%d", i), UserID: sql.NullString{String: "1", Valid: true}}
+		}
+
+		require.NoError(t, shorter.CreateBatch(ctx, &shortURLs))
+
+		_, err := shorter.Create(ctx, "2", "https://ya.ru/other", core.CreateOptions{})
+		require.NoError(t, err)
+
+		require.NoError(t, store.DeleteURLsUserByIds(ctx, "1", []string{shortURLs[0].ID}))
+
+		var ids []string
+		deleted := 0
+
+		err = shorter.EachByUser(ctx, "1", func(shortURL *core.ShortURL) error {
+			ids = append(ids, shortURL.ID)
+
+			if shortURL.IsDeleted {
+				deleted++
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, count, len(ids))
+		assert.True(t, sort.StringsAreSorted(ids))
+		assert.Equal(t, 1, deleted)
+	})
+}
+
+func TestShorter_Update(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should change url and record who changed it", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, nil)
+
+		shortURL, err := shorter.Create(ctx, "1", "https://ya.ru/typo", core.CreateOptions{})
+		require.NoError(t, err)
+
+		updated, err := shorter.Update(ctx, "1", shortURL.ID, "https://ya.ru", core.RedirectUpdate{})
+		require.NoError(t, err)
+		assert.Equal(t, "https://ya.ru", updated.URL)
+
+		got, ok := shorter.GetByID(ctx, shortURL.ID)
+		require.True(t, ok)
+		assert.Equal(t, "https://ya.ru", got.URL)
+
+		changes, err := store.URLChanges(ctx, shortURL.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(changes))
+		assert.Equal(t, "1", changes[0].UserID)
+		assert.Equal(t, "https://ya.ru/typo", changes[0].OldURL)
+		assert.False(t, changes[0].ChangedAt.IsZero())
+
+		_, err = shorter.Update(ctx, "2", shortURL.ID, "https://ya.ru/other", core.RedirectUpdate{})
+		assert.ErrorIs(t, err, core.ErrNotOwner)
+	})
+}
+
+func TestShorter_Rollback(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should rollback to version and keep history", func(t *testing.T) {
+		shorter := NewShorter(storagememory.NewShortURLStore(), nil)
+
+		shortURL, err := shorter.Create(ctx, "1", "https://ya.ru/1", core.CreateOptions{})
+		require.NoError(t, err)
+
+		_, err = shorter.Update(ctx, "1", shortURL.ID, "https://ya.ru/2", core.RedirectUpdate{})
+		require.NoError(t, err)
+
+		rolledBack, err := shorter.Rollback(ctx, "1", shortURL.ID, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "https://ya.ru/1", rolledBack.URL)
+
+		versions, err := shorter.History(ctx, "1", shortURL.ID)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(versions))
+		assert.Equal(t, "https://ya.ru/2", versions[1].URL)
+		assert.Equal(t, "https://ya.ru/1", versions[2].URL)
+
+		_, err = shorter.Rollback(ctx, "1", shortURL.ID, 4)
+		assert.ErrorIs(t, err, core.ErrURLVersionNotFound)
+
+		_, err = shorter.History(ctx, "2", shortURL.ID)
+		assert.ErrorIs(t, err, core.ErrNotOwner)
+	})
+
+	t.Run("should return history of deleted short url", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, nil)
+
+		shortURL, err := shorter.Create(ctx, "1", "https://ya.ru/1", core.CreateOptions{})
+		require.NoError(t, err)
+
+		_, err = shorter.Update(ctx, "1", shortURL.ID, "https://ya.ru/2", core.RedirectUpdate{})
+		require.NoError(t, err)
+
+		require.NoError(t, store.DeleteURLsUserByIds(ctx, "1", []string{shortURL.ID}))
+
+		versions, err := shorter.History(ctx, "1", shortURL.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, len(versions))
+
+		_, err = shorter.Rollback(ctx, "1", shortURL.ID, 1)
+		assert.ErrorIs(t, err, core.ErrShortURLDeleted)
+	})
+}
+
+func TestShorter_SetRedirect(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should create with redirect options and change only set fields", func(t *testing.T) {
+		shorter := NewShorter(storagememory.NewShortURLStore(), nil)
+		maxAge := sql.NullInt64{Int64: 3600, Valid: true}
+
+		shortURL, err := shorter.Create(ctx, "1", "https://ya.ru", core.CreateOptions{
+			RedirectOptions: core.RedirectOptions{RedirectType: http.StatusMovedPermanently, CacheMaxAge: maxAge},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusMovedPermanently, shortURL.RedirectType)
+
+		redirectType := http.StatusFound
+		updated, err := shorter.SetRedirect(ctx, "1", shortURL.ID, core.RedirectUpdate{RedirectType: &redirectType})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusFound, updated.RedirectType)
+		assert.Equal(t, maxAge, updated.CacheMaxAge)
+
+		_, err = shorter.SetRedirect(ctx, "2", shortURL.ID, core.RedirectUpdate{RedirectType: &redirectType})
+		assert.ErrorIs(t, err, core.ErrNotOwner)
+	})
+
+	t.Run("should validate redirect options", func(t *testing.T) {
+		shorter := NewShorter(storagememory.NewShortURLStore(), nil)
+
+		_, err := shorter.Create(ctx, "1", "https://ya.ru", core.CreateOptions{RedirectOptions: core.RedirectOptions{RedirectType: http.StatusOK}})
+		assert.ErrorIs(t, err, core.ErrInvalidRedirectType)
+
+		shortURLs := []*core.ShortURL{{URL: "https://ya.ru/1", CorrelationID: "1", CacheMaxAge: sql.NullInt64{Int64: -1, Valid: true}}}
+		assert.ErrorIs(t, shorter.CreateBatch(ctx, &shortURLs), core.ErrInvalidCacheMaxAge)
+
+		shortURL, err := shorter.Create(ctx, "1", "https://ya.ru", core.CreateOptions{})
+		require.NoError(t, err)
+
+		redirectType := http.StatusOK
+		_, err = shorter.SetRedirect(ctx, "1", shortURL.ID, core.RedirectUpdate{RedirectType: &redirectType})
+		assert.ErrorIs(t, err, core.ErrInvalidRedirectType)
+	})
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	expiredSweepInterval = time.Minute
+	expiredSweepTimeout  = 30 * time.Second
+)
+
+// ExpiredSweeper periodically mark as deleted short urls with expired ttl,
+// so stats and lists of user don't count them as active
+type ExpiredSweeper struct {
+	log               *zap.Logger
+	shorterRepository repositories.ShortURLRepository
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewExpiredSweeper create and start sweeper
+func NewExpiredSweeper(log *zap.Logger, shorterRepository repositories.ShortURLRepository) *ExpiredSweeper {
+	s := &ExpiredSweeper{
+		log:               log,
+		shorterRepository: shorterRepository,
+		done:              make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.loop(expiredSweepInterval)
+
+	return s
+}
+
+func (s *ExpiredSweeper) loop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), expiredSweepTimeout)
+
+			if _, err := s.Sweep(ctx); err != nil {
+				s.log.Error("error sweep expired short urls", zap.Error(err))
+			}
+
+			cancel()
+		}
+	}
+}
+
+// Sweep mark expired short urls as deleted now
+func (s *ExpiredSweeper) Sweep(ctx context.Context) (int, error) {
+	count, err := s.shorterRepository.DeleteExpired(ctx, time.Now())
+
+	if err != nil {
+		return 0, err
+	}
+
+	if count > 0 {
+		s.log.Info("expired short urls was marked deleted", zap.Int("count", count))
+	}
+
+	return count, nil
+}
+
+// Close stop sweeper and wait current sweep
+func (s *ExpiredSweeper) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
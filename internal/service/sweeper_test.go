@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+)
+
+func TestExpiredSweeper_Sweep(t *testing.T) {
+	t.Run("should mark expired urls as deleted", func(t *testing.T) {
+		store := storagememory.NewShortURLStore()
+		shorter := NewShorter(store, nil)
+
+		expired, err := shorter.Create(context.Background(), "1", "https://ya.ru/1", core.CreateOptions{
+			ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true},
+		})
+		require.NoError(t, err)
+
+		active, err := shorter.Create(context.Background(), "1", "https://ya.ru/2", core.CreateOptions{
+			ExpiresAt: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true},
+		})
+		require.NoError(t, err)
+
+		sweeper := NewExpiredSweeper(zap.NewNop(), store)
+		defer sweeper.Close()
+
+		count, err := sweeper.Sweep(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		got, ok := shorter.GetByID(context.Background(), expired.ID)
+		require.True(t, ok)
+		assert.True(t, got.IsDeleted)
+
+		got, ok = shorter.GetByID(context.Background(), active.ID)
+		require.True(t, ok)
+		assert.False(t, got.IsDeleted)
+	})
+}
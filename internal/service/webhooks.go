@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	rand "github.com/shreyner/go-shortener/internal/pkg/random"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	// maxWebhooksPerUser limit of webhooks of one user
+	maxWebhooksPerUser = 10
+	lengthWebhookID    = 12
+	lengthSecret       = 32
+)
+
+// WebhookService subscriptions of users on events of own short urls
+type WebhookService struct {
+	webhookRepository repositories.WebhookRepository
+}
+
+// NewWebhookService create service
+func NewWebhookService(webhookRepository repositories.WebhookRepository) *WebhookService {
+	return &WebhookService{
+		webhookRepository: webhookRepository,
+	}
+}
+
+// Create new webhook of user with generated secret for signature. Empty events mean all types of events
+func (s *WebhookService) Create(ctx context.Context, userID, url string, events []string) (*core.Webhook, error) {
+	url, eventTypes, err := core.ParseWebhook(url, events)
+
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.webhookRepository.ListWebhooks(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(webhooks) >= maxWebhooksPerUser {
+		return nil, core.ErrTooManyWebhooks
+	}
+
+	webhook := &core.Webhook{
+		ID:        rand.RandSeq(lengthWebhookID),
+		UserID:    userID,
+		URL:       url,
+		Secret:    rand.RandSeq(lengthSecret),
+		Events:    eventTypes,
+		CreatedAt: now(),
+	}
+
+	if err := s.webhookRepository.AddWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// List webhooks of user
+func (s *WebhookService) List(ctx context.Context, userID string) ([]*core.Webhook, error) {
+	return s.webhookRepository.ListWebhooks(ctx, userID)
+}
+
+// Delete webhook of user
+func (s *WebhookService) Delete(ctx context.Context, userID, id string) error {
+	return s.webhookRepository.DeleteWebhook(ctx, userID, id)
+}
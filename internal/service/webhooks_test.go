@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+)
+
+func TestWebhookService_Create(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should create webhook with secret subscribed on all events", func(t *testing.T) {
+		s := NewWebhookService(storagememory.NewWebhookStore())
+
+		webhook, err := s.Create(ctx, "1", "https://example.com/hook", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, lengthWebhookID, len(webhook.ID))
+		assert.Equal(t, lengthSecret, len(webhook.Secret))
+		assert.Equal(t, core.WebhookEventTypes, webhook.Events)
+
+		got, err := s.List(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(got))
+		assert.Equal(t, webhook.Secret, got[0].Secret)
+	})
+
+	t.Run("should limit webhooks of user", func(t *testing.T) {
+		s := NewWebhookService(storagememory.NewWebhookStore())
+
+		for i := 0; i < maxWebhooksPerUser; i++ {
+			_, err := s.Create(ctx, "1", "https://example.com/hook", nil)
+			require.NoError(t, err)
+		}
+
+		_, err := s.Create(ctx, "1", "https://example.com/hook", nil)
+		assert.ErrorIs(t, err, core.ErrTooManyWebhooks)
+
+		_, err = s.Create(ctx, "2", "https://example.com/hook", nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should error for incorrect url", func(t *testing.T) {
+		s := NewWebhookService(storagememory.NewWebhookStore())
+
+		_, err := s.Create(ctx, "1", "example.com/hook", nil)
+		assert.ErrorIs(t, err, core.ErrInvalidWebhook)
+	})
+}
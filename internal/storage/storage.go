@@ -2,15 +2,20 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/shreyner/go-shortener/internal/pkg/database"
 	"github.com/shreyner/go-shortener/internal/repositories"
+	storagebolt "github.com/shreyner/go-shortener/internal/storage/storage_bolt"
 	storagedatabase "github.com/shreyner/go-shortener/internal/storage/storage_database"
 	storagefile "github.com/shreyner/go-shortener/internal/storage/storage_file"
 	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+	storagesqlite "github.com/shreyner/go-shortener/internal/storage/storage_sqlite"
 )
 
 // Enum types with types connection to store
@@ -18,22 +23,33 @@ const (
 	RepositoryTypeFile     = iota // file store
 	RepositoryTypeDataBase        // sql store
 	RepositoryTypeMemory          // memo store
+	RepositoryTypeSQLite          // embedded sqlite store
+	RepositoryTypeBolt            // embedded key-value store
 )
 
 // Storage base storage
 type Storage struct {
 	ShortURL repositories.ShortURLRepository
+	Clicks   repositories.ClickRepository
+	Events   repositories.EventRepository
+	Webhooks repositories.WebhookRepository
 
 	ping  func(context.Context) error
 	close func() error
 }
 
-// NewStorage create memo or file or sql store by params
-func NewStorage(log *zap.Logger, fileStoragePath string, dataBaseDSN string) (*Storage, error) {
+// NewStorage create memo or file or sql store by params.
+// Database dsn with prefix sqlite:// or bolt:// select embedded sqlite or bolt store.
+// replicaDSNs are read replicas of postgres, other stores ignore them
+func NewStorage(log *zap.Logger, fileStoragePath string, dataBaseDSN string, replicaDSNs []string) (*Storage, error) {
 	var repositoryType int
 
 	if fileStoragePath != "" {
 		repositoryType = RepositoryTypeFile
+	} else if strings.HasPrefix(dataBaseDSN, storagesqlite.DSNPrefix) {
+		repositoryType = RepositoryTypeSQLite
+	} else if strings.HasPrefix(dataBaseDSN, storagebolt.DSNPrefix) {
+		repositoryType = RepositoryTypeBolt
 	} else if dataBaseDSN != "" {
 		repositoryType = RepositoryTypeDataBase
 	} else {
@@ -48,11 +64,97 @@ func NewStorage(log *zap.Logger, fileStoragePath string, dataBaseDSN string) (*S
 			return nil, fmt.Errorf("storage error when initialize file: %w", err)
 		}
 
+		clickFileRepository, err := storagefile.NewClickStore(log, fileStoragePath+".clicks")
+
+		if err != nil {
+			shorterFileRepository.Close()
+
+			return nil, fmt.Errorf("storage error when initialize clicks file: %w", err)
+		}
+
+		webhookFileRepository, err := storagefile.NewWebhookStore(fileStoragePath + ".webhooks")
+
+		if err != nil {
+			shorterFileRepository.Close()
+			clickFileRepository.Close()
+
+			return nil, fmt.Errorf("storage error when initialize webhooks file: %w", err)
+		}
+
 		return &Storage{
 			ShortURL: shorterFileRepository,
+			Clicks:   clickFileRepository,
+			Events:   shorterFileRepository,
+			Webhooks: webhookFileRepository,
+
+			ping: func(_ context.Context) error { return nil },
+			close: func() error {
+				if err := clickFileRepository.Close(); err != nil {
+					log.Error("error to close clicks file", zap.Error(err))
+				}
+
+				return shorterFileRepository.Close()
+			},
+		}, nil
+	}
+
+	if repositoryType == RepositoryTypeSQLite {
+		log.Info("Init sqlite storage")
+		storeSQLite, err := storagesqlite.NewStorageSQLite(log, dataBaseDSN)
+
+		if err != nil {
+			return nil, fmt.Errorf("storage error when initialize sqlite: %w", err)
+		}
+
+		shortURLSQLite := storagesqlite.NewShortURLStore(log, storeSQLite.DB)
+
+		return &Storage{
+			ShortURL: shortURLSQLite,
+			Clicks:   storagesqlite.NewClickStore(log, storeSQLite.DB),
+			Events:   shortURLSQLite,
+			Webhooks: storagesqlite.NewWebhookStore(log, storeSQLite.DB),
+
+			ping: storeSQLite.PingContext,
+			close: func() error {
+				log.Info("Close sqlite database")
+				if err := storeSQLite.Close(); err != nil {
+					log.Error("error to close sqlite db", zap.Error(err))
+
+					return err
+				}
+
+				return nil
+			},
+		}, nil
+	}
+
+	if repositoryType == RepositoryTypeBolt {
+		log.Info("Init bolt storage")
+		storeBolt, err := storagebolt.NewStorageBolt(log, dataBaseDSN)
+
+		if err != nil {
+			return nil, fmt.Errorf("storage error when initialize bolt: %w", err)
+		}
+
+		shortURLBolt := storagebolt.NewShortURLStore(log, storeBolt.DB)
+
+		return &Storage{
+			ShortURL: shortURLBolt,
+			Clicks:   storagebolt.NewClickStore(log, storeBolt.DB),
+			Events:   shortURLBolt,
+			Webhooks: storagebolt.NewWebhookStore(log, storeBolt.DB),
+
+			ping: storeBolt.PingContext,
+			close: func() error {
+				log.Info("Close bolt database")
+				if err := storeBolt.Close(); err != nil {
+					log.Error("error to close bolt db", zap.Error(err))
+
+					return err
+				}
 
-			ping:  func(_ context.Context) error { return nil },
-			close: shorterFileRepository.Close,
+				return nil
+			},
 		}, nil
 	}
 
@@ -69,22 +171,61 @@ func NewStorage(log *zap.Logger, fileStoragePath string, dataBaseDSN string) (*S
 
 		log.Info("Success connected database")
 
-		log.Info("Check and create database...")
-		if err = storeDB.CheckAndCreateSchema(); err != nil {
-			return nil, fmt.Errorf("storage error when create schema in db: %w", err)
+		log.Info("Apply database migrations...")
+		migrator, err := storagedatabase.NewMigrator(log, db)
+
+		if err != nil {
+			return nil, fmt.Errorf("storage error when load migrations: %w", err)
+		}
+
+		migrateCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if err = migrator.Up(migrateCtx); err != nil {
+			return nil, fmt.Errorf("storage error when apply migrations in db: %w", err)
+		}
+		log.Info("Finish apply migrations...")
+
+		var replicas *storagedatabase.ReplicaSet
+
+		if len(replicaDSNs) > 0 {
+			log.Info("Init database replicas", zap.Int("count", len(replicaDSNs)))
+			replicaDBs := make([]*sql.DB, 0, len(replicaDSNs))
+
+			for _, replicaDSN := range replicaDSNs {
+				replicaDB, err := database.OpenDataBase(replicaDSN)
+
+				if err != nil {
+					return nil, fmt.Errorf("storage error when open replica: %w", err)
+				}
+
+				replicaDBs = append(replicaDBs, replicaDB)
+			}
+
+			replicas = storagedatabase.NewReplicaSet(log, db, replicaDBs)
+			log.Info("Database replicas in rotation", zap.Int("healthy", replicas.Healthy()))
 		}
-		log.Info("Finish check or created...")
 
-		shortURLStorage, err := storagedatabase.NewShortURLStore(log, db)
+		shortURLStorage, err := storagedatabase.NewShortURLStore(log, db, replicas)
 		if err != nil {
 			return nil, fmt.Errorf("storage error when initilizing shortURLStorage: %w", err)
 		}
 
 		return &Storage{
 			ShortURL: shortURLStorage,
+			Clicks:   storagedatabase.NewClickStore(log, db),
+			Events:   shortURLStorage,
+			Webhooks: storagedatabase.NewWebhookStore(log, db),
 
 			ping: storeDB.PingContext,
 			close: func() error {
+				if replicas != nil {
+					log.Info("Close database replicas")
+					if err := replicas.Close(); err != nil {
+						log.Error("error to close replicas", zap.Error(err))
+					}
+				}
+
 				log.Info("Close database connection")
 				if err := storeDB.Close(); err != nil {
 					log.Error("error to close connection db", zap.Error(err))
@@ -98,8 +239,13 @@ func NewStorage(log *zap.Logger, fileStoragePath string, dataBaseDSN string) (*S
 	}
 
 	log.Info("Init memory storage")
+	shortURLMemory := storagememory.NewShortURLStore()
+
 	return &Storage{
-		ShortURL: storagememory.NewShortURLStore(),
+		ShortURL: shortURLMemory,
+		Clicks:   storagememory.NewClickStore(),
+		Events:   shortURLMemory,
+		Webhooks: storagememory.NewWebhookStore(),
 
 		ping:  func(_ context.Context) error { return nil },
 		close: func() error { return nil },
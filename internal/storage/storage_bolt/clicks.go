@@ -0,0 +1,96 @@
+package storagebolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	_ repositories.ClickRepository = (*clickRepository)(nil)
+)
+
+type clickRepository struct {
+	log *zap.Logger
+	db  *bolt.DB
+}
+
+// NewClickStore create bolt store of clicks
+func NewClickStore(log *zap.Logger, db *bolt.DB) *clickRepository {
+	return &clickRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// AddClicks Добавить пачку кликов в одной транзакции
+func (s *clickRepository) AddClicks(_ context.Context, clicks []*core.Click) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, click := range clicks {
+			data, err := json.Marshal(click)
+
+			if err != nil {
+				return err
+			}
+
+			clickBucket, err := tx.Bucket(bucketClicks).CreateBucketIfNotExists([]byte(click.ShortURLID))
+
+			if err != nil {
+				return err
+			}
+
+			seq, err := clickBucket.NextSequence()
+
+			if err != nil {
+				return err
+			}
+
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, seq)
+
+			if err := clickBucket.Put(key, data); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetClickStats статистика кликов по короткой ссылке
+func (s *clickRepository) GetClickStats(_ context.Context, shortURLID string, from, to time.Time, top int) (*core.ClickStats, error) {
+	var clicks []*core.Click
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		clickBucket := tx.Bucket(bucketClicks).Bucket([]byte(shortURLID))
+
+		if clickBucket == nil {
+			return nil
+		}
+
+		return clickBucket.ForEach(func(_, data []byte) error {
+			var click core.Click
+
+			if err := json.Unmarshal(data, &click); err != nil {
+				return err
+			}
+
+			clicks = append(clicks, &click)
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return repositories.AggregateClicks(clicks, shortURLID, from, to, top), nil
+}
@@ -0,0 +1,44 @@
+package storagebolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/repositories"
+	"github.com/shreyner/go-shortener/internal/repositories/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.ShortURLRepository {
+		return newTestStore(t)
+	})
+}
+
+func TestClickConformance(t *testing.T) {
+	repotest.RunClicks(t, func(t *testing.T) repositories.ClickRepository {
+		storage, err := NewStorageBolt(zap.NewNop(), DSNPrefix+filepath.Join(t.TempDir(), "shortener.bolt"))
+		require.NoError(t, err)
+
+		t.Cleanup(func() {
+			storage.Close()
+		})
+
+		return NewClickStore(zap.NewNop(), storage.DB)
+	})
+}
+
+func TestWebhookConformance(t *testing.T) {
+	repotest.RunWebhooks(t, func(t *testing.T) repositories.WebhookRepository {
+		storage, err := NewStorageBolt(zap.NewNop(), DSNPrefix+filepath.Join(t.TempDir(), "shortener.bolt"))
+		require.NoError(t, err)
+
+		t.Cleanup(func() {
+			storage.Close()
+		})
+
+		return NewWebhookStore(zap.NewNop(), storage.DB)
+	})
+}
@@ -0,0 +1,648 @@
+package storagebolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
+)
+
+var (
+	_ repositories.ShortURLRepository = (*shortURLRepository)(nil)
+	_ repositories.ShortURLScanner    = (*shortURLRepository)(nil)
+	_ repositories.EventRepository    = (*shortURLRepository)(nil)
+)
+
+// scanPageSize count of records read in one transaction by ScanAll
+var scanPageSize = 1000
+
+type shortURLRepository struct {
+	log *zap.Logger
+	db  *bolt.DB
+}
+
+// NewShortURLStore create bolt store
+func NewShortURLStore(log *zap.Logger, db *bolt.DB) *shortURLRepository {
+	return &shortURLRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// Add Добавить короткую ссылку в store
+func (s *shortURLRepository) Add(_ context.Context, shortURL *core.ShortURL) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := putShortURL(tx, shortURL); err != nil {
+			return err
+		}
+
+		return putEvent(tx, core.NewEvent(core.EventCreated, shortURL, time.Now()))
+	})
+}
+
+// GetByID Получить короткую ссылку по идентификатору
+func (s *shortURLRepository) GetByID(_ context.Context, id string) (*core.ShortURL, bool) {
+	var shortURL *core.ShortURL
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		shortURL, err = getShortURL(tx, id)
+
+		return err
+	})
+
+	if err != nil {
+		s.log.Error("error get short url", zap.String("id", id), zap.Error(err))
+		return nil, false
+	}
+
+	return shortURL, shortURL != nil
+}
+
+// AllByUserID получить все ссылки по идентификатору пользователя
+func (s *shortURLRepository) AllByUserID(_ context.Context, id string) ([]*core.ShortURL, error) {
+	var result []*core.ShortURL
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		userBucket := tx.Bucket(bucketUsers).Bucket([]byte(id))
+
+		if userBucket == nil {
+			return nil
+		}
+
+		return userBucket.ForEach(func(_, shortURLID []byte) error {
+			shortURL, err := getShortURL(tx, string(shortURLID))
+
+			if err != nil {
+				return err
+			}
+
+			if shortURL != nil {
+				result = append(result, shortURL)
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListByUserID получить страницу ссылок пользователя
+func (s *shortURLRepository) ListByUserID(ctx context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error) {
+	shortURLs, err := s.AllByUserID(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return repositories.PageShortURLs(shortURLs, opts)
+}
+
+// DeleteExpired Пометить удаленными ссылки с истекшим сроком жизни
+func (s *shortURLRepository) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	count := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketShortURLs)
+		var expired []*core.ShortURL
+
+		err := bucket.ForEach(func(_, data []byte) error {
+			var shortURL core.ShortURL
+
+			if err := json.Unmarshal(data, &shortURL); err != nil {
+				return err
+			}
+
+			if shortURL.IsDeleted || !shortURL.IsExpired(now) {
+				return nil
+			}
+
+			shortURL.IsDeleted = true
+			expired = append(expired, &shortURL)
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		// Менять bucket во время ForEach нельзя, обновляем после обхода
+		for _, shortURL := range expired {
+			data, err := json.Marshal(shortURL)
+
+			if err != nil {
+				return err
+			}
+
+			if err := bucket.Put([]byte(shortURL.ID), data); err != nil {
+				return err
+			}
+
+			if err := putEvent(tx, core.NewEvent(core.EventExpired, shortURL, now)); err != nil {
+				return err
+			}
+		}
+
+		count = len(expired)
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CreateBatch Добавление ссылок пачкой в одной транзакции
+func (s *shortURLRepository) CreateBatch(_ context.Context, shortURLs *[]*core.ShortURL) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+
+		for _, v := range *shortURLs {
+			if err := putShortURL(tx, v); err != nil {
+				return err
+			}
+
+			if err := putEvent(tx, core.NewEvent(core.EventCreated, v, now)); err != nil {
+				return err
+			}
+
+			if !v.IsDeleted {
+				continue
+			}
+
+			if err := putEvent(tx, core.NewEvent(core.EventDeleted, v, now)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteURLsUserByIds Удаление пачкой коротких ссылок от имени пользователя
+func (s *shortURLRepository) DeleteURLsUserByIds(_ context.Context, userID string, ids []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+
+		for _, id := range ids {
+			shortURL, err := getShortURL(tx, id)
+
+			if err != nil {
+				return err
+			}
+
+			if shortURL == nil || shortURL.IsDeleted || !shortURL.UserID.Valid || shortURL.UserID.String != userID {
+				continue
+			}
+
+			shortURL.IsDeleted = true
+
+			data, err := json.Marshal(shortURL)
+
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Bucket(bucketShortURLs).Put([]byte(id), data); err != nil {
+				return err
+			}
+
+			if err := putEvent(tx, core.NewEvent(core.EventDeleted, shortURL, now)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetStats return stats
+func (s *shortURLRepository) GetStats(_ context.Context, statsRange core.StatsRange) (*core.ShortStats, error) {
+	var shortStats *core.ShortStats
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var shortURLs []*core.ShortURL
+
+		err := tx.Bucket(bucketShortURLs).ForEach(func(_, data []byte) error {
+			var shortURL core.ShortURL
+
+			if err := json.Unmarshal(data, &shortURL); err != nil {
+				return err
+			}
+
+			shortURLs = append(shortURLs, &shortURL)
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		shortStats = repositories.AggregateShortURLs(shortURLs, statsRange)
+		shortStats.StorageSize = tx.Size()
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return shortStats, nil
+}
+
+// ScanAll обойти все ссылки с идентификатором больше afterID по порядку.
+// Читает страницами, fn вызывается вне транзакции
+func (s *shortURLRepository) ScanAll(ctx context.Context, afterID string, fn func(shortURL *core.ShortURL) error) error {
+	for {
+		page := make([]*core.ShortURL, 0, scanPageSize)
+
+		err := s.db.View(func(tx *bolt.Tx) error {
+			cursor := tx.Bucket(bucketShortURLs).Cursor()
+
+			k, v := cursor.Seek([]byte(afterID))
+
+			if k != nil && string(k) == afterID {
+				k, v = cursor.Next()
+			}
+
+			for ; k != nil && len(page) < scanPageSize; k, v = cursor.Next() {
+				var shortURL core.ShortURL
+
+				if err := json.Unmarshal(v, &shortURL); err != nil {
+					return err
+				}
+
+				page = append(page, &shortURL)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, shortURL := range page {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if err := fn(shortURL); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < scanPageSize {
+			return nil
+		}
+
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// EventsAfter события ленты после after. Bolt пишет транзакции последовательно, поэтому ID растут в порядке фиксации
+func (s *shortURLRepository) EventsAfter(_ context.Context, after int64, limit int) ([]*core.Event, error) {
+	var events []*core.Event
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketEvents).Cursor()
+
+		for k, v := cursor.Seek(eventKey(after + 1)); k != nil && len(events) < limit; k, v = cursor.Next() {
+			var event core.Event
+
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+
+			events = append(events, &event)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// UpdateURL Изменить url короткой ссылки владельцем, изменение и событие пишутся в той же транзакции
+func (s *shortURLRepository) UpdateURL(_ context.Context, change *core.URLChange, redirect core.RedirectUpdate) (*core.ShortURL, error) {
+	var updated *core.ShortURL
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		shortURL, err := getShortURL(tx, change.ShortURLID)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repositories.CheckURLChange(shortURL, change.UserID); err != nil {
+			return err
+		}
+
+		change.OldURL = shortURL.URL
+		redirect.ApplyTo(shortURL)
+		updated = shortURL
+
+		if shortURL.URL == change.NewURL {
+			if redirect.IsEmpty() {
+				return nil
+			}
+
+			data, err := json.Marshal(shortURL)
+
+			if err != nil {
+				return err
+			}
+
+			return tx.Bucket(bucketShortURLs).Put([]byte(shortURL.ID), data)
+		}
+
+		urls := tx.Bucket(bucketURLs)
+
+		originID, err := activeIDByURL(tx, change.NewURL, change.ChangedAt)
+
+		if err != nil {
+			return err
+		}
+
+		if originID != "" {
+			return storeerrors.NewShortURLCreateConflictError(originID)
+		}
+
+		if err := urls.Delete([]byte(shortURL.URL)); err != nil {
+			return err
+		}
+
+		if err := urls.Put([]byte(change.NewURL), []byte(shortURL.ID)); err != nil {
+			return err
+		}
+
+		shortURL.URL = change.NewURL
+
+		data, err := json.Marshal(shortURL)
+
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(bucketShortURLs).Put([]byte(shortURL.ID), data); err != nil {
+			return err
+		}
+
+		if err := putChange(tx, change); err != nil {
+			return err
+		}
+
+		return putEvent(tx, core.NewEvent(core.EventUpdated, shortURL, change.ChangedAt))
+	})
+
+	if err != nil {
+		change.ID = 0
+
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// SetRedirect Изменить тип редиректа и max-age короткой ссылки владельцем
+func (s *shortURLRepository) SetRedirect(_ context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error) {
+	var updated *core.ShortURL
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		shortURL, err := getShortURL(tx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if err := repositories.CheckURLChange(shortURL, userID); err != nil {
+			return err
+		}
+
+		update.ApplyTo(shortURL)
+		updated = shortURL
+
+		data, err := json.Marshal(shortURL)
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(bucketShortURLs).Put([]byte(shortURL.ID), data)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// URLChanges изменения url короткой ссылки по порядку
+func (s *shortURLRepository) URLChanges(_ context.Context, shortURLID string) ([]*core.URLChange, error) {
+	var changes []*core.URLChange
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		changeBucket := tx.Bucket(bucketChanges).Bucket([]byte(shortURLID))
+
+		if changeBucket == nil {
+			return nil
+		}
+
+		return changeBucket.ForEach(func(_, data []byte) error {
+			var change core.URLChange
+
+			if err := json.Unmarshal(data, &change); err != nil {
+				return err
+			}
+
+			changes = append(changes, &change)
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// putChange write change with next sequence of changes bucket into bucket of short url
+func putChange(tx *bolt.Tx, change *core.URLChange) error {
+	bucket := tx.Bucket(bucketChanges)
+
+	seq, err := bucket.NextSequence()
+
+	if err != nil {
+		return err
+	}
+
+	change.ID = int64(seq)
+
+	data, err := json.Marshal(change)
+
+	if err != nil {
+		return err
+	}
+
+	changeBucket, err := bucket.CreateBucketIfNotExists([]byte(change.ShortURLID))
+
+	if err != nil {
+		return err
+	}
+
+	return changeBucket.Put(eventKey(change.ID), data)
+}
+
+// putEvent write event with next sequence of events bucket
+func putEvent(tx *bolt.Tx, event *core.Event) error {
+	bucket := tx.Bucket(bucketEvents)
+
+	seq, err := bucket.NextSequence()
+
+	if err != nil {
+		return err
+	}
+
+	event.ID = int64(seq)
+
+	data, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put(eventKey(event.ID), data)
+}
+
+func eventKey(id int64) []byte {
+	if id < 0 {
+		id = 0
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+
+	return key
+}
+
+// activeIDByURL ID of active short url with url or empty string. Deleted or expired short url does not hold url
+func activeIDByURL(tx *bolt.Tx, url string, now time.Time) (string, error) {
+	originID := tx.Bucket(bucketURLs).Get([]byte(url))
+
+	if originID == nil {
+		return "", nil
+	}
+
+	origin, err := getShortURL(tx, string(originID))
+
+	if err != nil {
+		return "", err
+	}
+
+	if origin == nil || !origin.IsActive(now) {
+		return "", nil
+	}
+
+	return origin.ID, nil
+}
+
+// putShortURL insert new short url and update indexes by url and user. Deleted short url is not indexed by url
+func putShortURL(tx *bolt.Tx, shortURL *core.ShortURL) error {
+	urls := tx.Bucket(bucketURLs)
+
+	if !shortURL.IsDeleted {
+		originID, err := activeIDByURL(tx, shortURL.URL, time.Now())
+
+		if err != nil {
+			return err
+		}
+
+		if originID != "" {
+			return storeerrors.NewShortURLCreateConflictError(originID)
+		}
+	}
+
+	shortURLs := tx.Bucket(bucketShortURLs)
+
+	if shortURLs.Get([]byte(shortURL.ID)) != nil {
+		return storeerrors.NewShortURLIDConflictError(shortURL.ID)
+	}
+
+	data, err := json.Marshal(shortURL)
+
+	if err != nil {
+		return err
+	}
+
+	if err := shortURLs.Put([]byte(shortURL.ID), data); err != nil {
+		return err
+	}
+
+	if !shortURL.IsDeleted {
+		if err := urls.Put([]byte(shortURL.URL), []byte(shortURL.ID)); err != nil {
+			return err
+		}
+	}
+
+	if !shortURL.UserID.Valid {
+		return nil
+	}
+
+	userBucket, err := tx.Bucket(bucketUsers).CreateBucketIfNotExists([]byte(shortURL.UserID.String))
+
+	if err != nil {
+		return err
+	}
+
+	seq, err := userBucket.NextSequence()
+
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+
+	return userBucket.Put(key, []byte(shortURL.ID))
+}
+
+func getShortURL(tx *bolt.Tx, id string) (*core.ShortURL, error) {
+	data := tx.Bucket(bucketShortURLs).Get([]byte(id))
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var shortURL core.ShortURL
+
+	if err := json.Unmarshal(data, &shortURL); err != nil {
+		return nil, err
+	}
+
+	return &shortURL, nil
+}
@@ -0,0 +1,127 @@
+package storagebolt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
+)
+
+func newTestStore(t *testing.T) *shortURLRepository {
+	storage, err := NewStorageBolt(zap.NewNop(), DSNPrefix+filepath.Join(t.TempDir(), "shortener.bolt"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		storage.Close()
+	})
+
+	return NewShortURLStore(zap.NewNop(), storage.DB)
+}
+
+func newShortURL(id, url, userID string) *core.ShortURL {
+	return &core.ShortURL{
+		ID:  id,
+		URL: url,
+		UserID: sql.NullString{
+			String: userID,
+			Valid:  userID != "",
+		},
+	}
+}
+
+func TestNewStorageBolt(t *testing.T) {
+	t.Run("should keep data after reopen", func(t *testing.T) {
+		dsn := DSNPrefix + filepath.Join(t.TempDir(), "shortener.bolt")
+
+		storage, err := NewStorageBolt(zap.NewNop(), dsn)
+		require.NoError(t, err)
+		require.NoError(t, NewShortURLStore(zap.NewNop(), storage.DB).Add(context.Background(), newShortURL("1", "https://vk.com", "1")))
+		require.NoError(t, storage.Close())
+
+		storage, err = NewStorageBolt(zap.NewNop(), dsn)
+		require.NoError(t, err)
+		defer storage.Close()
+
+		got, err := NewShortURLStore(zap.NewNop(), storage.DB).AllByUserID(context.Background(), "1")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(got))
+		assert.Equal(t, "https://vk.com", got[0].URL)
+	})
+}
+
+func Test_shortURLRepository_Add(t *testing.T) {
+	t.Run("should return conflict with origin id", func(t *testing.T) {
+		s := newTestStore(t)
+
+		require.NoError(t, s.Add(context.Background(), newShortURL("1", "https://vk.com", "1")))
+
+		err := s.Add(context.Background(), newShortURL("2", "https://vk.com", "2"))
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.True(t, errors.As(err, &conflictError))
+		assert.Equal(t, "1", conflictError.OriginID)
+
+		got, ok := s.GetByID(context.Background(), "1")
+		require.True(t, ok)
+		assert.Equal(t, "1", got.UserID.String)
+	})
+}
+
+func Test_shortURLRepository_CreateBatch(t *testing.T) {
+	t.Run("should not save any url when batch has conflict", func(t *testing.T) {
+		s := newTestStore(t)
+
+		require.NoError(t, s.Add(context.Background(), newShortURL("1", "https://vk.com/1", "1")))
+
+		err := s.CreateBatch(context.Background(), &[]*core.ShortURL{
+			newShortURL("2", "https://vk.com/2", "1"),
+			newShortURL("3", "https://vk.com/1", "1"),
+		})
+		require.Error(t, err)
+
+		_, ok := s.GetByID(context.Background(), "2")
+		assert.False(t, ok)
+
+		got, err := s.AllByUserID(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, 1, len(got))
+	})
+}
+
+func Test_shortURLRepository_DeleteURLsUserByIds(t *testing.T) {
+	t.Run("should delete only own urls and count stats", func(t *testing.T) {
+		s := newTestStore(t)
+
+		require.NoError(t, s.CreateBatch(context.Background(), &[]*core.ShortURL{
+			newShortURL("1", "https://vk.com/1", "1"),
+			newShortURL("2", "https://vk.com/2", "1"),
+			newShortURL("3", "https://vk.com/3", "3"),
+			newShortURL("4", "https://vk.com/4", ""),
+		}))
+		require.NoError(t, s.DeleteURLsUserByIds(context.Background(), "1", []string{"1", "3"}))
+
+		got, err := s.AllByUserID(context.Background(), "1")
+		require.NoError(t, err)
+		require.Equal(t, 2, len(got))
+		assert.True(t, got[0].IsDeleted)
+		assert.False(t, got[1].IsDeleted)
+
+		other, ok := s.GetByID(context.Background(), "3")
+		require.True(t, ok)
+		assert.False(t, other.IsDeleted)
+
+		stats, err := s.GetStats(context.Background(), core.StatsRange{})
+		require.NoError(t, err)
+		assert.Equal(t, 4, stats.URLs)
+		assert.Equal(t, 2, stats.Users)
+		assert.Equal(t, 1, stats.DeletedURLs)
+	})
+}
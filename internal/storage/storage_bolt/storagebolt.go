@@ -0,0 +1,79 @@
+// Package storagebolt хранилище во встроенном key-value хранилище bbolt
+package storagebolt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// DSNPrefix prefix of database dsn for select bolt storage. For example bolt://data/shortener.bolt
+const DSNPrefix = "bolt://"
+
+var (
+	bucketShortURLs = []byte("short_urls") // id -> json core.ShortURL
+	bucketURLs      = []byte("urls")       // url -> id
+	bucketUsers     = []byte("users")      // user id -> bucket with sequence -> id
+	bucketClicks    = []byte("clicks")     // short url id -> bucket with sequence -> json core.Click
+	bucketEvents    = []byte("events")     // sequence -> json core.Event
+	bucketWebhooks  = []byte("webhooks")   // id -> json core.Webhook
+	bucketDelivery  = []byte("delivery")   // cursor -> ID of last event passed to delivery of webhooks
+	bucketChanges   = []byte("changes")    // short url id -> bucket with change ID -> json core.URLChange, sequence of top bucket is last change ID
+)
+
+// StorageBolt storage include opened bolt database
+type StorageBolt struct {
+	DB *bolt.DB
+}
+
+// NewStorageBolt open database by dsn bolt://path and create buckets
+func NewStorageBolt(log *zap.Logger, dsn string) (*StorageBolt, error) {
+	path := strings.TrimPrefix(dsn, DSNPrefix)
+
+	if path == "" {
+		return nil, fmt.Errorf("path to bolt database is required: %s", dsn)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+
+	if err != nil {
+		log.Error("error when open bolt", zap.Error(err))
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{bucketShortURLs, bucketURLs, bucketUsers, bucketClicks, bucketEvents, bucketWebhooks, bucketDelivery, bucketChanges} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		db.Close()
+
+		return nil, fmt.Errorf("error when create bolt buckets: %w", err)
+	}
+
+	return &StorageBolt{
+		DB: db,
+	}, nil
+}
+
+// PingContext check database is opened
+func (s *StorageBolt) PingContext(_ context.Context) error {
+	return s.DB.View(func(_ *bolt.Tx) error {
+		return nil
+	})
+}
+
+// Close database
+func (s *StorageBolt) Close() error {
+	return s.DB.Close()
+}
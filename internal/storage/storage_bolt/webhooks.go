@@ -0,0 +1,187 @@
+package storagebolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	_ repositories.WebhookRepository = (*webhookRepository)(nil)
+)
+
+// keyCursor key of cursor in bucket delivery
+var keyCursor = []byte("cursor")
+
+type webhookRepository struct {
+	log *zap.Logger
+	db  *bolt.DB
+}
+
+// NewWebhookStore create bolt store of webhooks
+func NewWebhookStore(log *zap.Logger, db *bolt.DB) *webhookRepository {
+	return &webhookRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// AddWebhook Добавить подписку
+func (s *webhookRepository) AddWebhook(_ context.Context, webhook *core.Webhook) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putWebhook(tx, webhook)
+	})
+}
+
+// ListWebhooks подписки пользователя. Подписок немного, поэтому обходится весь bucket
+func (s *webhookRepository) ListWebhooks(_ context.Context, userID string) ([]*core.Webhook, error) {
+	var webhooks []*core.Webhook
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWebhooks).ForEach(func(_, data []byte) error {
+			var webhook core.Webhook
+
+			if err := json.Unmarshal(data, &webhook); err != nil {
+				return err
+			}
+
+			if webhook.UserID == userID {
+				webhooks = append(webhooks, &webhook)
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+
+	return webhooks, nil
+}
+
+// DeleteWebhook удалить подписку пользователя
+func (s *webhookRepository) DeleteWebhook(_ context.Context, userID, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		webhook, err := getWebhook(tx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if webhook == nil || webhook.UserID != userID {
+			return core.ErrWebhookNotFound
+		}
+
+		return tx.Bucket(bucketWebhooks).Delete([]byte(id))
+	})
+}
+
+// MarkWebhookFailed еще одна неудачная доставка подряд
+func (s *webhookRepository) MarkWebhookFailed(_ context.Context, id string, disableAfter int) (bool, error) {
+	disabled := false
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		webhook, err := getWebhook(tx, id)
+
+		if err != nil || webhook == nil {
+			return err
+		}
+
+		webhook.Failures++
+		webhook.Disabled = webhook.Failures >= disableAfter
+		disabled = webhook.Disabled
+
+		return putWebhook(tx, webhook)
+	})
+
+	return disabled, err
+}
+
+// MarkWebhookDelivered сбросить счетчик неудачных доставок
+func (s *webhookRepository) MarkWebhookDelivered(_ context.Context, id string) error {
+	failed := false
+
+	// Запись в bbolt дорогая, поэтому сначала проверяем, есть ли что сбрасывать
+	err := s.db.View(func(tx *bolt.Tx) error {
+		webhook, err := getWebhook(tx, id)
+		failed = webhook != nil && webhook.Failures > 0
+
+		return err
+	})
+
+	if err != nil || !failed {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		webhook, err := getWebhook(tx, id)
+
+		if err != nil || webhook == nil || webhook.Failures == 0 {
+			return err
+		}
+
+		webhook.Failures = 0
+
+		return putWebhook(tx, webhook)
+	})
+}
+
+// WebhookCursor курсор ленты событий, доставка которых завершена
+func (s *webhookRepository) WebhookCursor(_ context.Context) (int64, error) {
+	var cursor int64
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(bucketDelivery).Get(keyCursor); data != nil {
+			cursor = int64(binary.BigEndian.Uint64(data))
+		}
+
+		return nil
+	})
+
+	return cursor, err
+}
+
+// SaveWebhookCursor сохранить курсор ленты событий
+func (s *webhookRepository) SaveWebhookCursor(_ context.Context, cursor int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketDelivery).Put(keyCursor, eventKey(cursor))
+	})
+}
+
+func putWebhook(tx *bolt.Tx, webhook *core.Webhook) error {
+	data, err := json.Marshal(webhook)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(bucketWebhooks).Put([]byte(webhook.ID), data)
+}
+
+func getWebhook(tx *bolt.Tx, id string) (*core.Webhook, error) {
+	data := tx.Bucket(bucketWebhooks).Get([]byte(id))
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var webhook core.Webhook
+
+	if err := json.Unmarshal(data, &webhook); err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
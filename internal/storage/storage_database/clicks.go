@@ -0,0 +1,154 @@
+package storagedatabase
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	_ repositories.ClickRepository = (*clickRepository)(nil)
+)
+
+type clickRepository struct {
+	log *zap.Logger
+	db  *sql.DB
+}
+
+// NewClickStore create sql store of clicks
+func NewClickStore(log *zap.Logger, db *sql.DB) *clickRepository {
+	return &clickRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// AddClicks Добавить пачку кликов в одной транзакции
+func (s *clickRepository) AddClicks(ctx context.Context, clicks []*core.Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(
+		ctx,
+		`insert into click (short_url_id, created_at, referrer, user_agent, ip) values ($1, $2, $3, $4, $5);`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		_, err := stmt.ExecContext(ctx, click.ShortURLID, click.CreatedAt.UTC(), click.Referrer, click.UserAgent, click.IP)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetClickStats статистика кликов по короткой ссылке
+func (s *clickRepository) GetClickStats(ctx context.Context, shortURLID string, from, to time.Time, top int) (*core.ClickStats, error) {
+	var stats core.ClickStats
+
+	err := s.db.QueryRowContext(
+		ctx,
+		`select count(*), count(distinct nullif(ip, '')) from click where short_url_id = $1 and created_at >= $2 and created_at < $3;`,
+		shortURLID, from.UTC(), to.UTC(),
+	).Scan(&stats.Total, &stats.UniqueVisitors)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select date_trunc('day', created_at) as day, count(*) from click
+		where short_url_id = $1 and created_at >= $2 and created_at < $3
+		group by day order by day;`,
+		shortURLID, from.UTC(), to.UTC(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var day core.DayCount
+
+		if err := rows.Scan(&day.Day, &day.Count); err != nil {
+			return nil, err
+		}
+
+		day.Day = day.Day.UTC()
+		stats.Days = append(stats.Days, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.TopReferrers, err = s.topValues(ctx, "referrer", shortURLID, from, to, top); err != nil {
+		return nil, err
+	}
+
+	if stats.TopUserAgents, err = s.topValues(ctx, "user_agent", shortURLID, from, to, top); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// topValues самые частые значения колонки column
+func (s *clickRepository) topValues(
+	ctx context.Context,
+	column, shortURLID string,
+	from, to time.Time,
+	top int,
+) ([]core.ValueCount, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			`select %[1]s, count(*) as count from click
+			where short_url_id = $1 and created_at >= $2 and created_at < $3 and %[1]s <> ''
+			group by %[1]s order by count desc, %[1]s limit $4;`,
+			column,
+		),
+		shortURLID, from.UTC(), to.UTC(), top,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	values := make([]core.ValueCount, 0, top)
+
+	for rows.Next() {
+		var value core.ValueCount
+
+		if err := rows.Scan(&value.Value, &value.Count); err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
@@ -0,0 +1,60 @@
+package storagedatabase
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/pkg/database"
+	"github.com/shreyner/go-shortener/internal/repositories"
+	"github.com/shreyner/go-shortener/internal/repositories/repotest"
+)
+
+// testDataBaseDSNEnv env with dsn to test database. Tables in it will be truncated
+const testDataBaseDSNEnv = "SHORTENER_TEST_DATABASE_DSN"
+
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv(testDataBaseDSNEnv)
+
+	if dsn == "" {
+		t.Skipf("%s is not set", testDataBaseDSNEnv)
+	}
+
+	db, err := database.NewDataBase(zap.NewNop(), dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	migrator, err := NewMigrator(zap.NewNop(), db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(context.Background()))
+
+	repotest.Run(t, func(t *testing.T) repositories.ShortURLRepository {
+		_, err := db.Exec(`truncate short_url, outbox, url_change restart identity;`)
+		require.NoError(t, err)
+
+		s, err := NewShortURLStore(zap.NewNop(), db, nil)
+		require.NoError(t, err)
+
+		return s
+	})
+
+	repotest.RunClicks(t, func(t *testing.T) repositories.ClickRepository {
+		_, err := db.Exec(`truncate click;`)
+		require.NoError(t, err)
+
+		return NewClickStore(zap.NewNop(), db)
+	})
+
+	repotest.RunWebhooks(t, func(t *testing.T) repositories.WebhookRepository {
+		_, err := db.Exec(`truncate webhook, webhook_cursor;`)
+		require.NoError(t, err)
+
+		return NewWebhookStore(zap.NewNop(), db)
+	})
+}
@@ -0,0 +1,307 @@
+package storagedatabase
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// migrationsLockKey key of postgres advisory lock. Only one instance can apply migrations at once
+const migrationsLockKey = 7245310915
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+type migration struct {
+	version int64
+	name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus state of migration in database
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt sql.NullTime
+}
+
+// Migrator apply versioned migrations of database schema
+type Migrator struct {
+	log        *zap.Logger
+	db         *sql.DB
+	migrations []*migration
+}
+
+// NewMigrator create migrator with migrations embedded in binary
+func NewMigrator(log *zap.Logger, db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		log:        log,
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// loadMigrations read pairs of files NNNN_name.up.sql and NNNN_name.down.sql ordered by version
+func loadMigrations(fsys fs.FS, dir string) ([]*migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*migration{}
+
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		versionPart, namePart, ok := strings.Cut(strings.TrimSuffix(fileName, "."+direction+".sql"), "_")
+
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %s", fileName)
+		}
+
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", fileName, err)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+
+		if !ok {
+			m = &migration{version: version, name: namePart}
+			byVersion[version] = m
+		}
+
+		if m.name != namePart {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.name, namePart)
+		}
+
+		if direction == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]*migration, 0, len(byVersion))
+
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have up and down files", m.version, m.name)
+		}
+
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
+
+// Up apply all not applied migrations
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.version]; ok {
+				continue
+			}
+
+			m.log.Info("apply migration", zap.Int64("version", mig.version), zap.String("name", mig.name))
+
+			err := m.inTx(ctx, conn, mig.up, `insert into schema_migrations (version, name) values ($1, $2);`, mig.version, mig.name)
+
+			if err != nil {
+				return fmt.Errorf("error apply migration %d_%s: %w", mig.version, mig.name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down rollback last applied migrations by steps count
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+
+			if _, ok := applied[mig.version]; !ok {
+				continue
+			}
+
+			m.log.Info("rollback migration", zap.Int64("version", mig.version), zap.String("name", mig.name))
+
+			err := m.inTx(ctx, conn, mig.down, `delete from schema_migrations where version = $1;`, mig.version)
+
+			if err != nil {
+				return fmt.Errorf("error rollback migration %d_%s: %w", mig.version, mig.name, err)
+			}
+
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Status return all known migrations with time of applying
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var result []MigrationStatus
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			status := MigrationStatus{Version: mig.version, Name: mig.name}
+
+			if appliedAt, ok := applied[mig.version]; ok {
+				status.AppliedAt = sql.NullTime{Time: appliedAt, Valid: true}
+			}
+
+			result = append(result, status)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// withLock take advisory lock on dedicated connection and create table with migrations
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `select pg_advisory_lock($1);`, migrationsLockKey); err != nil {
+		return fmt.Errorf("error take migrations lock: %w", err)
+	}
+
+	defer func() {
+		// Контекст мог быть отменен, но блокировку нужно отпустить
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if _, err := conn.ExecContext(unlockCtx, `select pg_advisory_unlock($1);`, migrationsLockKey); err != nil {
+			m.log.Error("error release migrations lock", zap.Error(err))
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		create table if not exists schema_migrations
+		(
+			version    bigint primary key,
+			name       varchar                 not null,
+			applied_at timestamp default now() not null
+		);
+	`)
+
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `select version, applied_at from schema_migrations;`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// inTx exec migration and update schema_migrations in one transaction
+func (m *Migrator) inTx(ctx context.Context, conn *sql.Conn, migrationSQL, query string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, migrationSQL); err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
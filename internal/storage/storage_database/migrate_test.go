@@ -0,0 +1,51 @@
+package storagedatabase
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadMigrations(t *testing.T) {
+	t.Run("should load embedded migrations", func(t *testing.T) {
+		migrations, err := loadMigrations(migrationsFS, "migrations")
+
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+		assert.Equal(t, int64(1), migrations[0].version)
+
+		for i := 1; i < len(migrations); i++ {
+			assert.Less(t, migrations[i-1].version, migrations[i].version)
+		}
+	})
+
+	t.Run("should sort by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/0010_second.up.sql":   {Data: []byte("select 2;")},
+			"m/0010_second.down.sql": {Data: []byte("select -2;")},
+			"m/0002_first.up.sql":    {Data: []byte("select 1;")},
+			"m/0002_first.down.sql":  {Data: []byte("select -1;")},
+			"m/README.md":            {Data: []byte("skip")},
+		}
+
+		migrations, err := loadMigrations(fsys, "m")
+
+		require.NoError(t, err)
+		require.Equal(t, 2, len(migrations))
+		assert.Equal(t, "first", migrations[0].name)
+		assert.Equal(t, "select -1;", migrations[0].down)
+		assert.Equal(t, int64(10), migrations[1].version)
+	})
+
+	t.Run("should error without down file", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/0001_first.up.sql": {Data: []byte("select 1;")},
+		}
+
+		_, err := loadMigrations(fsys, "m")
+
+		assert.Error(t, err)
+	})
+}
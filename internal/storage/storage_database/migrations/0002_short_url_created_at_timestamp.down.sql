@@ -0,0 +1,5 @@
+drop index if exists short_url_user_id_created_at_index;
+
+alter table short_url
+    alter column created_at type date using created_at::date,
+    alter column created_at set default current_date;
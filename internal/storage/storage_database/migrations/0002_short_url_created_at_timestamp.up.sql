@@ -0,0 +1,6 @@
+alter table short_url
+    alter column created_at type timestamp using created_at::timestamp,
+    alter column created_at set default now();
+
+create index if not exists short_url_user_id_created_at_index
+    on short_url (user_id, created_at, id);
@@ -0,0 +1,4 @@
+drop index if exists short_url_expires_at_index;
+
+alter table short_url
+    drop column if exists expires_at;
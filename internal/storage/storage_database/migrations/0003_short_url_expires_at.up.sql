@@ -0,0 +1,5 @@
+alter table short_url
+    add column if not exists expires_at timestamp;
+
+create index if not exists short_url_expires_at_index
+    on short_url (expires_at) where not deleted and expires_at is not null;
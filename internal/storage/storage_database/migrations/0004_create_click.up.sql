@@ -0,0 +1,12 @@
+create table if not exists click
+(
+    id           bigserial primary key,
+    short_url_id varchar   not null,
+    created_at   timestamp not null,
+    referrer     varchar   not null default '',
+    user_agent   varchar   not null default '',
+    ip           varchar   not null default ''
+);
+
+create index if not exists click_short_url_id_created_at_index
+    on click (short_url_id, created_at);
@@ -0,0 +1,9 @@
+create table if not exists outbox
+(
+    id           bigserial primary key,
+    type         varchar   not null,
+    short_url_id varchar   not null,
+    url          varchar   not null,
+    user_id      varchar   not null default '',
+    created_at   timestamp not null
+);
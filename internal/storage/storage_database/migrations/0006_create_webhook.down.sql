@@ -0,0 +1,2 @@
+drop table if exists webhook_cursor;
+drop table if exists webhook;
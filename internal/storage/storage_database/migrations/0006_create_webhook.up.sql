@@ -0,0 +1,20 @@
+create table if not exists webhook
+(
+    id         varchar   not null primary key,
+    user_id    varchar   not null,
+    url        varchar   not null,
+    secret     varchar   not null,
+    events     varchar   not null,
+    failures   integer   not null default 0,
+    disabled   boolean   not null default false,
+    created_at timestamp not null
+);
+
+create index if not exists webhook_user_id_index
+    on webhook (user_id, created_at);
+
+create table if not exists webhook_cursor
+(
+    id     integer not null primary key check (id = 1),
+    cursor bigint  not null
+);
@@ -0,0 +1 @@
+drop table if exists url_change;
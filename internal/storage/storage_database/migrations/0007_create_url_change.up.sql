@@ -0,0 +1,12 @@
+create table if not exists url_change
+(
+    id           bigserial primary key,
+    short_url_id varchar   not null,
+    user_id      varchar   not null,
+    old_url      varchar   not null,
+    new_url      varchar   not null,
+    changed_at   timestamp not null
+);
+
+create index if not exists url_change_short_url_id_index
+    on url_change (short_url_id, id);
@@ -0,0 +1,2 @@
+alter table short_url drop column if exists cache_max_age;
+alter table short_url drop column if exists redirect_type;
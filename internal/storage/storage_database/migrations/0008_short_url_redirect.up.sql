@@ -0,0 +1,2 @@
+alter table short_url add column if not exists redirect_type integer not null default 0;
+alter table short_url add column if not exists cache_max_age integer;
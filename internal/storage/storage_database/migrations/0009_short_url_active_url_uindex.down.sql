@@ -0,0 +1,18 @@
+-- Full unique index on url can't be restored when url of deleted or expired short url was shortened again.
+-- Rollback is refused until such duplicates are removed by hand, schema stays unchanged
+do $$
+declare
+    duplicate_url text;
+begin
+    select url into duplicate_url from short_url group by url having count(*) > 1 limit 1;
+
+    if duplicate_url is not null then
+        raise exception 'can not rollback 0009_short_url_active_url_uindex: url % is used by several short urls, remove deleted duplicates first', duplicate_url;
+    end if;
+end
+$$;
+
+drop index if exists short_url_uindex;
+
+create unique index if not exists short_url_uindex
+    on short_url (url);
@@ -0,0 +1,4 @@
+drop index if exists short_url_uindex;
+
+create unique index if not exists short_url_uindex
+    on short_url (url) where not deleted;
@@ -0,0 +1,4 @@
+drop index if exists outbox_unpositioned_index;
+drop index if exists outbox_position_uindex;
+
+alter table outbox drop column if exists position;
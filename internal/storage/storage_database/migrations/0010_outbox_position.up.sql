@@ -0,0 +1,9 @@
+alter table outbox add column if not exists position bigint;
+
+update outbox set position = id where position is null;
+
+create unique index if not exists outbox_position_uindex
+    on outbox (position);
+
+create index if not exists outbox_unpositioned_index
+    on outbox (id) where position is null;
@@ -0,0 +1,197 @@
+package storagedatabase
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	replicaCheckInterval = 5 * time.Second
+	replicaCheckTimeout  = time.Second
+	// recentWriteTTL time when reads by written short url or user go to primary, should be greater than replication lag
+	recentWriteTTL = 10 * time.Second
+)
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// ReplicaSet read replicas of database. Reads are spread by round robin between healthy replicas,
+// replicas are checked in background and drop out of rotation on failed ping
+type ReplicaSet struct {
+	log      *zap.Logger
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewReplicaSet create set and start health checks. Replicas are named by index in logs, dsn may have password
+func NewReplicaSet(log *zap.Logger, primary *sql.DB, replicaDBs []*sql.DB) *ReplicaSet {
+	r := &ReplicaSet{
+		log:     log,
+		primary: primary,
+		done:    make(chan struct{}),
+	}
+
+	for i, db := range replicaDBs {
+		r.replicas = append(r.replicas, &replica{
+			name: fmt.Sprintf("replica-%d", i),
+			db:   db,
+		})
+	}
+
+	r.check(context.Background())
+
+	r.wg.Add(1)
+	go r.checker()
+
+	return r
+}
+
+// Reader return healthy replica or primary when all replicas are down
+func (r *ReplicaSet) Reader() *sql.DB {
+	count := len(r.replicas)
+
+	for i := 0; i < count; i++ {
+		item := r.replicas[int(r.next.Add(1)%uint64(count))]
+
+		if item.healthy.Load() {
+			return item.db
+		}
+	}
+
+	return r.primary
+}
+
+// Healthy count of replicas in rotation
+func (r *ReplicaSet) Healthy() int {
+	healthy := 0
+
+	for _, item := range r.replicas {
+		if item.healthy.Load() {
+			healthy++
+		}
+	}
+
+	return healthy
+}
+
+// Close stop health checks and close connections to replicas. Return first error of close
+func (r *ReplicaSet) Close() error {
+	var errClose error
+
+	r.once.Do(func() {
+		close(r.done)
+		r.wg.Wait()
+
+		for _, item := range r.replicas {
+			if err := item.db.Close(); err != nil && errClose == nil {
+				errClose = err
+			}
+		}
+	})
+
+	return errClose
+}
+
+func (r *ReplicaSet) checker() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.check(context.Background())
+		}
+	}
+}
+
+// check ping all replicas in parallel and update rotation
+func (r *ReplicaSet) check(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, item := range r.replicas {
+		wg.Add(1)
+
+		go func(item *replica) {
+			defer wg.Done()
+
+			pingCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+			defer cancel()
+
+			err := item.db.PingContext(pingCtx)
+			healthy := err == nil
+
+			if item.healthy.Swap(healthy) == healthy {
+				return
+			}
+
+			if healthy {
+				r.log.Info("replica is back in rotation", zap.String("replica", item.name))
+			} else {
+				r.log.Warn("replica is out of rotation", zap.String("replica", item.name), zap.Error(err))
+			}
+		}(item)
+	}
+
+	wg.Wait()
+}
+
+// recentWrites keys of recently written short urls and users, reads by them go to primary
+type recentWrites struct {
+	mutex sync.Mutex
+	keys  map[string]time.Time
+	ttl   time.Duration
+}
+
+func newRecentWrites(ttl time.Duration) *recentWrites {
+	return &recentWrites{
+		keys: map[string]time.Time{},
+		ttl:  ttl,
+	}
+}
+
+func (w *recentWrites) add(keys ...string) {
+	now := time.Now()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// Чистим устаревшие ключи при росте, чтобы не держать отдельную горутину
+	if len(w.keys) > 10000 {
+		for key, expiresAt := range w.keys {
+			if now.After(expiresAt) {
+				delete(w.keys, key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		w.keys[key] = now.Add(w.ttl)
+	}
+}
+
+func (w *recentWrites) has(key string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	expiresAt, ok := w.keys[key]
+
+	return ok && time.Now().Before(expiresAt)
+}
@@ -0,0 +1,208 @@
+package storagedatabase
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// openTestDB in memory database which answer on ping
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db
+}
+
+func TestReplicaSet(t *testing.T) {
+	t.Run("should spread reads between healthy replicas", func(t *testing.T) {
+		primary := openTestDB(t)
+		replica1 := openTestDB(t)
+		replica2 := openTestDB(t)
+
+		replicas := NewReplicaSet(zap.NewNop(), primary, []*sql.DB{replica1, replica2})
+		defer replicas.Close()
+
+		assert.Equal(t, 2, replicas.Healthy())
+
+		got := map[*sql.DB]int{}
+
+		for i := 0; i < 10; i++ {
+			got[replicas.Reader()]++
+		}
+
+		assert.Equal(t, map[*sql.DB]int{replica1: 5, replica2: 5}, got)
+	})
+
+	t.Run("should drop failed replica out of rotation", func(t *testing.T) {
+		primary := openTestDB(t)
+		replica1 := openTestDB(t)
+		replica2 := openTestDB(t)
+
+		replicas := NewReplicaSet(zap.NewNop(), primary, []*sql.DB{replica1, replica2})
+		defer replicas.Close()
+
+		require.NoError(t, replica1.Close())
+		replicas.check(context.Background())
+
+		assert.Equal(t, 1, replicas.Healthy())
+
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, replica2, replicas.Reader())
+		}
+
+		require.NoError(t, replica2.Close())
+		replicas.check(context.Background())
+
+		assert.Equal(t, 0, replicas.Healthy())
+		assert.Equal(t, primary, replicas.Reader())
+	})
+}
+
+func TestShortURLRepository_reader(t *testing.T) {
+	primary := openTestDB(t)
+	replica := openTestDB(t)
+
+	replicas := NewReplicaSet(zap.NewNop(), primary, []*sql.DB{replica})
+	defer replicas.Close()
+
+	s := &shortURLRepository{
+		db:       primary,
+		replicas: replicas,
+		recent:   newRecentWrites(time.Hour),
+	}
+
+	s.markWritten(&core.ShortURL{ID: "1", UserID: sql.NullString{String: "user1", Valid: true}})
+
+	t.Run("should read recently written from primary", func(t *testing.T) {
+		assert.Equal(t, primary, s.reader(idKey("1")))
+		assert.Equal(t, primary, s.reader(userKey("user1")))
+	})
+
+	t.Run("should read other from replica", func(t *testing.T) {
+		assert.Equal(t, replica, s.reader(idKey("2")))
+		assert.Equal(t, replica, s.reader(userKey("user2")))
+		assert.Equal(t, replica, s.reader(""))
+	})
+}
+
+// openTestShortURLDB in memory database with table short_url of columns which are read by repository
+func openTestShortURLDB(t *testing.T) *sql.DB {
+	db := openTestDB(t)
+
+	_, err := db.Exec(`create table short_url (
+		id text, url text, user_id text, deleted boolean default false, created_at timestamp default current_timestamp,
+		expires_at timestamp, redirect_type integer default 0, cache_max_age integer
+	);`)
+	require.NoError(t, err)
+
+	return db
+}
+
+func insertTestShortURL(t *testing.T, db *sql.DB, id, userID string) {
+	_, err := db.Exec(`insert into short_url (id, url, user_id) values (?, ?, ?);`, id, "https://vk.com/"+id, userID)
+	require.NoError(t, err)
+}
+
+func TestShortURLRepository_GetByID(t *testing.T) {
+	ctx := context.Background()
+
+	primary := openTestShortURLDB(t)
+	replica := openTestShortURLDB(t)
+
+	replicas := NewReplicaSet(zap.NewNop(), primary, []*sql.DB{replica})
+	defer replicas.Close()
+
+	s := &shortURLRepository{
+		db:       primary,
+		replicas: replicas,
+		recent:   newRecentWrites(time.Hour),
+	}
+
+	insertTestShortURL(t, primary, "recent", "")
+	insertTestShortURL(t, primary, "other", "")
+	s.markWritten(&core.ShortURL{ID: "recent"})
+
+	t.Run("should read recently written from primary", func(t *testing.T) {
+		got, ok := s.GetByID(ctx, "recent")
+		require.True(t, ok)
+		assert.Equal(t, "recent", got.ID)
+	})
+
+	t.Run("should not read primary on miss of replica outside of recent writes", func(t *testing.T) {
+		_, ok := s.GetByID(ctx, "other")
+		assert.False(t, ok)
+	})
+
+	t.Run("should read primary on error of replica", func(t *testing.T) {
+		require.NoError(t, replica.Close())
+
+		got, ok := s.GetByID(ctx, "other")
+		require.True(t, ok)
+		assert.Equal(t, "other", got.ID)
+	})
+}
+
+func TestShortURLRepository_ListByUserID(t *testing.T) {
+	ctx := context.Background()
+
+	primary := openTestShortURLDB(t)
+	replica := openTestShortURLDB(t)
+
+	replicas := NewReplicaSet(zap.NewNop(), primary, []*sql.DB{replica})
+	defer replicas.Close()
+
+	s := &shortURLRepository{
+		db:       primary,
+		replicas: replicas,
+		recent:   newRecentWrites(time.Hour),
+	}
+
+	insertTestShortURL(t, replica, "replica1", "user1")
+	insertTestShortURL(t, replica, "replica2", "user1")
+	insertTestShortURL(t, primary, "recent", "user2")
+	s.markWritten(&core.ShortURL{ID: "recent", UserID: sql.NullString{String: "user2", Valid: true}})
+
+	t.Run("should read pages of user from replica", func(t *testing.T) {
+		page, err := s.ListByUserID(ctx, "user1", core.ListOptions{Limit: 1})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(page.ShortURLs))
+		assert.Equal(t, "replica1", page.ShortURLs[0].ID)
+
+		page, err = s.ListByUserID(ctx, "user1", core.ListOptions{Limit: 1, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(page.ShortURLs))
+		assert.Equal(t, "replica2", page.ShortURLs[0].ID)
+	})
+
+	t.Run("should read user with recent writes from primary", func(t *testing.T) {
+		page, err := s.ListByUserID(ctx, "user2", core.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(page.ShortURLs))
+		assert.Equal(t, "recent", page.ShortURLs[0].ID)
+	})
+}
+
+func TestRecentWrites(t *testing.T) {
+	t.Run("should forget keys after ttl", func(t *testing.T) {
+		w := newRecentWrites(10 * time.Millisecond)
+		w.add("a")
+
+		assert.True(t, w.has("a"))
+		assert.False(t, w.has("b"))
+
+		assert.Eventually(t, func() bool { return !w.has("a") }, time.Second, 5*time.Millisecond)
+	})
+}
@@ -3,7 +3,11 @@ package storagedatabase
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgconn"
 	"go.uber.org/zap"
 
 	"github.com/shreyner/go-shortener/internal/core"
@@ -11,19 +15,31 @@ import (
 	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
 )
 
+// uniqueViolationCode postgres error code unique_violation
+const uniqueViolationCode = "23505"
+
+// outboxLockKey key of postgres advisory lock. Only readers of feed take it while assign positions to committed events,
+// writers of outbox insert events without lock
+const outboxLockKey = 7245310916
+
 var (
 	_ repositories.ShortURLRepository = (*shortURLRepository)(nil)
+	_ repositories.ShortURLScanner    = (*shortURLRepository)(nil)
+	_ repositories.EventRepository    = (*shortURLRepository)(nil)
 )
 
 type shortURLRepository struct {
 	log        *zap.Logger
 	db         *sql.DB
 	insertStmt *sql.Stmt
+	replicas   *ReplicaSet
+	recent     *recentWrites
 }
 
-// NewShortURLStore create sql store
-func NewShortURLStore(log *zap.Logger, db *sql.DB) (*shortURLRepository, error) {
-	insertStmt, err := db.Prepare("insert into short_url (id, url, user_id, correlation_id) values ($1, $2, $3, $4);")
+// NewShortURLStore create sql store. With replicas GetByID, AllByUserID and GetStats are read from replicas,
+// except short urls and users written recently by this store. Nil replicas mean all queries go to db
+func NewShortURLStore(log *zap.Logger, db *sql.DB, replicas *ReplicaSet) (*shortURLRepository, error) {
+	insertStmt, err := db.Prepare("insert into short_url (id, url, user_id, correlation_id, created_at, expires_at, redirect_type, cache_max_age, deleted) values ($1, $2, $3, $4, $5, $6, $7, $8, $9) on conflict (url) where not deleted do update set url=excluded.url returning id;")
 
 	if err != nil {
 		return nil, err
@@ -33,19 +49,72 @@ func NewShortURLStore(log *zap.Logger, db *sql.DB) (*shortURLRepository, error)
 		log:        log,
 		db:         db,
 		insertStmt: insertStmt,
+		replicas:   replicas,
+		recent:     newRecentWrites(recentWriteTTL),
 	}, nil
 }
 
-// Add Добавить короткую ссылку в store
+// reader replica for read or primary when key was written recently
+func (s *shortURLRepository) reader(key string) *sql.DB {
+	if s.replicas == nil || (key != "" && s.recent.has(key)) {
+		return s.db
+	}
+
+	return s.replicas.Reader()
+}
+
+// markWritten read by short url and its owner go to primary while replicas may lag
+func (s *shortURLRepository) markWritten(shortURL *core.ShortURL) {
+	if s.replicas == nil {
+		return
+	}
+
+	keys := []string{idKey(shortURL.ID)}
+
+	if shortURL.UserID.Valid {
+		keys = append(keys, userKey(shortURL.UserID.String))
+	}
+
+	s.recent.add(keys...)
+}
+
+func idKey(id string) string {
+	return "id:" + id
+}
+
+func userKey(userID string) string {
+	return "user:" + userID
+}
+
+// Add Добавить короткую ссылку в store вместе с событием created
 func (s *shortURLRepository) Add(ctx context.Context, shortURL *core.ShortURL) error {
-	result := s.db.QueryRowContext(
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := expireByURL(ctx, tx, shortURL.URL, time.Now()); err != nil {
+		return err
+	}
+
+	result := tx.QueryRowContext(
 		ctx,
-		`insert into short_url (id, url, user_id) values ($1, $2, $3) on conflict (url) do update set url=excluded.url returning id;`,
+		`insert into short_url (id, url, user_id, created_at, expires_at, redirect_type, cache_max_age) values ($1, $2, $3, $4, $5, $6, $7) on conflict (url) where not deleted do update set url=excluded.url returning id;`,
 		shortURL.ID,
 		shortURL.URL,
 		shortURL.UserID,
+		shortURL.CreatedAt.UTC(),
+		nullTimeUTC(shortURL.ExpiresAt),
+		shortURL.RedirectType,
+		shortURL.CacheMaxAge,
 	)
 
+	if isIDUniqueViolation(result.Err()) {
+		return storeerrors.NewShortURLIDConflictError(shortURL.ID)
+	}
+
 	if result.Err() != nil {
 		return result.Err()
 	}
@@ -59,35 +128,58 @@ func (s *shortURLRepository) Add(ctx context.Context, shortURL *core.ShortURL) e
 		return storeerrors.NewShortURLCreateConflictError(resultID)
 	}
 
+	if err := insertEvents(ctx, tx, core.EventCreated, []string{shortURL.ID}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.markWritten(shortURL)
+
 	return nil
 }
 
-// GetByID Получить короткую ссылку по идентификатору
+// GetByID Получить короткую ссылку по идентификатору.
+// Недавно записанная ссылка читается с primary, поэтому промах реплики не перечитывается с primary:
+// иначе перебор несуществующих ID шел бы на primary. При ошибке реплики чтение повторяется на primary
 func (s *shortURLRepository) GetByID(ctx context.Context, id string) (*core.ShortURL, bool) {
+	db := s.reader(idKey(id))
+	shortURL, err := getShortURL(ctx, db, id)
+
+	if err != nil && db != s.db && !errors.Is(err, sql.ErrNoRows) {
+		shortURL, err = getShortURL(ctx, s.db, id)
+	}
+
+	if err != nil {
+		return nil, false
+	}
+
+	return shortURL, true
+}
+
+func getShortURL(ctx context.Context, db *sql.DB, id string) (*core.ShortURL, error) {
 	var shortURL core.ShortURL
 
-	row := s.db.QueryRowContext(
+	row := db.QueryRowContext(
 		ctx,
-		`select id, url, user_id, deleted from short_url where id = $1`,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where id = $1`,
 		id,
 	)
 
-	if row.Err() != nil {
-		return nil, false
-	}
-
-	if err := row.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted); err != nil {
-		return nil, false
+	if err := row.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge); err != nil {
+		return nil, err
 	}
 
-	return &shortURL, true
+	return &shortURL, nil
 }
 
 // AllByUserID получить все ссылки по идентификатору пользователя
 func (s *shortURLRepository) AllByUserID(ctx context.Context, id string) ([]*core.ShortURL, error) {
-	rows, err := s.db.QueryContext(
+	rows, err := s.reader(userKey(id)).QueryContext(
 		ctx,
-		`select id, url, user_id from short_url where user_id = $1`,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where user_id = $1`,
 		id,
 	)
 
@@ -102,7 +194,7 @@ func (s *shortURLRepository) AllByUserID(ctx context.Context, id string) ([]*cor
 	for rows.Next() {
 		shortURL := core.ShortURL{}
 
-		if err := rows.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID); err != nil {
+		if err := rows.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge); err != nil {
 			return nil, err
 		}
 
@@ -116,7 +208,77 @@ func (s *shortURLRepository) AllByUserID(ctx context.Context, id string) ([]*cor
 	return shortURLs, nil
 }
 
-// CreateBatch Добавление ссылок пачкой
+// ListByUserID получить страницу ссылок пользователя. Следующая страница ищется по ключу сортировки из курсора
+func (s *shortURLRepository) ListByUserID(ctx context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error) {
+	cursor, err := repositories.DecodeCursor(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	limit := repositories.NormalizeLimit(opts.Limit)
+
+	direction, compare := "asc", ">"
+
+	if opts.Desc {
+		direction, compare = "desc", "<"
+	}
+
+	query := `select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where user_id = $1`
+	args := []interface{}{id}
+
+	if opts.SortBy == core.SortByCreatedAt {
+		if cursor != nil {
+			query += ` and (created_at, id) ` + compare + ` ($2, $3)`
+			args = append(args, cursor.CreatedAt.UTC(), cursor.ID)
+		}
+
+		query += ` order by created_at ` + direction + `, id ` + direction
+	} else {
+		if cursor != nil {
+			query += ` and id ` + compare + ` $2`
+			args = append(args, cursor.ID)
+		}
+
+		query += ` order by id ` + direction
+	}
+
+	args = append(args, limit+1)
+	query += fmt.Sprintf(` limit $%d`, len(args))
+
+	rows, err := s.reader(userKey(id)).QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var page core.ShortURLPage
+
+	for rows.Next() {
+		shortURL := core.ShortURL{}
+
+		if err := rows.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge); err != nil {
+			return nil, err
+		}
+
+		page.ShortURLs = append(page.ShortURLs, &shortURL)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.ShortURLs) > limit {
+		page.ShortURLs = page.ShortURLs[:limit]
+		page.NextCursor = repositories.EncodeCursor(page.ShortURLs[limit-1], opts)
+	}
+
+	return &page, nil
+}
+
+// CreateBatch Добавление ссылок пачкой. Уже сокращенный url, как и в Add, возвращает ShortURLCreateConflictError
 func (s *shortURLRepository) CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 
@@ -134,59 +296,583 @@ func (s *shortURLRepository) CreateBatch(ctx context.Context, shortURLs *[]*core
 
 	defer txStmt.Close()
 
+	now := time.Now()
+
 	for _, v := range *shortURLs {
-		if _, err := txStmt.ExecContext(ctx, v.ID, v.URL, v.UserID, v.CorrelationID); err != nil {
+		if !v.IsDeleted {
+			if err := expireByURL(ctx, tx, v.URL, now); err != nil {
+				return err
+			}
+		}
+
+		var resultID string
+
+		err := txStmt.QueryRowContext(ctx, v.ID, v.URL, v.UserID, v.CorrelationID, v.CreatedAt.UTC(), nullTimeUTC(v.ExpiresAt), v.RedirectType, v.CacheMaxAge, v.IsDeleted).Scan(&resultID)
+
+		if isIDUniqueViolation(err) {
+			return storeerrors.NewShortURLIDConflictError(v.ID)
+		}
+
+		if err != nil {
 			return err
 		}
+
+		if resultID != v.ID {
+			return storeerrors.NewShortURLCreateConflictError(resultID)
+		}
 	}
 
-	return tx.Commit()
+	ids := make([]string, len(*shortURLs))
+
+	var deletedIDs []string
+
+	for i, v := range *shortURLs {
+		ids[i] = v.ID
+
+		if v.IsDeleted {
+			deletedIDs = append(deletedIDs, v.ID)
+		}
+	}
+
+	if err := insertEvents(ctx, tx, core.EventCreated, ids); err != nil {
+		return err
+	}
+
+	if err := insertEvents(ctx, tx, core.EventDeleted, deletedIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, v := range *shortURLs {
+		s.markWritten(v)
+	}
+
+	return nil
 }
 
-// DeleteURLsUserByIds Удаление пачкой коротких ссылок от имени пользователя
+// DeleteURLsUserByIds Удаление пачкой коротких ссылок от имени пользователя вместе с событиями deleted
 func (s *shortURLRepository) DeleteURLsUserByIds(ctx context.Context, userID string, ids []string) error {
 	s.log.Info("Was deleted", zap.String("userID", userID), zap.Strings("ids", ids))
 
-	_, err := s.db.ExecContext(
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deletedIDs, err := updateReturningIDs(
 		ctx,
-		`update short_url set deleted = true where user_id = $1 and id = any ($2);`,
+		tx,
+		`update short_url set deleted = true where not deleted and user_id = $1 and id = any ($2) returning id;`,
 		userID,
 		ids,
 	)
 
+	if err != nil {
+		return err
+	}
+
+	if err := insertEvents(ctx, tx, core.EventDeleted, deletedIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if s.replicas != nil {
+		keys := []string{userKey(userID)}
+
+		for _, id := range ids {
+			keys = append(keys, idKey(id))
+		}
+
+		s.recent.add(keys...)
+	}
+
+	return nil
+}
+
+// DeleteExpired Пометить удаленными ссылки с истекшим сроком жизни вместе с событиями expired
+func (s *shortURLRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ids, err := updateReturningIDs(
+		ctx,
+		tx,
+		`update short_url set deleted = true where not deleted and expires_at is not null and expires_at <= $1 returning id;`,
+		now.UTC(),
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertEvents(ctx, tx, core.EventExpired, ids); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// UpdateURL Изменить url короткой ссылки владельцем вместе с записью изменения и событием updated.
+// Строка ссылки блокируется до конца транзакции, поэтому параллельные изменения одной ссылки пишутся по очереди
+func (s *shortURLRepository) UpdateURL(ctx context.Context, change *core.URLChange, redirect core.RedirectUpdate) (*core.ShortURL, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var shortURL core.ShortURL
+
+	err = tx.QueryRowContext(
+		ctx,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where id = $1 for update`,
+		change.ShortURLID,
+	).Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.ErrShortURLNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repositories.CheckURLChange(&shortURL, change.UserID); err != nil {
+		return nil, err
+	}
+
+	change.OldURL = shortURL.URL
+	redirect.ApplyTo(&shortURL)
+
+	if shortURL.URL == change.NewURL {
+		if redirect.IsEmpty() {
+			return &shortURL, nil
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`update short_url set redirect_type = $1, cache_max_age = $2 where id = $3;`,
+			shortURL.RedirectType, shortURL.CacheMaxAge, shortURL.ID,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		s.markWritten(&shortURL)
+
+		return &shortURL, nil
+	}
+
+	if err := expireByURL(ctx, tx, change.NewURL, change.ChangedAt); err != nil {
+		return nil, err
+	}
+
+	var conflictID string
+
+	err = tx.QueryRowContext(ctx, `select id from short_url where url = $1 and not deleted`, change.NewURL).Scan(&conflictID)
+
+	if err == nil {
+		return nil, storeerrors.NewShortURLCreateConflictError(conflictID)
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update short_url set url = $1, redirect_type = $2, cache_max_age = $3 where id = $4;`,
+		change.NewURL, shortURL.RedirectType, shortURL.CacheMaxAge, change.ShortURLID,
+	)
+
+	// Ссылка с тем же url могла быть создана параллельно после проверки выше, ее ID читается вне прерванной транзакции
+	if isURLUniqueViolation(err) {
+		tx.Rollback()
+
+		var originID string
+
+		if err := s.db.QueryRowContext(ctx, `select id from short_url where url = $1 and not deleted`, change.NewURL).Scan(&originID); err != nil {
+			s.log.Error("error get origin id of conflict", zap.String("url", change.NewURL), zap.Error(err))
+		}
+
+		return nil, storeerrors.NewShortURLCreateConflictError(originID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var changeID int64
+
+	err = tx.QueryRowContext(
+		ctx,
+		`insert into url_change (short_url_id, user_id, old_url, new_url, changed_at) values ($1, $2, $3, $4, $5) returning id;`,
+		change.ShortURLID, change.UserID, change.OldURL, change.NewURL, change.ChangedAt.UTC(),
+	).Scan(&changeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertEvents(ctx, tx, core.EventUpdated, []string{change.ShortURLID}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	change.ID = changeID
+	shortURL.URL = change.NewURL
+
+	s.markWritten(&shortURL)
+
+	return &shortURL, nil
+}
+
+// SetRedirect Изменить тип редиректа и max-age короткой ссылки владельцем
+func (s *shortURLRepository) SetRedirect(ctx context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var shortURL core.ShortURL
+
+	err = tx.QueryRowContext(
+		ctx,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where id = $1 for update`,
+		id,
+	).Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.ErrShortURLNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repositories.CheckURLChange(&shortURL, userID); err != nil {
+		return nil, err
+	}
+
+	update.ApplyTo(&shortURL)
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update short_url set redirect_type = $1, cache_max_age = $2 where id = $3;`,
+		shortURL.RedirectType, shortURL.CacheMaxAge, id,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.markWritten(&shortURL)
+
+	return &shortURL, nil
+}
+
+// URLChanges изменения url короткой ссылки по порядку. Читаются с primary, реплика может отставать
+func (s *shortURLRepository) URLChanges(ctx context.Context, shortURLID string) ([]*core.URLChange, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select id, short_url_id, user_id, old_url, new_url, changed_at from url_change where short_url_id = $1 order by id`,
+		shortURLID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var changes []*core.URLChange
+
+	for rows.Next() {
+		change := core.URLChange{}
+
+		if err := rows.Scan(&change.ID, &change.ShortURLID, &change.UserID, &change.OldURL, &change.NewURL, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// expireByURL mark deleted expired short url with url, so url can be shorted again. Event expired is written as in DeleteExpired
+func expireByURL(ctx context.Context, tx *sql.Tx, url string, now time.Time) error {
+	ids, err := updateReturningIDs(
+		ctx,
+		tx,
+		`update short_url set deleted = true where not deleted and url = $1 and expires_at is not null and expires_at <= $2 returning id;`,
+		url,
+		now.UTC(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return insertEvents(ctx, tx, core.EventExpired, ids)
+}
+
+// updateReturningIDs exec update with returning id and return changed ids
+func updateReturningIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ids []string
+
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// insertEvents write into outbox events for short urls in transaction of change.
+// Event gets position in feed only after commit, see assignEventPositions
+func insertEvents(ctx context.Context, tx *sql.Tx, eventType core.EventType, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(
+		ctx,
+		`insert into outbox (type, short_url_id, url, user_id, created_at)
+		select $1::varchar, id, url, coalesce(user_id, ''), $2::timestamp from short_url where id = any ($3::varchar[]) order by array_position($3::varchar[], id);`,
+		string(eventType),
+		time.Now().UTC().Truncate(time.Microsecond),
+		ids,
+	)
+
 	return err
 }
 
-// GetStats return stats
-func (s *shortURLRepository) GetStats(ctx context.Context) (*core.ShortStats, error) {
-	rowURLCount := s.db.QueryRowContext(ctx, `select count(*) from short_url;`)
+// assignEventPositions assign positions of feed to committed events in order of id.
+// Serial id of outbox is taken before commit, so transaction committed later can have less id than event already read.
+// Position is assigned only to visible, that is committed, events and readers assign them one by one under advisory lock,
+// so event committed later always gets position greater than any position which was read
+func (s *shortURLRepository) assignEventPositions(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 
-	if rowURLCount.Err() != nil {
-		return nil, rowURLCount.Err()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	URLsCount := 0
+	if _, err := tx.ExecContext(ctx, `select pg_advisory_xact_lock($1);`, outboxLockKey); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update outbox set position = pending.position
+		from (
+			select id, (select coalesce(max(position), 0) from outbox) + row_number() over (order by id) as position
+			from outbox where position is null
+		) pending
+		where outbox.id = pending.id;`,
+	)
 
-	if err := rowURLCount.Scan(&URLsCount); err != nil {
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// EventsAfter события ленты после after, ID события — его позиция в ленте. Читаются с primary, реплика может отставать
+func (s *shortURLRepository) EventsAfter(ctx context.Context, after int64, limit int) ([]*core.Event, error) {
+	if err := s.assignEventPositions(ctx); err != nil {
 		return nil, err
 	}
 
-	rowUserCount := s.db.QueryRowContext(ctx, `select count(distinct(user_id)) from short_url;`)
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select position, type, short_url_id, url, user_id, created_at from outbox where position > $1 order by position limit $2`,
+		after, limit,
+	)
 
-	if rowUserCount.Err() != nil {
-		return nil, rowUserCount.Err()
+	if err != nil {
+		return nil, err
 	}
 
-	usersCount := 0
+	defer rows.Close()
+
+	var events []*core.Event
+
+	for rows.Next() {
+		event := core.Event{}
+
+		if err := rows.Scan(&event.ID, &event.Type, &event.ShortURLID, &event.URL, &event.UserID, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
 
-	if err := rowUserCount.Scan(&usersCount); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	shortStats := core.ShortStats{
-		URLs:  URLsCount,
-		Users: usersCount,
+	return events, nil
+}
+
+// GetStats return stats
+func (s *shortURLRepository) GetStats(ctx context.Context, statsRange core.StatsRange) (*core.ShortStats, error) {
+	var shortStats core.ShortStats
+	db := s.reader("")
+
+	err := db.QueryRowContext(
+		ctx,
+		`select count(*),
+			count(*) filter (where deleted),
+			count(distinct user_id) filter (where user_id <> ''),
+			count(distinct user_id) filter (where user_id <> '' and created_at >= $1 and created_at < $2),
+			pg_total_relation_size('short_url')
+		from short_url;`,
+		statsRange.From.UTC(), statsRange.To.UTC(),
+	).Scan(&shortStats.URLs, &shortStats.DeletedURLs, &shortStats.Users, &shortStats.ActiveUsers, &shortStats.StorageSize)
+
+	if err != nil {
+		return nil, err
 	}
 
+	rows, err := db.QueryContext(
+		ctx,
+		`select date_trunc('day', created_at) as day, count(*) from short_url
+		where created_at >= $1 and created_at < $2
+		group by day;`,
+		statsRange.From.UTC(), statsRange.To.UTC(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	days := map[time.Time]int{}
+
+	for rows.Next() {
+		var day time.Time
+		var count int
+
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+
+		days[day.UTC()] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	shortStats.CreatedPerDay = repositories.FillDays(days, statsRange)
+
 	return &shortStats, nil
 }
+
+// ScanAll обойти все ссылки с идентификатором больше afterID по порядку
+func (s *shortURLRepository) ScanAll(ctx context.Context, afterID string, fn func(shortURL *core.ShortURL) error) error {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where id > $1 order by id`,
+		afterID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		shortURL := core.ShortURL{}
+
+		if err := rows.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge); err != nil {
+			return err
+		}
+
+		if err := fn(&shortURL); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// nullTimeUTC value of nullable time in UTC for compare with stored values
+func nullTimeUTC(t sql.NullTime) interface{} {
+	if !t.Valid {
+		return nil
+	}
+
+	return t.Time.UTC()
+}
+
+// isIDUniqueViolation short url with same id already exists
+func isIDUniqueViolation(err error) bool {
+	var pgError *pgconn.PgError
+
+	return errors.As(err, &pgError) && pgError.Code == uniqueViolationCode && pgError.ConstraintName == "short_url_id_uindex"
+}
+
+func isURLUniqueViolation(err error) bool {
+	var pgError *pgconn.PgError
+
+	return errors.As(err, &pgError) && pgError.Code == uniqueViolationCode && pgError.ConstraintName == "short_url_uindex"
+}
@@ -0,0 +1,26 @@
+package storagedatabase
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_isUniqueViolation(t *testing.T) {
+	t.Run("should separate violation of url and id", func(t *testing.T) {
+		urlViolation := fmt.Errorf("update: %w", &pgconn.PgError{Code: uniqueViolationCode, ConstraintName: "short_url_uindex"})
+		idViolation := &pgconn.PgError{Code: uniqueViolationCode, ConstraintName: "short_url_id_uindex"}
+
+		assert.True(t, isURLUniqueViolation(urlViolation))
+		assert.False(t, isIDUniqueViolation(urlViolation))
+
+		assert.True(t, isIDUniqueViolation(idViolation))
+		assert.False(t, isURLUniqueViolation(idViolation))
+
+		assert.False(t, isURLUniqueViolation(errors.New("other")))
+		assert.False(t, isURLUniqueViolation(nil))
+	})
+}
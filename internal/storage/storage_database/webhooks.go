@@ -0,0 +1,179 @@
+package storagedatabase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	_ repositories.WebhookRepository = (*webhookRepository)(nil)
+)
+
+type webhookRepository struct {
+	log *zap.Logger
+	db  *sql.DB
+}
+
+// NewWebhookStore create sql store of webhooks
+func NewWebhookStore(log *zap.Logger, db *sql.DB) *webhookRepository {
+	return &webhookRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// AddWebhook Добавить подписку
+func (s *webhookRepository) AddWebhook(ctx context.Context, webhook *core.Webhook) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`insert into webhook (id, user_id, url, secret, events, failures, disabled, created_at) values ($1, $2, $3, $4, $5, $6, $7, $8);`,
+		webhook.ID,
+		webhook.UserID,
+		webhook.URL,
+		webhook.Secret,
+		joinEvents(webhook.Events),
+		webhook.Failures,
+		webhook.Disabled,
+		webhook.CreatedAt.UTC(),
+	)
+
+	return err
+}
+
+// ListWebhooks подписки пользователя
+func (s *webhookRepository) ListWebhooks(ctx context.Context, userID string) ([]*core.Webhook, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select id, user_id, url, secret, events, failures, disabled, created_at from webhook where user_id = $1 order by created_at, id`,
+		userID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var webhooks []*core.Webhook
+
+	for rows.Next() {
+		webhook := core.Webhook{}
+		var events string
+
+		if err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret, &events, &webhook.Failures, &webhook.Disabled, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		webhook.Events = splitEvents(events)
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook удалить подписку пользователя
+func (s *webhookRepository) DeleteWebhook(ctx context.Context, userID, id string) error {
+	result, err := s.db.ExecContext(ctx, `delete from webhook where id = $1 and user_id = $2;`, id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return core.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// MarkWebhookFailed еще одна неудачная доставка подряд
+func (s *webhookRepository) MarkWebhookFailed(ctx context.Context, id string, disableAfter int) (bool, error) {
+	var disabled bool
+
+	err := s.db.QueryRowContext(
+		ctx,
+		`update webhook set failures = failures + 1, disabled = failures + 1 >= $1 where id = $2 returning disabled;`,
+		disableAfter,
+		id,
+	).Scan(&disabled)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return disabled, err
+}
+
+// MarkWebhookDelivered сбросить счетчик неудачных доставок
+func (s *webhookRepository) MarkWebhookDelivered(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `update webhook set failures = 0 where id = $1 and failures > 0;`, id)
+
+	return err
+}
+
+// WebhookCursor курсор ленты событий, доставка которых завершена
+func (s *webhookRepository) WebhookCursor(ctx context.Context) (int64, error) {
+	var cursor int64
+
+	err := s.db.QueryRowContext(ctx, `select cursor from webhook_cursor where id = 1`).Scan(&cursor)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	return cursor, err
+}
+
+// SaveWebhookCursor сохранить курсор ленты событий
+func (s *webhookRepository) SaveWebhookCursor(ctx context.Context, cursor int64) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`insert into webhook_cursor (id, cursor) values (1, $1) on conflict (id) do update set cursor = excluded.cursor;`,
+		cursor,
+	)
+
+	return err
+}
+
+// joinEvents types of events in one column
+func joinEvents(events []core.EventType) string {
+	values := make([]string, len(events))
+
+	for i, event := range events {
+		values[i] = string(event)
+	}
+
+	return strings.Join(values, ",")
+}
+
+func splitEvents(value string) []core.EventType {
+	if value == "" {
+		return nil
+	}
+
+	values := strings.Split(value, ",")
+	events := make([]core.EventType, len(values))
+
+	for i, v := range values {
+		events[i] = core.EventType(v)
+	}
+
+	return events
+}
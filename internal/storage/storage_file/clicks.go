@@ -0,0 +1,175 @@
+package storagefile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+// recordTypeClick redirect event in log of clicks
+const recordTypeClick = "click"
+
+var (
+	_ repositories.ClickRepository = (*clickRepository)(nil)
+)
+
+// clickRepository append only log of clicks in separate file, clicks are kept in memory for read
+type clickRepository struct {
+	file   *os.File
+	clicks []*core.Click
+	mutex  *sync.RWMutex
+	log    *zap.Logger
+	size   int64
+}
+
+// NewClickStore open log of clicks and read it
+func NewClickStore(log *zap.Logger, path string) (*clickRepository, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &clickRepository{
+		file:  file,
+		mutex: &sync.RWMutex{},
+		log:   log,
+	}
+
+	if err := s.load(); err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("error load clicks %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// load read clicks from log. Torn tail after crash is truncated by same rule as log of short urls,
+// any other broken line is error and log is not changed
+func (s *clickRepository) load() error {
+	reader := bufio.NewReader(s.file)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			return nil
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			s.size += int64(len(line))
+			continue
+		}
+
+		var click *core.Click
+
+		if errors.Is(err, io.EOF) {
+			err = errUnterminatedRecord
+		} else {
+			click, err = decodeClick(line)
+		}
+
+		if err != nil {
+			if !isTornRecord(line, err) {
+				return fmt.Errorf("invalid click at offset %d: %w", s.size, err)
+			}
+
+			return truncateTail(s.log, s.file, reader, s.size, int64(len(line)), err)
+		}
+
+		s.clicks = append(s.clicks, click)
+		s.size += int64(len(line))
+	}
+}
+
+func decodeClick(line []byte) (*core.Click, error) {
+	r, err := decodeRecord(line)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Type != recordTypeClick {
+		return nil, fmt.Errorf("unknown record type %q", r.Type)
+	}
+
+	var click core.Click
+
+	if err := json.Unmarshal(r.Data, &click); err != nil {
+		return nil, err
+	}
+
+	return &click, nil
+}
+
+// AddClicks Добавить пачку кликов одной записью в журнал
+func (s *clickRepository) AddClicks(_ context.Context, clicks []*core.Click) error {
+	var lines bytes.Buffer
+
+	for _, click := range clicks {
+		line, err := encodeRecord(recordTypeClick, click)
+
+		if err != nil {
+			return err
+		}
+
+		lines.Write(line)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, err := s.file.Write(lines.Bytes())
+
+	if err != nil {
+		if n > 0 {
+			if errTruncate := s.file.Truncate(s.size); errTruncate != nil {
+				s.log.Error("error truncate partial write", zap.Error(errTruncate))
+			}
+		}
+
+		return err
+	}
+
+	s.size += int64(n)
+
+	for _, click := range clicks {
+		stored := *click
+		s.clicks = append(s.clicks, &stored)
+	}
+
+	return nil
+}
+
+// GetClickStats статистика кликов по короткой ссылке
+func (s *clickRepository) GetClickStats(_ context.Context, shortURLID string, from, to time.Time, top int) (*core.ClickStats, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return repositories.AggregateClicks(s.clicks, shortURLID, from, to, top), nil
+}
+
+// Close Метод для корректного закрытия store
+func (s *clickRepository) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
@@ -0,0 +1,154 @@
+package storagefile
+
+import (
+	"bufio"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	compactInterval = 5 * time.Minute
+)
+
+func (s *shortURLRepository) compactLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.compact(); err != nil {
+				s.log.Error("error compact file storage", zap.Error(err))
+			}
+		}
+	}
+}
+
+// compact write snapshot of indexes into new file and replace log by it
+func (s *shortURLRepository) compact() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.appended == 0 {
+		return nil
+	}
+
+	tmpPath := s.pathToFile + ".compact"
+
+	snapshot, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(snapshot)
+	size := int64(0)
+
+	// История изменений пишется до ссылок: при чтении снимка она только восстанавливает историю,
+	// а ссылки записаны уже с текущим url
+	for _, change := range s.index.allChanges() {
+		line, err := encodeRecord(recordTypeChange, change)
+
+		if err == nil {
+			_, err = writer.Write(line)
+		}
+
+		if err != nil {
+			snapshot.Close()
+			os.Remove(tmpPath)
+
+			return err
+		}
+
+		size += int64(len(line))
+	}
+
+	for _, shortURL := range s.index.all() {
+		line, err := encodeRecord(recordTypeShortURL, shortURL)
+
+		if err == nil {
+			_, err = writer.Write(line)
+		}
+
+		if err != nil {
+			snapshot.Close()
+			os.Remove(tmpPath)
+
+			return err
+		}
+
+		size += int64(len(line))
+	}
+
+	// События ленты переносятся с прежними ID, чтобы курсоры потребителей оставались валидными
+	for _, event := range s.index.events {
+		line, err := encodeRecord(recordTypeEvent, event)
+
+		if err == nil {
+			_, err = writer.Write(line)
+		}
+
+		if err != nil {
+			snapshot.Close()
+			os.Remove(tmpPath)
+
+			return err
+		}
+
+		size += int64(len(line))
+	}
+
+	if err := writer.Flush(); err != nil {
+		snapshot.Close()
+		os.Remove(tmpPath)
+
+		return err
+	}
+
+	if err := snapshot.Sync(); err != nil {
+		snapshot.Close()
+		os.Remove(tmpPath)
+
+		return err
+	}
+
+	if err := snapshot.Close(); err != nil {
+		os.Remove(tmpPath)
+
+		return err
+	}
+
+	if err := os.Rename(tmpPath, s.pathToFile); err != nil {
+		os.Remove(tmpPath)
+
+		return err
+	}
+
+	file, err := os.OpenFile(s.pathToFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.file.Close(); err != nil {
+		s.log.Error("error close old file storage", zap.Error(err))
+	}
+
+	s.log.Info(
+		"file storage compacted",
+		zap.Int("appended", s.appended),
+		zap.Int("shortURLs", s.index.len()),
+	)
+
+	s.file = file
+	s.size = size
+	s.appended = 0
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package storagefile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/repositories"
+	"github.com/shreyner/go-shortener/internal/repositories/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.ShortURLRepository {
+		s := newTestStore(t, filepath.Join(t.TempDir(), "store.json"))
+
+		t.Cleanup(func() {
+			s.Close()
+		})
+
+		return s
+	})
+}
+
+func TestClickConformance(t *testing.T) {
+	repotest.RunClicks(t, func(t *testing.T) repositories.ClickRepository {
+		s, err := NewClickStore(zap.NewNop(), filepath.Join(t.TempDir(), "store.json.clicks"))
+		require.NoError(t, err)
+
+		t.Cleanup(func() {
+			s.Close()
+		})
+
+		return s
+	})
+}
+
+func TestWebhookConformance(t *testing.T) {
+	repotest.RunWebhooks(t, func(t *testing.T) repositories.WebhookRepository {
+		s, err := NewWebhookStore(filepath.Join(t.TempDir(), "store.json.webhooks"))
+		require.NoError(t, err)
+
+		return s
+	})
+}
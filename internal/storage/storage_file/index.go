@@ -0,0 +1,216 @@
+package storagefile
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// shortURLIndex in-memory indexes over the records of the log
+type shortURLIndex struct {
+	ids    []string
+	byID   map[string]*core.ShortURL
+	byUser map[string][]string
+	byURL  map[string]string
+	// events last lifecycle events ordered by ID, no more eventsRetention
+	events          []*core.Event
+	eventsRetention int
+	// changes changes of url by short url, changesCount and lastChangeID over all short urls
+	changes      map[string][]*core.URLChange
+	changesCount int
+	lastChangeID int64
+}
+
+func newShortURLIndex() *shortURLIndex {
+	return &shortURLIndex{
+		byID:    map[string]*core.ShortURL{},
+		byUser:  map[string][]string{},
+		byURL:   map[string]string{},
+		changes: map[string][]*core.URLChange{},
+
+		eventsRetention: core.EventsRetention,
+	}
+}
+
+// put insert record or replace record with same ID
+func (idx *shortURLIndex) put(shortURL *core.ShortURL) {
+	if prev, ok := idx.byID[shortURL.ID]; ok {
+		if idx.byURL[prev.URL] == prev.ID {
+			delete(idx.byURL, prev.URL)
+		}
+
+		if prev.UserID != shortURL.UserID {
+			idx.removeUserID(prev)
+			idx.addUserID(shortURL)
+		}
+	} else {
+		idx.ids = append(idx.ids, shortURL.ID)
+		idx.addUserID(shortURL)
+	}
+
+	idx.byID[shortURL.ID] = shortURL
+
+	if !shortURL.IsDeleted {
+		idx.byURL[shortURL.URL] = shortURL.ID
+	}
+}
+
+func (idx *shortURLIndex) addUserID(shortURL *core.ShortURL) {
+	if !shortURL.UserID.Valid {
+		return
+	}
+
+	idx.byUser[shortURL.UserID.String] = append(idx.byUser[shortURL.UserID.String], shortURL.ID)
+}
+
+func (idx *shortURLIndex) removeUserID(shortURL *core.ShortURL) {
+	if !shortURL.UserID.Valid {
+		return
+	}
+
+	ids := idx.byUser[shortURL.UserID.String]
+
+	for i, id := range ids {
+		if id == shortURL.ID {
+			idx.byUser[shortURL.UserID.String] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+
+	if len(idx.byUser[shortURL.UserID.String]) == 0 {
+		delete(idx.byUser, shortURL.UserID.String)
+	}
+}
+
+// markDeleted mark record as deleted if it owned by user
+func (idx *shortURLIndex) markDeleted(id, userID string) {
+	shortURL, ok := idx.byID[id]
+
+	if !ok || !shortURL.UserID.Valid || shortURL.UserID.String != userID {
+		return
+	}
+
+	shortURL.IsDeleted = true
+}
+
+// markExpired mark record as deleted by expiration
+func (idx *shortURLIndex) markExpired(id string) {
+	if shortURL, ok := idx.byID[id]; ok {
+		shortURL.IsDeleted = true
+	}
+}
+
+func (idx *shortURLIndex) get(id string) (*core.ShortURL, bool) {
+	shortURL, ok := idx.byID[id]
+
+	return shortURL, ok
+}
+
+// activeIDByURL ID of active short url with url. Entry of deleted or expired short url is free
+func (idx *shortURLIndex) activeIDByURL(url string, now time.Time) (string, bool) {
+	id, ok := idx.byURL[url]
+
+	if !ok || !idx.byID[id].IsActive(now) {
+		return "", false
+	}
+
+	return id, true
+}
+
+func (idx *shortURLIndex) allByUser(userID string) []*core.ShortURL {
+	ids := idx.byUser[userID]
+	result := make([]*core.ShortURL, 0, len(ids))
+
+	for _, id := range ids {
+		result = append(result, idx.byID[id])
+	}
+
+	return result
+}
+
+// all return records in order of insertion
+func (idx *shortURLIndex) all() []*core.ShortURL {
+	result := make([]*core.ShortURL, 0, len(idx.ids))
+
+	for _, id := range idx.ids {
+		result = append(result, idx.byID[id])
+	}
+
+	return result
+}
+
+func (idx *shortURLIndex) len() int {
+	return len(idx.ids)
+}
+
+// addEvent add event to feed and return count of dropped old events, they become garbage of log
+func (idx *shortURLIndex) addEvent(event *core.Event) int {
+	idx.events = append(idx.events, event)
+
+	if len(idx.events) <= idx.eventsRetention {
+		return 0
+	}
+
+	idx.events[0] = nil
+	idx.events = idx.events[1:]
+
+	return 1
+}
+
+// nextEventID return ID for new event, IDs grow in order of records in log
+func (idx *shortURLIndex) nextEventID() int64 {
+	if len(idx.events) == 0 {
+		return 1
+	}
+
+	return idx.events[len(idx.events)-1].ID + 1
+}
+
+// eventsAfter return no more limit events with ID greater than after
+func (idx *shortURLIndex) eventsAfter(after int64, limit int) []*core.Event {
+	start := sort.Search(len(idx.events), func(i int) bool {
+		return idx.events[i].ID > after
+	})
+
+	end := start + limit
+
+	if end > len(idx.events) {
+		end = len(idx.events)
+	}
+
+	return idx.events[start:end]
+}
+
+// applyChange add change to history and set url of short url. Snapshot after compaction has changes
+// before short urls, then only history is restored and short urls are written with current url
+func (idx *shortURLIndex) applyChange(change *core.URLChange) {
+	idx.changes[change.ShortURLID] = append(idx.changes[change.ShortURLID], change)
+	idx.changesCount++
+
+	if change.ID > idx.lastChangeID {
+		idx.lastChangeID = change.ID
+	}
+
+	if shortURL, ok := idx.byID[change.ShortURLID]; ok {
+		updated := *shortURL
+		updated.URL = change.NewURL
+
+		idx.put(&updated)
+	}
+}
+
+// allChanges return changes of all short urls ordered by ID
+func (idx *shortURLIndex) allChanges() []*core.URLChange {
+	result := make([]*core.URLChange, 0, idx.changesCount)
+
+	for _, changes := range idx.changes {
+		result = append(result, changes...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result
+}
@@ -0,0 +1,103 @@
+package storagefile
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// tombstoneV0 tombstone record in log without envelope
+type tombstoneV0 struct {
+	ID     string `json:"tombstone"`
+	UserID string `json:"deletedBy"`
+}
+
+// replayLegacy read log in v0 format. Every line is core.ShortURL,
+// array of core.ShortURL written by CreateBatch or tombstone
+func (s *shortURLRepository) replayLegacy() (int, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	decoder := json.NewDecoder(s.file)
+	records := 0
+
+	for decoder.More() {
+		var raw json.RawMessage
+
+		if err := decoder.Decode(&raw); err != nil {
+			return records, err
+		}
+
+		if bytes.HasPrefix(raw, []byte("[")) {
+			var shortURLs []*core.ShortURL
+
+			if err := json.Unmarshal(raw, &shortURLs); err != nil {
+				return records, err
+			}
+
+			for _, shortURL := range shortURLs {
+				s.index.put(shortURL)
+				records++
+			}
+
+			continue
+		}
+
+		var deleted tombstoneV0
+
+		if err := json.Unmarshal(raw, &deleted); err != nil {
+			return records, err
+		}
+
+		records++
+
+		if deleted.ID != "" {
+			s.index.markDeleted(deleted.ID, deleted.UserID)
+
+			continue
+		}
+
+		var shortURL core.ShortURL
+
+		if err := json.Unmarshal(raw, &shortURL); err != nil {
+			return records, err
+		}
+
+		s.index.put(&shortURL)
+	}
+
+	return records, nil
+}
+
+// migrateLegacy rewrite log in current format. Old log will be saved near with suffix .v0
+func (s *shortURLRepository) migrateLegacy() error {
+	backupPath := s.pathToFile + ".v0"
+
+	if err := os.Rename(s.pathToFile, backupPath); err != nil {
+		return err
+	}
+
+	s.appended++
+
+	if err := s.compact(); err != nil {
+		if errRename := os.Rename(backupPath, s.pathToFile); errRename != nil {
+			s.log.Error("error restore legacy file storage", zap.Error(errRename))
+		}
+
+		return err
+	}
+
+	s.log.Info(
+		"file storage migrated to current format",
+		zap.Int("version", recordVersion),
+		zap.String("backup", backupPath),
+	)
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package storagefile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/shreyner/go-shortener/internal/core"
+)
+
+// recordVersion current version of record envelope in log
+const recordVersion = 1
+
+// Types of records in log
+const (
+	recordTypeShortURL  = "url"       // create or replace short url
+	recordTypeTombstone = "tombstone" // mark short url as deleted
+	recordTypeEvent     = "event"     // lifecycle event of short url for change feed
+	recordTypeChange    = "change"    // change of url of short url by owner
+)
+
+var (
+	crc32Table = crc32.MakeTable(crc32.Castagnoli)
+
+	errRecordChecksum = errors.New("record checksum mismatch")
+)
+
+// record envelope for every entry in log. One record is one line in file
+type record struct {
+	Version  int             `json:"v"`
+	Type     string          `json:"t"`
+	Data     json.RawMessage `json:"d"`
+	Checksum uint32          `json:"crc"`
+}
+
+// tombstone record in log which mark short url as deleted by user or by expiration
+type tombstone struct {
+	ID      string `json:"id"`
+	UserID  string `json:"userId,omitempty"`
+	Expired bool   `json:"expired,omitempty"`
+}
+
+// encodeRecord return line with envelope for data
+func encodeRecord(recordType string, data interface{}) ([]byte, error) {
+	rawData, err := json.Marshal(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := json.Marshal(&record{
+		Version:  recordVersion,
+		Type:     recordType,
+		Data:     rawData,
+		Checksum: crc32.Checksum(rawData, crc32Table),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(line, '\n'), nil
+}
+
+// decodeRecord parse line and check checksum
+func decodeRecord(line []byte) (*record, error) {
+	var r record
+
+	if err := json.Unmarshal(line, &r); err != nil {
+		return nil, err
+	}
+
+	if r.Version != recordVersion {
+		return nil, fmt.Errorf("unsupported record version %d", r.Version)
+	}
+
+	if crc32.Checksum(r.Data, crc32Table) != r.Checksum {
+		return nil, errRecordChecksum
+	}
+
+	return &r, nil
+}
+
+// apply record to index
+func (idx *shortURLIndex) apply(r *record) error {
+	switch r.Type {
+	case recordTypeShortURL:
+		var shortURL core.ShortURL
+
+		if err := json.Unmarshal(r.Data, &shortURL); err != nil {
+			return err
+		}
+
+		idx.put(&shortURL)
+	case recordTypeTombstone:
+		var deleted tombstone
+
+		if err := json.Unmarshal(r.Data, &deleted); err != nil {
+			return err
+		}
+
+		if deleted.Expired {
+			idx.markExpired(deleted.ID)
+		} else {
+			idx.markDeleted(deleted.ID, deleted.UserID)
+		}
+	case recordTypeEvent:
+		var event core.Event
+
+		if err := json.Unmarshal(r.Data, &event); err != nil {
+			return err
+		}
+
+		idx.addEvent(&event)
+	case recordTypeChange:
+		var change core.URLChange
+
+		if err := json.Unmarshal(r.Data, &change); err != nil {
+			return err
+		}
+
+		idx.applyChange(&change)
+	default:
+		return fmt.Errorf("unknown record type %q", r.Type)
+	}
+
+	return nil
+}
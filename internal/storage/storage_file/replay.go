@@ -0,0 +1,197 @@
+package storagefile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+var (
+	errUnterminatedRecord = errors.New("unterminated record")
+
+	// envelopePrefix begin of every line written by encodeRecord
+	envelopePrefix = []byte(fmt.Sprintf(`{"v":%d,`, recordVersion))
+)
+
+// replay read records from log into index.
+// Torn tail of log (unterminated record or record with wrong checksum) will be truncated,
+// any other line which is not record of current version is error, such log is not changed
+func (s *shortURLRepository) replay() (int, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(s.file)
+	records := 0
+	offset := int64(0)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			break
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return records, err
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			offset += int64(len(line))
+			continue
+		}
+
+		var r *record
+
+		if errors.Is(err, io.EOF) {
+			err = errUnterminatedRecord
+		} else {
+			r, err = decodeRecord(line)
+		}
+
+		if err != nil {
+			if !isTornRecord(line, err) {
+				return records, fmt.Errorf("invalid record at offset %d: %w", offset, err)
+			}
+
+			if err := truncateTail(s.log, s.file, reader, offset, int64(len(line)), err); err != nil {
+				return records, err
+			}
+
+			break
+		}
+
+		if err := s.index.apply(r); err != nil {
+			return records, fmt.Errorf("error apply record at offset %d: %w", offset, err)
+		}
+
+		records++
+		offset += int64(len(line))
+	}
+
+	s.size = offset
+
+	return records, nil
+}
+
+// truncateTail drop corrupt record and all after it from log of file storage or clicks.
+// If after corrupt record there are valid records or lines which are not torn records
+// then log was corrupted not only in tail and will be returned error
+func truncateTail(log *zap.Logger, file *os.File, reader *bufio.Reader, offset, corruptSize int64, reason error) error {
+	dropped := corruptSize
+	droppedRecords := 1
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		dropped += int64(len(line))
+
+		if len(bytes.TrimSpace(line)) != 0 {
+			droppedRecords++
+
+			decodeErr := errUnterminatedRecord
+
+			if err == nil {
+				_, decodeErr = decodeRecord(line)
+			}
+
+			if decodeErr == nil || !isTornRecord(line, decodeErr) {
+				return fmt.Errorf("corrupt record at offset %d followed by other records: %w", offset, reason)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Warn(
+		"file storage has corrupt tail, it will be truncated",
+		zap.String("file", file.Name()),
+		zap.Int64("offset", offset),
+		zap.Int64("droppedBytes", dropped),
+		zap.Int("droppedRecords", droppedRecords),
+		zap.Error(reason),
+	)
+
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// isTornRecord check decode error of line is result of interrupted write of record:
+// unterminated begin of record or full record with wrong checksum
+func isTornRecord(line []byte, decodeErr error) bool {
+	if errors.Is(decodeErr, errRecordChecksum) {
+		return true
+	}
+
+	if !errors.Is(decodeErr, errUnterminatedRecord) {
+		return false
+	}
+
+	if len(line) < len(envelopePrefix) {
+		return bytes.HasPrefix(envelopePrefix, line)
+	}
+
+	return bytes.HasPrefix(line, envelopePrefix)
+}
+
+// isLegacyLog check log was written before records had envelope (v0).
+// First record of v0 is array written by CreateBatch or object without key "v"
+func isLegacyLog(path string) (bool, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	var line []byte
+
+	for len(bytes.TrimSpace(line)) == 0 {
+		line, err = reader.ReadBytes('\n')
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, err
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+
+	line = bytes.TrimSpace(line)
+
+	if len(line) == 0 {
+		return false, nil
+	}
+
+	if line[0] == '[' {
+		return true, nil
+	}
+
+	var probe map[string]json.RawMessage
+
+	// Первая строка, которая не разбирается, не считается старым форматом, ее обработает replay
+	if json.Unmarshal(line, &probe) != nil {
+		return false, nil
+	}
+
+	_, hasVersion := probe["v"]
+
+	return !hasVersion, nil
+}
@@ -1,31 +1,50 @@
+// Package storagefile хранилище в файле. Файл является журналом записей,
+// при старте журнал проигрывается в индексы в памяти, из которых обслуживается чтение.
 package storagefile
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"fmt"
 	"os"
+	"sort"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/shreyner/go-shortener/internal/core"
 	"github.com/shreyner/go-shortener/internal/repositories"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
 )
 
 var (
 	_ repositories.ShortURLRepository = (*shortURLRepository)(nil)
+	_ repositories.ShortURLScanner    = (*shortURLRepository)(nil)
+	_ repositories.EventRepository    = (*shortURLRepository)(nil)
 )
 
 type shortURLRepository struct {
-	decoder    *json.Decoder
-	encoder    *json.Encoder
 	file       *os.File
+	index      *shortURLIndex
 	mutex      *sync.RWMutex
 	log        *zap.Logger
 	pathToFile string
+
+	// size of valid part of log
+	size int64
+	// appended count of records written to log after last compaction
+	appended int
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	// closeOnce closeErr result of first Close, repeated Close returns it
+	closeOnce sync.Once
+	closeErr  error
 }
 
-// NewShortURLStore create file store
+// NewShortURLStore create file store and replay log into indexes.
+// Log in old format (v0) will be migrated to current format
 func NewShortURLStore(log *zap.Logger, fileStoragePath string) (*shortURLRepository, error) {
 	file, err := os.OpenFile(fileStoragePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
 
@@ -33,108 +52,570 @@ func NewShortURLStore(log *zap.Logger, fileStoragePath string) (*shortURLReposit
 		return nil, err
 	}
 
-	return &shortURLRepository{
+	s := &shortURLRepository{
 		log:        log,
 		pathToFile: fileStoragePath,
 		mutex:      &sync.RWMutex{},
 		file:       file,
-		decoder:    json.NewDecoder(file),
-		encoder:    json.NewEncoder(file),
-	}, nil
+		index:      newShortURLIndex(),
+		done:       make(chan struct{}),
+	}
+
+	if err := s.load(); err != nil {
+		s.file.Close()
+
+		return nil, fmt.Errorf("error load file %s: %w", fileStoragePath, err)
+	}
+
+	s.wg.Add(1)
+	go s.compactLoop()
+
+	return s, nil
+}
+
+func (s *shortURLRepository) load() error {
+	legacy, err := isLegacyLog(s.pathToFile)
+
+	if err != nil {
+		return err
+	}
+
+	if legacy {
+		records, err := s.replayLegacy()
+
+		if err != nil {
+			return err
+		}
+
+		s.log.Info("file storage loaded from legacy format", zap.Int("records", records))
+
+		return s.migrateLegacy()
+	}
+
+	records, err := s.replay()
+
+	if err != nil {
+		return err
+	}
+
+	s.appended = records - s.index.len() - len(s.index.events) - s.index.changesCount
+
+	s.log.Info(
+		"file storage loaded",
+		zap.Int("records", records),
+		zap.Int("shortURLs", s.index.len()),
+	)
+
+	return nil
+}
+
+// write append lines into log by one write. Partial write will be truncated
+func (s *shortURLRepository) write(lines []byte, records int) error {
+	n, err := s.file.Write(lines)
+
+	if err != nil {
+		if n > 0 {
+			if errTruncate := s.file.Truncate(s.size); errTruncate != nil {
+				s.log.Error("error truncate partial write", zap.Error(errTruncate))
+			}
+		}
+
+		return err
+	}
+
+	s.size += int64(n)
+	s.appended += records
+
+	return nil
+}
+
+// encodeEvents return lines of events for short urls. Events get IDs after last event in index
+func (s *shortURLRepository) encodeEvents(eventType core.EventType, shortURLs []*core.ShortURL) ([]byte, []*core.Event, error) {
+	return encodeEventsFrom(s.index.nextEventID(), eventType, shortURLs)
+}
+
+// encodeEventsFrom return lines of events for short urls with IDs starting from nextID
+func encodeEventsFrom(nextID int64, eventType core.EventType, shortURLs []*core.ShortURL) ([]byte, []*core.Event, error) {
+	var lines bytes.Buffer
+
+	now := time.Now()
+	events := make([]*core.Event, 0, len(shortURLs))
+
+	for _, shortURL := range shortURLs {
+		event := core.NewEvent(eventType, shortURL, now)
+		event.ID = nextID
+		nextID++
+
+		line, err := encodeRecord(recordTypeEvent, event)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lines.Write(line)
+		events = append(events, event)
+	}
+
+	return lines.Bytes(), events, nil
 }
 
-// Add Добавить короткую ссылку в store
+// Add Добавить короткую ссылку в store. Ссылка и событие пишутся в журнал одной записью
 func (s *shortURLRepository) Add(_ context.Context, shortURL *core.ShortURL) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	return s.encoder.Encode(&shortURL)
+
+	if id, ok := s.index.activeIDByURL(shortURL.URL, time.Now()); ok {
+		return storeerrors.NewShortURLCreateConflictError(id)
+	}
+
+	if _, ok := s.index.get(shortURL.ID); ok {
+		return storeerrors.NewShortURLIDConflictError(shortURL.ID)
+	}
+
+	line, err := encodeRecord(recordTypeShortURL, shortURL)
+
+	if err != nil {
+		return err
+	}
+
+	eventLines, events, err := s.encodeEvents(core.EventCreated, []*core.ShortURL{shortURL})
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(append(line, eventLines...), 1+len(events)); err != nil {
+		return err
+	}
+
+	s.index.put(copyShortURL(shortURL))
+	s.appended += s.index.addEvent(events[0])
+
+	return nil
 }
 
 // GetByID Получить короткую ссылку по идентификатору
-func (s *shortURLRepository) GetByID(ctx context.Context, id string) (*core.ShortURL, bool) {
+func (s *shortURLRepository) GetByID(_ context.Context, id string) (*core.ShortURL, bool) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	file, err := os.OpenFile(s.pathToFile, os.O_RDONLY|os.O_CREATE, 0644)
+	shortURL, ok := s.index.get(id)
 
-	if err != nil {
-		s.log.Error("error open file for read", zap.Error(err))
+	if !ok {
 		return nil, false
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
+	return copyShortURL(shortURL), true
+}
 
-	for decoder.More() {
-		var shortURL core.ShortURL
-		err := decoder.Decode(&shortURL)
+// AllByUserID получить все ссылки по идентификатору пользователя
+func (s *shortURLRepository) AllByUserID(_ context.Context, id string) ([]*core.ShortURL, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*core.ShortURL
+
+	for _, shortURL := range s.index.allByUser(id) {
+		result = append(result, copyShortURL(shortURL))
+	}
+
+	return result, nil
+}
+
+// ListByUserID получить страницу ссылок пользователя
+func (s *shortURLRepository) ListByUserID(_ context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error) {
+	s.mutex.RLock()
+
+	shortURLs := make([]*core.ShortURL, 0, len(s.index.byUser[id]))
+
+	for _, shortURL := range s.index.allByUser(id) {
+		shortURLs = append(shortURLs, copyShortURL(shortURL))
+	}
+
+	s.mutex.RUnlock()
+
+	return repositories.PageShortURLs(shortURLs, opts)
+}
+
+// CreateBatch Добавление ссылок пачкой
+func (s *shortURLRepository) CreateBatch(_ context.Context, shortURLs *[]*core.ShortURL) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	batchURLs := make(map[string]string, len(*shortURLs))
+	batchIDs := make(map[string]struct{}, len(*shortURLs))
+	now := time.Now()
+
+	var deleted []*core.ShortURL
+
+	for _, v := range *shortURLs {
+		if _, ok := s.index.get(v.ID); ok {
+			return storeerrors.NewShortURLIDConflictError(v.ID)
+		}
+
+		if _, ok := batchIDs[v.ID]; ok {
+			return storeerrors.NewShortURLIDConflictError(v.ID)
+		}
+
+		batchIDs[v.ID] = struct{}{}
+
+		if v.IsDeleted {
+			deleted = append(deleted, v)
+			continue
+		}
+
+		if id, ok := s.index.activeIDByURL(v.URL, now); ok {
+			return storeerrors.NewShortURLCreateConflictError(id)
+		}
+
+		if id, ok := batchURLs[v.URL]; ok {
+			return storeerrors.NewShortURLCreateConflictError(id)
+		}
+
+		batchURLs[v.URL] = v.ID
+	}
+
+	var lines bytes.Buffer
+
+	for _, v := range *shortURLs {
+		line, err := encodeRecord(recordTypeShortURL, v)
 
 		if err != nil {
-			s.log.Error("error read shorted json", zap.Error(err))
-			return nil, false
+			return err
 		}
 
-		if shortURL.ID == id {
-			return &shortURL, true
+		lines.Write(line)
+	}
+
+	eventLines, events, err := s.encodeEvents(core.EventCreated, *shortURLs)
+
+	if err != nil {
+		return err
+	}
+
+	lines.Write(eventLines)
+
+	// Удалённые ссылки переносятся вместе с событием deleted, оно идёт после всех событий created пачки
+	deletedLines, deletedEvents, err := encodeEventsFrom(s.index.nextEventID()+int64(len(events)), core.EventDeleted, deleted)
+
+	if err != nil {
+		return err
+	}
+
+	lines.Write(deletedLines)
+	events = append(events, deletedEvents...)
+
+	if err := s.write(lines.Bytes(), len(*shortURLs)+len(events)); err != nil {
+		return err
+	}
+
+	for _, v := range *shortURLs {
+		s.index.put(copyShortURL(v))
+	}
+
+	for _, event := range events {
+		s.appended += s.index.addEvent(event)
+	}
+
+	return nil
+}
+
+// Close Метод для корректного закрытия store. Повторный вызов возвращает ошибку первого
+func (s *shortURLRepository) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		s.closeErr = s.file.Close()
+	})
+
+	return s.closeErr
+}
+
+// DeleteURLsUserByIds Удаление пачкой коротких ссылок от имени пользователя.
+// На каждую удаленную ссылку в журнал пишется tombstone запись вместе с событием
+func (s *shortURLRepository) DeleteURLsUserByIds(_ context.Context, userID string, ids []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, id := range ids {
+		shortURL, ok := s.index.get(id)
+
+		if !ok || shortURL.IsDeleted || !shortURL.UserID.Valid || shortURL.UserID.String != userID {
+			continue
+		}
+
+		line, err := encodeRecord(recordTypeTombstone, &tombstone{ID: id, UserID: userID})
+
+		if err != nil {
+			return err
+		}
+
+		eventLines, events, err := s.encodeEvents(core.EventDeleted, []*core.ShortURL{shortURL})
+
+		if err != nil {
+			return err
+		}
+
+		if err := s.write(append(line, eventLines...), 1+len(events)); err != nil {
+			return err
 		}
+
+		s.index.markDeleted(id, userID)
+		s.appended += s.index.addEvent(events[0])
 	}
 
-	return nil, false
+	return nil
 }
 
-// AllByUserID получить все ссылки по идентификатору пользователя
-func (s *shortURLRepository) AllByUserID(_ context.Context, id string) ([]*core.ShortURL, error) {
+// DeleteExpired Пометить удаленными ссылки с истекшим сроком жизни. Пишет одной записью все tombstone
+func (s *shortURLRepository) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var lines bytes.Buffer
+	var ids []string
+	var expired []*core.ShortURL
+
+	for _, shortURL := range s.index.all() {
+		if shortURL.IsDeleted || !shortURL.IsExpired(now) {
+			continue
+		}
+
+		line, err := encodeRecord(recordTypeTombstone, &tombstone{ID: shortURL.ID, Expired: true})
+
+		if err != nil {
+			return 0, err
+		}
+
+		lines.Write(line)
+		ids = append(ids, shortURL.ID)
+		expired = append(expired, shortURL)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	eventLines, events, err := s.encodeEvents(core.EventExpired, expired)
+
+	if err != nil {
+		return 0, err
+	}
+
+	lines.Write(eventLines)
+
+	if err := s.write(lines.Bytes(), len(ids)+len(events)); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		s.index.markExpired(id)
+	}
+
+	for _, event := range events {
+		s.appended += s.index.addEvent(event)
+	}
+
+	return len(ids), nil
+}
+
+// GetStats return stats
+func (s *shortURLRepository) GetStats(_ context.Context, statsRange core.StatsRange) (*core.ShortStats, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	file, err := os.OpenFile(s.pathToFile, os.O_RDONLY|os.O_CREATE, 0644)
+	shortStats := repositories.AggregateShortURLs(s.index.all(), statsRange)
+	shortStats.StorageSize = s.size
 
-	if err != nil {
-		s.log.Error("error open file for read", zap.Error(err))
+	return shortStats, nil
+}
+
+// UpdateURL Изменить url короткой ссылки владельцем. Изменение и событие пишутся в журнал одной записью
+func (s *shortURLRepository) UpdateURL(_ context.Context, change *core.URLChange, redirect core.RedirectUpdate) (*core.ShortURL, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	shortURL, _ := s.index.get(change.ShortURLID)
+
+	if err := repositories.CheckURLChange(shortURL, change.UserID); err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
+	change.OldURL = shortURL.URL
 
-	var result []*core.ShortURL
+	updated := copyShortURL(shortURL)
+	redirect.ApplyTo(updated)
 
-	for decoder.More() {
-		var shortURL core.ShortURL
-		err := decoder.Decode(&shortURL)
+	if shortURL.URL == change.NewURL {
+		if redirect.IsEmpty() {
+			return updated, nil
+		}
 
-		if err != nil {
-			s.log.Error("error read shorted json", zap.Error(err))
+		if err := s.writeShortURL(updated); err != nil {
 			return nil, err
 		}
 
-		if shortURL.UserID.Valid && shortURL.UserID.String == id {
-			result = append(result, &shortURL)
+		return updated, nil
+	}
+
+	if id, ok := s.index.activeIDByURL(change.NewURL, time.Now()); ok {
+		return nil, storeerrors.NewShortURLCreateConflictError(id)
+	}
+
+	recorded := *change
+	recorded.ID = s.index.lastChangeID + 1
+
+	lines, err := encodeRecord(recordTypeChange, &recorded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated.URL = change.NewURL
+	records := 1
+
+	// Параметры редиректа пишутся записью url после изменения в той же записи журнала
+	if !redirect.IsEmpty() {
+		line, err := encodeRecord(recordTypeShortURL, updated)
+
+		if err != nil {
+			return nil, err
 		}
+
+		lines = append(lines, line...)
+		records++
 	}
 
-	return result, nil
+	eventLines, events, err := s.encodeEvents(core.EventUpdated, []*core.ShortURL{updated})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.write(append(lines, eventLines...), records+len(events)); err != nil {
+		return nil, err
+	}
+
+	s.index.applyChange(&recorded)
+
+	if !redirect.IsEmpty() {
+		s.index.put(copyShortURL(updated))
+	}
+
+	s.appended += s.index.addEvent(events[0])
+
+	change.ID = recorded.ID
+
+	return updated, nil
 }
 
-// CreateBatch Добавление ссылок пачкой
-func (s *shortURLRepository) CreateBatch(_ context.Context, shortURLs *[]*core.ShortURL) error {
+// SetRedirect Изменить тип редиректа и max-age короткой ссылки владельцем. Пишет ссылку целиком записью url
+func (s *shortURLRepository) SetRedirect(_ context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	return s.encoder.Encode(shortURLs)
+	shortURL, _ := s.index.get(id)
+
+	if err := repositories.CheckURLChange(shortURL, userID); err != nil {
+		return nil, err
+	}
+
+	updated := copyShortURL(shortURL)
+	update.ApplyTo(updated)
+
+	if err := s.writeShortURL(updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
 }
 
-// Close Метод для корректного закрытия store
-func (s *shortURLRepository) Close() error {
-	return s.file.Close()
+// writeShortURL write short url whole by record url and replace it in index, called under lock
+func (s *shortURLRepository) writeShortURL(shortURL *core.ShortURL) error {
+	line, err := encodeRecord(recordTypeShortURL, shortURL)
+
+	if err != nil {
+		return err
+	}
+
+	if err := s.write(line, 1); err != nil {
+		return err
+	}
+
+	s.index.put(copyShortURL(shortURL))
+
+	return nil
 }
 
-// DeleteURLsUserByIds Удаление пачкой коротких ссылок от имени пользователя
-func (s *shortURLRepository) DeleteURLsUserByIds(_ context.Context, userID string, ids []string) error {
+// URLChanges изменения url короткой ссылки по порядку
+func (s *shortURLRepository) URLChanges(_ context.Context, shortURLID string) ([]*core.URLChange, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	changes := s.index.changes[shortURLID]
+	result := make([]*core.URLChange, len(changes))
+
+	for i, change := range changes {
+		copied := *change
+		result[i] = &copied
+	}
+
+	return result, nil
+}
+
+// EventsAfter события ленты после after
+func (s *shortURLRepository) EventsAfter(_ context.Context, after int64, limit int) ([]*core.Event, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	events := s.index.eventsAfter(after, limit)
+	result := make([]*core.Event, len(events))
+
+	for i, event := range events {
+		copied := *event
+		result[i] = &copied
+	}
+
+	return result, nil
+}
+
+// ScanAll обойти все ссылки с идентификатором больше afterID по порядку
+func (s *shortURLRepository) ScanAll(ctx context.Context, afterID string, fn func(shortURL *core.ShortURL) error) error {
+	s.mutex.RLock()
+
+	shortURLs := make([]*core.ShortURL, 0, s.index.len())
+
+	for _, shortURL := range s.index.all() {
+		if shortURL.ID > afterID {
+			shortURLs = append(shortURLs, copyShortURL(shortURL))
+		}
+	}
+
+	s.mutex.RUnlock()
+
+	sort.Slice(shortURLs, func(i, j int) bool {
+		return shortURLs[i].ID < shortURLs[j].ID
+	})
+
+	for _, shortURL := range shortURLs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(shortURL); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// GetStats return stats
-func (s *shortURLRepository) GetStats(_ context.Context) (*core.ShortStats, error) {
-	return &core.ShortStats{}, nil
+func copyShortURL(shortURL *core.ShortURL) *core.ShortURL {
+	result := *shortURL
+
+	return &result
 }
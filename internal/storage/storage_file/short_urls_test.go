@@ -0,0 +1,570 @@
+package storagefile
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
+)
+
+func newTestStore(t *testing.T, path string) *shortURLRepository {
+	s, err := NewShortURLStore(zap.NewNop(), path)
+	require.NoError(t, err)
+
+	return s
+}
+
+func newShortURL(id, url, userID string) *core.ShortURL {
+	return &core.ShortURL{
+		ID:  id,
+		URL: url,
+		UserID: sql.NullString{
+			String: userID,
+			Valid:  userID != "",
+		},
+	}
+}
+
+func Test_shortURLRepository_Add(t *testing.T) {
+	t.Run("should success add and get by id", func(t *testing.T) {
+		s := newTestStore(t, filepath.Join(t.TempDir(), "store.json"))
+		defer s.Close()
+
+		require.NoError(t, s.Add(context.Background(), newShortURL("1", "https://vk.com", "1")))
+
+		got, ok := s.GetByID(context.Background(), "1")
+
+		require.True(t, ok)
+		assert.Equal(t, "https://vk.com", got.URL)
+
+		_, ok = s.GetByID(context.Background(), "2")
+		assert.False(t, ok)
+	})
+
+	t.Run("should return conflict with origin id", func(t *testing.T) {
+		s := newTestStore(t, filepath.Join(t.TempDir(), "store.json"))
+		defer s.Close()
+
+		require.NoError(t, s.Add(context.Background(), newShortURL("1", "https://vk.com", "1")))
+
+		err := s.Add(context.Background(), newShortURL("2", "https://vk.com", "2"))
+
+		var conflictError *storeerrors.ShortURLCreateConflictError
+		require.True(t, errors.As(err, &conflictError))
+		assert.Equal(t, "1", conflictError.OriginID)
+	})
+}
+
+func Test_shortURLRepository_Close(t *testing.T) {
+	t.Run("should close twice without panic", func(t *testing.T) {
+		s := newTestStore(t, filepath.Join(t.TempDir(), "store.json"))
+
+		require.NoError(t, s.Close())
+		assert.NotPanics(t, func() {
+			assert.NoError(t, s.Close())
+		})
+	})
+}
+
+func Test_shortURLRepository_Replay(t *testing.T) {
+	t.Run("should restore indexes after reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.Add(context.Background(), newShortURL("1", "https://vk.com", "1")))
+		require.NoError(t, s.CreateBatch(context.Background(), &[]*core.ShortURL{
+			newShortURL("2", "https://vk.com/2", "1"),
+			newShortURL("3", "https://vk.com/3", "3"),
+		}))
+		require.NoError(t, s.Close())
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		got, err := s.AllByUserID(context.Background(), "1")
+		require.NoError(t, err)
+		require.Equal(t, 2, len(got))
+		assert.Equal(t, "1", got[0].ID)
+		assert.Equal(t, "2", got[1].ID)
+
+		got, err = s.AllByUserID(context.Background(), "5")
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(got))
+	})
+}
+
+func Test_shortURLRepository_compact(t *testing.T) {
+	t.Run("should rewrite log with same state", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.CreateBatch(context.Background(), &[]*core.ShortURL{
+			newShortURL("1", "https://vk.com/1", "1"),
+			newShortURL("2", "https://vk.com/2", "1"),
+		}))
+		require.NoError(t, s.compact())
+		require.NoError(t, s.Add(context.Background(), newShortURL("3", "https://vk.com/3", "1")))
+		require.NoError(t, s.Close())
+
+		_, err := os.Stat(path + ".compact")
+		assert.True(t, os.IsNotExist(err))
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		got, err := s.AllByUserID(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, 3, len(got))
+		assert.Equal(t, 0, s.appended)
+	})
+}
+
+func Test_shortURLRepository_EventsAfter(t *testing.T) {
+	t.Run("should keep events with same IDs after compact and reopen", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.Add(ctx, newShortURL("1", "https://vk.com/1", "1")))
+		require.NoError(t, s.Add(ctx, newShortURL("2", "https://vk.com/2", "1")))
+		require.NoError(t, s.DeleteURLsUserByIds(ctx, "1", []string{"1"}))
+		require.NoError(t, s.compact())
+		require.NoError(t, s.Close())
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		require.NoError(t, s.Add(ctx, newShortURL("3", "https://vk.com/3", "1")))
+
+		got, err := s.EventsAfter(ctx, 1, 10)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(got))
+		assert.Equal(t, int64(2), got[0].ID)
+		assert.Equal(t, core.EventDeleted, got[1].Type)
+		assert.Equal(t, "1", got[1].ShortURLID)
+		assert.Equal(t, int64(4), got[2].ID)
+		assert.Equal(t, "3", got[2].ShortURLID)
+	})
+
+	t.Run("should keep last events and continue IDs after compact and reopen", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		s := newTestStore(t, path)
+		s.index.eventsRetention = 2
+		require.NoError(t, s.Add(ctx, newShortURL("1", "https://vk.com/1", "1")))
+		require.NoError(t, s.Add(ctx, newShortURL("2", "https://vk.com/2", "1")))
+		require.NoError(t, s.Add(ctx, newShortURL("3", "https://vk.com/3", "1")))
+
+		got, err := s.EventsAfter(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(got))
+		assert.Equal(t, int64(2), got[0].ID)
+
+		require.NoError(t, s.compact())
+		require.NoError(t, s.Close())
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		require.NoError(t, s.Add(ctx, newShortURL("4", "https://vk.com/4", "1")))
+
+		got, err = s.EventsAfter(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(got))
+		assert.Equal(t, int64(2), got[0].ID)
+		assert.Equal(t, int64(4), got[2].ID)
+		assert.Equal(t, "4", got[2].ShortURLID)
+	})
+}
+
+func Test_shortURLRepository_UpdateURL(t *testing.T) {
+	t.Run("should keep url and changes after reopen and compact", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.Add(ctx, newShortURL("1", "https://vk.com/a", "1")))
+
+		_, err := s.UpdateURL(ctx, &core.URLChange{ShortURLID: "1", UserID: "1", NewURL: "https://vk.com/b", ChangedAt: time.Now()}, core.RedirectUpdate{})
+		require.NoError(t, err)
+
+		// Освобожденный url занимает другая ссылка
+		require.NoError(t, s.Add(ctx, newShortURL("2", "https://vk.com/a", "1")))
+		require.NoError(t, s.Close())
+
+		check := func(s *shortURLRepository) {
+			got, ok := s.GetByID(ctx, "1")
+			require.True(t, ok)
+			assert.Equal(t, "https://vk.com/b", got.URL)
+
+			got, ok = s.GetByID(ctx, "2")
+			require.True(t, ok)
+			assert.Equal(t, "https://vk.com/a", got.URL)
+
+			changes, err := s.URLChanges(ctx, "1")
+			require.NoError(t, err)
+			require.Equal(t, 1, len(changes))
+			assert.Equal(t, int64(1), changes[0].ID)
+			assert.Equal(t, "https://vk.com/a", changes[0].OldURL)
+
+			var conflictError *storeerrors.ShortURLCreateConflictError
+
+			err = s.Add(ctx, newShortURL("3", "https://vk.com/b", "1"))
+			require.True(t, errors.As(err, &conflictError))
+			assert.Equal(t, "1", conflictError.OriginID)
+		}
+
+		s = newTestStore(t, path)
+		check(s)
+		require.NoError(t, s.compact())
+		require.NoError(t, s.Close())
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		check(s)
+		assert.Equal(t, 0, s.appended)
+
+		change := &core.URLChange{ShortURLID: "1", UserID: "1", NewURL: "https://vk.com/c", ChangedAt: time.Now()}
+		_, err = s.UpdateURL(ctx, change, core.RedirectUpdate{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), change.ID)
+	})
+}
+
+func Test_shortURLRepository_DeleteURLsUserByIds(t *testing.T) {
+	t.Run("should delete only own urls and keep it after reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.CreateBatch(context.Background(), &[]*core.ShortURL{
+			newShortURL("1", "https://vk.com/1", "1"),
+			newShortURL("2", "https://vk.com/2", "1"),
+			newShortURL("3", "https://vk.com/3", "3"),
+		}))
+		require.NoError(t, s.DeleteURLsUserByIds(context.Background(), "1", []string{"1", "3", "4"}))
+		require.NoError(t, s.Close())
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		for id, isDeleted := range map[string]bool{"1": true, "2": false, "3": false} {
+			got, ok := s.GetByID(context.Background(), id)
+
+			require.True(t, ok)
+			assert.Equal(t, isDeleted, got.IsDeleted, "id %s", id)
+		}
+	})
+}
+
+func Test_shortURLRepository_DeleteExpired(t *testing.T) {
+	t.Run("should keep expired urls deleted after reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+		now := time.Now().UTC()
+
+		expired := newShortURL("1", "https://vk.com/1", "")
+		expired.ExpiresAt = sql.NullTime{Time: now.Add(-time.Minute), Valid: true}
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.CreateBatch(context.Background(), &[]*core.ShortURL{
+			expired,
+			newShortURL("2", "https://vk.com/2", "1"),
+		}))
+
+		count, err := s.DeleteExpired(context.Background(), now)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		require.NoError(t, s.Close())
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		got, ok := s.GetByID(context.Background(), "1")
+		require.True(t, ok)
+		assert.True(t, got.IsDeleted)
+
+		got, ok = s.GetByID(context.Background(), "2")
+		require.True(t, ok)
+		assert.False(t, got.IsDeleted)
+	})
+}
+
+func Test_shortURLRepository_GetStats(t *testing.T) {
+	t.Run("should count urls and distinct users", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.CreateBatch(context.Background(), &[]*core.ShortURL{
+			newShortURL("1", "https://vk.com/1", "1"),
+			newShortURL("2", "https://vk.com/2", "1"),
+			newShortURL("3", "https://vk.com/3", "3"),
+			newShortURL("4", "https://vk.com/4", ""),
+		}))
+		require.NoError(t, s.DeleteURLsUserByIds(context.Background(), "3", []string{"3"}))
+
+		got, err := s.GetStats(context.Background(), core.StatsRange{})
+		require.NoError(t, err)
+		assert.Equal(t, 4, got.URLs)
+		assert.Equal(t, 2, got.Users)
+		assert.Equal(t, 1, got.DeletedURLs)
+
+		require.NoError(t, s.compact())
+		require.NoError(t, s.Close())
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		got, err = s.GetStats(context.Background(), core.StatsRange{})
+		require.NoError(t, err)
+		assert.Equal(t, 4, got.URLs)
+		assert.Equal(t, 2, got.Users)
+		assert.Equal(t, 1, got.DeletedURLs)
+	})
+}
+
+func Test_shortURLRepository_Recovery(t *testing.T) {
+	t.Run("should truncate torn tail", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.Add(context.Background(), newShortURL("1", "https://vk.com/1", "1")))
+		require.NoError(t, s.Close())
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"v":1,"t":"url","d":{"id":"2","u`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		s = newTestStore(t, path)
+
+		_, ok := s.GetByID(context.Background(), "1")
+		assert.True(t, ok)
+
+		require.NoError(t, s.Add(context.Background(), newShortURL("2", "https://vk.com/2", "1")))
+		require.NoError(t, s.Close())
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		got, err := s.AllByUserID(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, 2, len(got))
+		assert.Greater(t, s.size, info.Size())
+	})
+
+	t.Run("should fail when corrupt record is not in tail", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		first, err := encodeRecord(recordTypeShortURL, newShortURL("1", "https://vk.com/1", "1"))
+		require.NoError(t, err)
+		second, err := encodeRecord(recordTypeShortURL, newShortURL("2", "https://vk.com/2", "1"))
+		require.NoError(t, err)
+
+		corrupt := bytes.Replace(first, []byte("vk.com"), []byte("ya.com"), 1)
+
+		require.NoError(t, os.WriteFile(path, append(corrupt, second...), 0644))
+
+		_, err = NewShortURLStore(zap.NewNop(), path)
+		assert.ErrorIs(t, err, errRecordChecksum)
+	})
+
+	t.Run("should fail and keep log with line which is not record", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		first, err := encodeRecord(recordTypeShortURL, newShortURL("1", "https://vk.com/1", "1"))
+		require.NoError(t, err)
+
+		content := append(first, []byte(`{"id":"2","url":"https://vk.com/2","isDeleted":false}`+"\n")...)
+
+		require.NoError(t, os.WriteFile(path, content, 0644))
+
+		_, err = NewShortURLStore(zap.NewNop(), path)
+		assert.Error(t, err)
+
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(content), string(got))
+	})
+}
+
+func Test_shortURLRepository_MigrateLegacy(t *testing.T) {
+	t.Run("should migrate v0 file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		legacy := `{"id":"1","url":"https://vk.com/1","userId":{"String":"1","Valid":true},"isDeleted":false}
+[{"id":"2","url":"https://vk.com/2","userId":{"String":"1","Valid":true},"isDeleted":false},{"id":"3","url":"https://vk.com/3","userId":{"String":"3","Valid":true},"isDeleted":false}]
+{"tombstone":"2","deletedBy":"1"}
+`
+		require.NoError(t, os.WriteFile(path, []byte(legacy), 0644))
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.Close())
+
+		backup, err := os.ReadFile(path + ".v0")
+		require.NoError(t, err)
+		assert.Equal(t, legacy, string(backup))
+
+		legacyFormat, err := isLegacyLog(path)
+		require.NoError(t, err)
+		assert.False(t, legacyFormat)
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		got, err := s.AllByUserID(context.Background(), "1")
+		require.NoError(t, err)
+		require.Equal(t, 2, len(got))
+		assert.False(t, got[0].IsDeleted)
+		assert.True(t, got[1].IsDeleted)
+
+		stats, err := s.GetStats(context.Background(), core.StatsRange{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, stats.URLs)
+		assert.Equal(t, 2, stats.Users)
+	})
+
+	t.Run("should migrate v0 file which starts with batch", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		legacy := `[{"id":"1","url":"https://vk.com/1","userId":{"String":"1","Valid":true},"isDeleted":false},{"id":"2","url":"https://vk.com/2","userId":{"String":"1","Valid":true},"isDeleted":false}]
+{"id":"3","url":"https://vk.com/3","userId":{"String":"1","Valid":true},"isDeleted":false}
+`
+		require.NoError(t, os.WriteFile(path, []byte(legacy), 0644))
+
+		legacyFormat, err := isLegacyLog(path)
+		require.NoError(t, err)
+		assert.True(t, legacyFormat)
+
+		s := newTestStore(t, path)
+		require.NoError(t, s.Close())
+
+		backup, err := os.ReadFile(path + ".v0")
+		require.NoError(t, err)
+		assert.Equal(t, legacy, string(backup))
+
+		s = newTestStore(t, path)
+		defer s.Close()
+
+		got, err := s.AllByUserID(context.Background(), "1")
+		require.NoError(t, err)
+		assert.Equal(t, 3, len(got))
+	})
+}
+
+func Test_clickRepository(t *testing.T) {
+	t.Run("should restore clicks after reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json.clicks")
+		createdAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		s, err := NewClickStore(zap.NewNop(), path)
+		require.NoError(t, err)
+
+		require.NoError(t, s.AddClicks(context.Background(), []*core.Click{
+			{ShortURLID: "1", CreatedAt: createdAt, Referrer: "https://ya.ru", UserAgent: "curl", IP: "10.0.0.1"},
+			{ShortURLID: "2", CreatedAt: createdAt},
+		}))
+		require.NoError(t, s.Close())
+
+		s, err = NewClickStore(zap.NewNop(), path)
+		require.NoError(t, err)
+		defer s.Close()
+
+		require.Len(t, s.clicks, 2)
+		assert.Equal(t, core.Click{ShortURLID: "1", CreatedAt: createdAt, Referrer: "https://ya.ru", UserAgent: "curl", IP: "10.0.0.1"}, *s.clicks[0])
+		assert.Equal(t, "2", s.clicks[1].ShortURLID)
+	})
+
+	t.Run("should truncate broken tail", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json.clicks")
+
+		s, err := NewClickStore(zap.NewNop(), path)
+		require.NoError(t, err)
+		require.NoError(t, s.AddClicks(context.Background(), []*core.Click{{ShortURLID: "1"}}))
+		require.NoError(t, s.Close())
+
+		torn, err := encodeRecord(recordTypeClick, &core.Click{ShortURLID: "torn"})
+		require.NoError(t, err)
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = file.Write(torn[:len(torn)/2])
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		s, err = NewClickStore(zap.NewNop(), path)
+		require.NoError(t, err)
+		require.NoError(t, s.AddClicks(context.Background(), []*core.Click{{ShortURLID: "2"}}))
+		require.NoError(t, s.Close())
+
+		s, err = NewClickStore(zap.NewNop(), path)
+		require.NoError(t, err)
+		defer s.Close()
+
+		require.Len(t, s.clicks, 2)
+		assert.Equal(t, "2", s.clicks[1].ShortURLID)
+	})
+
+	t.Run("should error and keep clicks after corrupt line in middle", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store.json.clicks")
+
+		first, err := encodeRecord(recordTypeClick, &core.Click{ShortURLID: "1"})
+		require.NoError(t, err)
+		last, err := encodeRecord(recordTypeClick, &core.Click{ShortURLID: "2"})
+		require.NoError(t, err)
+
+		content := append(append(first, "not a record\n"...), last...)
+		require.NoError(t, os.WriteFile(path, content, 0644))
+
+		_, err = NewClickStore(zap.NewNop(), path)
+		require.ErrorContains(t, err, "invalid click at offset")
+
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+	})
+}
+
+func Test_webhookRepository(t *testing.T) {
+	t.Run("should restore webhooks and cursor after reopen", func(t *testing.T) {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "store.json.webhooks")
+
+		s, err := NewWebhookStore(path)
+		require.NoError(t, err)
+		require.NoError(t, s.AddWebhook(ctx, &core.Webhook{ID: "hook1", UserID: "1", URL: "https://example.com", Events: []core.EventType{core.EventCreated}}))
+		_, err = s.MarkWebhookFailed(ctx, "hook1", 5)
+		require.NoError(t, err)
+		require.NoError(t, s.SaveWebhookCursor(ctx, 7))
+
+		s, err = NewWebhookStore(path)
+		require.NoError(t, err)
+
+		got, err := s.ListWebhooks(ctx, "1")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(got))
+		assert.Equal(t, 1, got[0].Failures)
+
+		cursor, err := s.WebhookCursor(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), cursor)
+
+		_, err = os.Stat(path + ".tmp")
+		assert.True(t, os.IsNotExist(err))
+	})
+}
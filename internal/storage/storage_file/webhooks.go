@@ -0,0 +1,224 @@
+package storagefile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	_ repositories.WebhookRepository = (*webhookRepository)(nil)
+)
+
+// webhookState content of file of webhooks
+type webhookState struct {
+	Webhooks []*core.Webhook `json:"webhooks"`
+	Cursor   int64           `json:"cursor"`
+}
+
+// webhookRepository webhooks in separate file. Data is small, so file is rewritten on every change
+// through temp file and rename and is never half written
+type webhookRepository struct {
+	path  string
+	state webhookState
+	mutex *sync.RWMutex
+}
+
+// NewWebhookStore read file of webhooks
+func NewWebhookStore(path string) (*webhookRepository, error) {
+	s := &webhookRepository{
+		path:  path,
+		mutex: &sync.RWMutex{},
+	}
+
+	data, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("error load webhooks %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// save write state into temp file and rename it
+func (s *webhookRepository) save(state webhookState) error {
+	data, err := json.Marshal(&state)
+
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+
+		return err
+	}
+
+	s.state = state
+
+	return nil
+}
+
+// update apply change to copy of state and save it. State in memory is changed only after success write
+func (s *webhookRepository) update(change func(state *webhookState) error) error {
+	state := webhookState{
+		Webhooks: make([]*core.Webhook, len(s.state.Webhooks)),
+		Cursor:   s.state.Cursor,
+	}
+
+	for i, webhook := range s.state.Webhooks {
+		state.Webhooks[i] = copyWebhook(webhook)
+	}
+
+	if err := change(&state); err != nil {
+		return err
+	}
+
+	return s.save(state)
+}
+
+// AddWebhook Добавить подписку
+func (s *webhookRepository) AddWebhook(_ context.Context, webhook *core.Webhook) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(state *webhookState) error {
+		state.Webhooks = append(state.Webhooks, copyWebhook(webhook))
+
+		return nil
+	})
+}
+
+// ListWebhooks подписки пользователя
+func (s *webhookRepository) ListWebhooks(_ context.Context, userID string) ([]*core.Webhook, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*core.Webhook
+
+	for _, webhook := range s.state.Webhooks {
+		if webhook.UserID == userID {
+			result = append(result, copyWebhook(webhook))
+		}
+	}
+
+	return result, nil
+}
+
+// DeleteWebhook удалить подписку пользователя
+func (s *webhookRepository) DeleteWebhook(_ context.Context, userID, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(state *webhookState) error {
+		for i, webhook := range state.Webhooks {
+			if webhook.ID == id && webhook.UserID == userID {
+				state.Webhooks = append(state.Webhooks[:i:i], state.Webhooks[i+1:]...)
+
+				return nil
+			}
+		}
+
+		return core.ErrWebhookNotFound
+	})
+}
+
+// MarkWebhookFailed еще одна неудачная доставка подряд
+func (s *webhookRepository) MarkWebhookFailed(_ context.Context, id string, disableAfter int) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	disabled := false
+
+	err := s.update(func(state *webhookState) error {
+		for _, webhook := range state.Webhooks {
+			if webhook.ID == id {
+				webhook.Failures++
+				webhook.Disabled = webhook.Failures >= disableAfter
+				disabled = webhook.Disabled
+			}
+		}
+
+		return nil
+	})
+
+	return disabled, err
+}
+
+// MarkWebhookDelivered сбросить счетчик неудачных доставок
+func (s *webhookRepository) MarkWebhookDelivered(_ context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Файл не переписывается после каждой успешной доставки, если сбрасывать нечего
+	if !hasWebhookFailures(s.state.Webhooks, id) {
+		return nil
+	}
+
+	return s.update(func(state *webhookState) error {
+		for _, webhook := range state.Webhooks {
+			if webhook.ID == id {
+				webhook.Failures = 0
+			}
+		}
+
+		return nil
+	})
+}
+
+func hasWebhookFailures(webhooks []*core.Webhook, id string) bool {
+	for _, webhook := range webhooks {
+		if webhook.ID == id {
+			return webhook.Failures > 0
+		}
+	}
+
+	return false
+}
+
+// WebhookCursor курсор ленты событий, доставка которых завершена
+func (s *webhookRepository) WebhookCursor(_ context.Context) (int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.state.Cursor, nil
+}
+
+// SaveWebhookCursor сохранить курсор ленты событий
+func (s *webhookRepository) SaveWebhookCursor(_ context.Context, cursor int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(state *webhookState) error {
+		state.Cursor = cursor
+
+		return nil
+	})
+}
+
+func copyWebhook(webhook *core.Webhook) *core.Webhook {
+	result := *webhook
+	result.Events = append([]core.EventType(nil), webhook.Events...)
+
+	return &result
+}
@@ -0,0 +1,47 @@
+package storagememory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	_ repositories.ClickRepository = (*clickRepository)(nil)
+)
+
+type clickRepository struct {
+	clicks []*core.Click
+	mutex  *sync.RWMutex
+}
+
+// NewClickStore create memo store of clicks
+func NewClickStore() *clickRepository {
+	return &clickRepository{
+		mutex: &sync.RWMutex{},
+	}
+}
+
+// AddClicks Добавить пачку кликов
+func (s *clickRepository) AddClicks(_ context.Context, clicks []*core.Click) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, click := range clicks {
+		stored := *click
+		s.clicks = append(s.clicks, &stored)
+	}
+
+	return nil
+}
+
+// GetClickStats статистика кликов по короткой ссылке
+func (s *clickRepository) GetClickStats(_ context.Context, shortURLID string, from, to time.Time, top int) (*core.ClickStats, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return repositories.AggregateClicks(s.clicks, shortURLID, from, to, top), nil
+}
@@ -0,0 +1,26 @@
+package storagememory
+
+import (
+	"testing"
+
+	"github.com/shreyner/go-shortener/internal/repositories"
+	"github.com/shreyner/go-shortener/internal/repositories/repotest"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.ShortURLRepository {
+		return NewShortURLStore()
+	})
+}
+
+func TestClickConformance(t *testing.T) {
+	repotest.RunClicks(t, func(t *testing.T) repositories.ClickRepository {
+		return NewClickStore()
+	})
+}
+
+func TestWebhookConformance(t *testing.T) {
+	repotest.RunWebhooks(t, func(t *testing.T) repositories.WebhookRepository {
+		return NewWebhookStore()
+	})
+}
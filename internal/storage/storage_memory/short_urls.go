@@ -3,26 +3,43 @@ package storagememory
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/shreyner/go-shortener/internal/core"
 	"github.com/shreyner/go-shortener/internal/repositories"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
 )
 
 var (
 	_ repositories.ShortURLRepository = (*shortURLRepository)(nil)
+	_ repositories.ShortURLScanner    = (*shortURLRepository)(nil)
+	_ repositories.EventRepository    = (*shortURLRepository)(nil)
 )
 
 type shortURLRepository struct {
-	store map[string]*core.ShortURL
-	mutex *sync.RWMutex
+	store  map[string]*core.ShortURL
+	urls   map[string]string
+	events []*core.Event
+	// trimmed count of events dropped from start of feed, eventsRetention count of last events in feed
+	trimmed         int64
+	eventsRetention int
+	// changes changes of url by short url, lastChangeID is ID of last change of all short urls
+	changes      map[string][]*core.URLChange
+	lastChangeID int64
+	mutex        *sync.RWMutex
 }
 
 // NewShortURLStore create memo store
 func NewShortURLStore() *shortURLRepository {
 	return &shortURLRepository{
-		store: map[string]*core.ShortURL{},
-		mutex: &sync.RWMutex{},
+		store:   map[string]*core.ShortURL{},
+		urls:    map[string]string{},
+		changes: map[string][]*core.URLChange{},
+		mutex:   &sync.RWMutex{},
+
+		eventsRetention: core.EventsRetention,
 	}
 }
 
@@ -30,11 +47,57 @@ func NewShortURLStore() *shortURLRepository {
 func (s *shortURLRepository) Add(_ context.Context, shortURL *core.ShortURL) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.store[shortURL.ID] = shortURL
+
+	if id, ok := s.activeIDByURL(shortURL.URL, time.Now()); ok {
+		return storeerrors.NewShortURLCreateConflictError(id)
+	}
+
+	if _, ok := s.store[shortURL.ID]; ok {
+		return storeerrors.NewShortURLIDConflictError(shortURL.ID)
+	}
+
+	s.put(shortURL)
+	s.addEvent(core.EventCreated, shortURL, time.Now())
 
 	return nil
 }
 
+// put сохраняет копию, чтобы изменения снаружи не попадали в store
+func (s *shortURLRepository) put(shortURL *core.ShortURL) {
+	stored := *shortURL
+
+	s.store[shortURL.ID] = &stored
+
+	if !shortURL.IsDeleted {
+		s.urls[shortURL.URL] = shortURL.ID
+	}
+}
+
+// activeIDByURL ID активной ссылки с url. Запись удаленной или истекшей ссылки в индексе считается свободной
+func (s *shortURLRepository) activeIDByURL(url string, now time.Time) (string, bool) {
+	id, ok := s.urls[url]
+
+	if !ok || !s.store[id].IsActive(now) {
+		return "", false
+	}
+
+	return id, true
+}
+
+// addEvent добавить событие в ленту, вызывается под блокировкой записи вместе с изменением
+func (s *shortURLRepository) addEvent(eventType core.EventType, shortURL *core.ShortURL, now time.Time) {
+	event := core.NewEvent(eventType, shortURL, now)
+	event.ID = s.trimmed + int64(len(s.events)) + 1
+
+	s.events = append(s.events, event)
+
+	if len(s.events) > s.eventsRetention {
+		s.events[0] = nil
+		s.events = s.events[1:]
+		s.trimmed++
+	}
+}
+
 // GetByID Получить короткую ссылку по идентификатору
 func (s *shortURLRepository) GetByID(_ context.Context, id string) (*core.ShortURL, bool) {
 	s.mutex.RLock()
@@ -61,16 +124,73 @@ func (s *shortURLRepository) AllByUserID(_ context.Context, id string) ([]*core.
 		}
 	}
 
+	// Порядок обхода map случайный, отдаем ссылки стабильно по идентификатору
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
 	return result, nil
 }
 
+// ListByUserID получить страницу ссылок пользователя
+func (s *shortURLRepository) ListByUserID(_ context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error) {
+	s.mutex.RLock()
+
+	var shortURLs []*core.ShortURL
+
+	for _, shortURL := range s.store {
+		if shortURL.UserID.Valid && shortURL.UserID.String == id {
+			shortURLs = append(shortURLs, shortURL)
+		}
+	}
+
+	s.mutex.RUnlock()
+
+	return repositories.PageShortURLs(shortURLs, opts)
+}
+
 // CreateBatch Добавление ссылок пачкой
 func (s *shortURLRepository) CreateBatch(_ context.Context, shortURLs *[]*core.ShortURL) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	batchURLs := make(map[string]string, len(*shortURLs))
+	batchIDs := make(map[string]struct{}, len(*shortURLs))
+	now := time.Now()
+
 	for _, v := range *shortURLs {
-		s.store[v.ID] = v
+		if _, ok := s.store[v.ID]; ok {
+			return storeerrors.NewShortURLIDConflictError(v.ID)
+		}
+
+		if _, ok := batchIDs[v.ID]; ok {
+			return storeerrors.NewShortURLIDConflictError(v.ID)
+		}
+
+		batchIDs[v.ID] = struct{}{}
+
+		if v.IsDeleted {
+			continue
+		}
+
+		if id, ok := s.activeIDByURL(v.URL, now); ok {
+			return storeerrors.NewShortURLCreateConflictError(id)
+		}
+
+		if id, ok := batchURLs[v.URL]; ok {
+			return storeerrors.NewShortURLCreateConflictError(id)
+		}
+
+		batchURLs[v.URL] = v.ID
+	}
+
+	for _, v := range *shortURLs {
+		s.put(v)
+		s.addEvent(core.EventCreated, v, now)
+
+		if v.IsDeleted {
+			s.addEvent(core.EventDeleted, v, now)
+		}
 	}
 
 	return nil
@@ -81,40 +201,196 @@ func (s *shortURLRepository) DeleteURLsUserByIds(_ context.Context, userID strin
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	now := time.Now()
+
 	for _, id := range ids {
 		shortURL, ok := s.store[id]
 
-		if !ok || (shortURL.UserID.Valid && shortURL.UserID.String != userID) {
+		if !ok || shortURL.IsDeleted || !shortURL.UserID.Valid || shortURL.UserID.String != userID {
 			continue
 		}
 
-		shortURL.IsDeleted = true
+		// Заменяем копией, ранее выданные ссылки читаются без блокировки
+		deleted := *shortURL
+		deleted.IsDeleted = true
+		s.store[id] = &deleted
+		s.addEvent(core.EventDeleted, &deleted, now)
 	}
 
 	return nil
 }
 
+// DeleteExpired Пометить удаленными ссылки с истекшим сроком жизни
+func (s *shortURLRepository) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	count := 0
+
+	for id, shortURL := range s.store {
+		if shortURL.IsDeleted || !shortURL.IsExpired(now) {
+			continue
+		}
+
+		deleted := *shortURL
+		deleted.IsDeleted = true
+		s.store[id] = &deleted
+		s.addEvent(core.EventExpired, &deleted, now)
+		count++
+	}
+
+	return count, nil
+}
+
+// UpdateURL Изменить url короткой ссылки владельцем, изменение и событие пишутся под одной блокировкой
+func (s *shortURLRepository) UpdateURL(_ context.Context, change *core.URLChange, redirect core.RedirectUpdate) (*core.ShortURL, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	shortURL := s.store[change.ShortURLID]
+
+	if err := repositories.CheckURLChange(shortURL, change.UserID); err != nil {
+		return nil, err
+	}
+
+	change.OldURL = shortURL.URL
+
+	updated := *shortURL
+	redirect.ApplyTo(&updated)
+
+	if shortURL.URL == change.NewURL {
+		s.put(&updated)
+
+		return s.store[change.ShortURLID], nil
+	}
+
+	if id, ok := s.activeIDByURL(change.NewURL, time.Now()); ok {
+		return nil, storeerrors.NewShortURLCreateConflictError(id)
+	}
+
+	updated.URL = change.NewURL
+
+	delete(s.urls, shortURL.URL)
+	s.put(&updated)
+
+	s.lastChangeID++
+	change.ID = s.lastChangeID
+
+	stored := *change
+	s.changes[change.ShortURLID] = append(s.changes[change.ShortURLID], &stored)
+	s.addEvent(core.EventUpdated, &updated, change.ChangedAt)
+
+	return s.store[change.ShortURLID], nil
+}
+
+// URLChanges изменения url короткой ссылки по порядку
+func (s *shortURLRepository) URLChanges(_ context.Context, shortURLID string) ([]*core.URLChange, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	changes := s.changes[shortURLID]
+	result := make([]*core.URLChange, len(changes))
+
+	for i, change := range changes {
+		copied := *change
+		result[i] = &copied
+	}
+
+	return result, nil
+}
+
+// SetRedirect Изменить тип редиректа и max-age короткой ссылки владельцем
+func (s *shortURLRepository) SetRedirect(_ context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	shortURL := s.store[id]
+
+	if err := repositories.CheckURLChange(shortURL, userID); err != nil {
+		return nil, err
+	}
+
+	updated := *shortURL
+	update.ApplyTo(&updated)
+
+	s.put(&updated)
+
+	return s.store[id], nil
+}
+
+// EventsAfter события ленты после after
+func (s *shortURLRepository) EventsAfter(_ context.Context, after int64, limit int) ([]*core.Event, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	start := after - s.trimmed
+
+	if start < 0 {
+		start = 0
+	}
+
+	if start >= int64(len(s.events)) {
+		return nil, nil
+	}
+
+	events := s.events[start:]
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	result := make([]*core.Event, len(events))
+	copy(result, events)
+
+	return result, nil
+}
+
 // GetStats return stats
-func (s *shortURLRepository) GetStats(_ context.Context) (*core.ShortStats, error) {
+func (s *shortURLRepository) GetStats(_ context.Context, statsRange core.StatsRange) (*core.ShortStats, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	setUsers := make(map[string]byte)
+	shortURLs := make([]*core.ShortURL, 0, len(s.store))
+	var size int64
 
-	for _, url := range s.store {
-		if !url.UserID.Valid {
-			continue
-		}
+	for _, shortURL := range s.store {
+		shortURLs = append(shortURLs, shortURL)
+		size += int64(len(shortURL.ID) + len(shortURL.URL) + len(shortURL.UserID.String) + len(shortURL.CorrelationID))
+	}
+
+	shortStats := repositories.AggregateShortURLs(shortURLs, statsRange)
+	shortStats.StorageSize = size
+
+	return shortStats, nil
+}
+
+// ScanAll обойти все ссылки с идентификатором больше afterID по порядку
+func (s *shortURLRepository) ScanAll(ctx context.Context, afterID string, fn func(shortURL *core.ShortURL) error) error {
+	s.mutex.RLock()
+
+	shortURLs := make([]*core.ShortURL, 0, len(s.store))
 
-		if _, ok := setUsers[url.UserID.String]; !ok {
-			setUsers[url.UserID.String] = 1
+	for id, shortURL := range s.store {
+		if id > afterID {
+			shortURLs = append(shortURLs, shortURL)
 		}
 	}
 
-	shortStats := core.ShortStats{
-		URLs:  len(s.store),
-		Users: len(setUsers),
+	s.mutex.RUnlock()
+
+	sort.Slice(shortURLs, func(i, j int) bool {
+		return shortURLs[i].ID < shortURLs[j].ID
+	})
+
+	for _, shortURL := range shortURLs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(shortURL); err != nil {
+			return err
+		}
 	}
 
-	return &shortStats, nil
+	return nil
 }
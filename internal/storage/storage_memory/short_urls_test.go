@@ -27,6 +27,7 @@ func Test_shortURLRepository_Add(t *testing.T) {
 
 		s := &shortURLRepository{
 			store: storeMap,
+			urls:  map[string]string{},
 			mutex: &sync.RWMutex{},
 		}
 		shortURL := &core.ShortURL{
@@ -236,6 +237,29 @@ func Test_shortURLRepository_DeleteURLsUserByIds(t *testing.T) {
 	})
 }
 
+func Test_shortURLRepository_EventsRetention(t *testing.T) {
+	t.Run("should keep last events and continue IDs of new events", func(t *testing.T) {
+		ctx := context.Background()
+		s := NewShortURLStore()
+		s.eventsRetention = 2
+
+		for _, id := range []string{"1", "2", "3", "4"} {
+			assert.NoError(t, s.Add(ctx, &core.ShortURL{ID: id, URL: "https://vk.com/" + id}))
+		}
+
+		got, err := s.EventsAfter(ctx, 0, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(got))
+		assert.Equal(t, int64(3), got[0].ID)
+		assert.Equal(t, int64(4), got[1].ID)
+
+		got, err = s.EventsAfter(ctx, 3, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(got))
+		assert.Equal(t, "4", got[0].ShortURLID)
+	})
+}
+
 //func Test_shortURLRepository_CreateBatchWithContext(t *testing.T) {
 //	storeMap := map[string]*core.ShortURL{}
 //
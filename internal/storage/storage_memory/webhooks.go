@@ -0,0 +1,132 @@
+package storagememory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	_ repositories.WebhookRepository = (*webhookRepository)(nil)
+)
+
+type webhookRepository struct {
+	webhooks []*core.Webhook
+	cursor   int64
+	mutex    *sync.RWMutex
+}
+
+// NewWebhookStore create memo store of webhooks
+func NewWebhookStore() *webhookRepository {
+	return &webhookRepository{
+		mutex: &sync.RWMutex{},
+	}
+}
+
+// AddWebhook Добавить подписку
+func (s *webhookRepository) AddWebhook(_ context.Context, webhook *core.Webhook) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.webhooks = append(s.webhooks, copyWebhook(webhook))
+
+	return nil
+}
+
+// ListWebhooks подписки пользователя
+func (s *webhookRepository) ListWebhooks(_ context.Context, userID string) ([]*core.Webhook, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var result []*core.Webhook
+
+	for _, webhook := range s.webhooks {
+		if webhook.UserID == userID {
+			result = append(result, copyWebhook(webhook))
+		}
+	}
+
+	return result, nil
+}
+
+// DeleteWebhook удалить подписку пользователя
+func (s *webhookRepository) DeleteWebhook(_ context.Context, userID, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, webhook := range s.webhooks {
+		if webhook.ID == id && webhook.UserID == userID {
+			s.webhooks = append(s.webhooks[:i:i], s.webhooks[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return core.ErrWebhookNotFound
+}
+
+// MarkWebhookFailed еще одна неудачная доставка подряд
+func (s *webhookRepository) MarkWebhookFailed(_ context.Context, id string, disableAfter int) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	webhook := s.find(id)
+
+	if webhook == nil {
+		return false, nil
+	}
+
+	webhook.Failures++
+	webhook.Disabled = webhook.Failures >= disableAfter
+
+	return webhook.Disabled, nil
+}
+
+// MarkWebhookDelivered сбросить счетчик неудачных доставок
+func (s *webhookRepository) MarkWebhookDelivered(_ context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if webhook := s.find(id); webhook != nil {
+		webhook.Failures = 0
+	}
+
+	return nil
+}
+
+// WebhookCursor курсор ленты событий, доставка которых завершена
+func (s *webhookRepository) WebhookCursor(_ context.Context) (int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.cursor, nil
+}
+
+// SaveWebhookCursor сохранить курсор ленты событий
+func (s *webhookRepository) SaveWebhookCursor(_ context.Context, cursor int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.cursor = cursor
+
+	return nil
+}
+
+func (s *webhookRepository) find(id string) *core.Webhook {
+	for _, webhook := range s.webhooks {
+		if webhook.ID == id {
+			return webhook
+		}
+	}
+
+	return nil
+}
+
+func copyWebhook(webhook *core.Webhook) *core.Webhook {
+	result := *webhook
+	result.Events = append([]core.EventType(nil), webhook.Events...)
+
+	return &result
+}
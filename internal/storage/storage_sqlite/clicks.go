@@ -0,0 +1,160 @@
+package storagesqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	_ repositories.ClickRepository = (*clickRepository)(nil)
+)
+
+type clickRepository struct {
+	log *zap.Logger
+	db  *sql.DB
+}
+
+// NewClickStore create sqlite store of clicks
+func NewClickStore(log *zap.Logger, db *sql.DB) *clickRepository {
+	return &clickRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// AddClicks Добавить пачку кликов в одной транзакции
+func (s *clickRepository) AddClicks(ctx context.Context, clicks []*core.Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(
+		ctx,
+		`insert into click (short_url_id, created_at, referrer, user_agent, ip) values (?, ?, ?, ?, ?);`,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		_, err := stmt.ExecContext(ctx, click.ShortURLID, click.CreatedAt.UTC(), click.Referrer, click.UserAgent, click.IP)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetClickStats статистика кликов по короткой ссылке
+func (s *clickRepository) GetClickStats(ctx context.Context, shortURLID string, from, to time.Time, top int) (*core.ClickStats, error) {
+	var stats core.ClickStats
+
+	err := s.db.QueryRowContext(
+		ctx,
+		`select count(*), count(distinct nullif(ip, '')) from click where short_url_id = ? and created_at >= ? and created_at < ?;`,
+		shortURLID, from.UTC(), to.UTC(),
+	).Scan(&stats.Total, &stats.UniqueVisitors)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select substr(created_at, 1, 10) as day, count(*) from click
+		where short_url_id = ? and created_at >= ? and created_at < ?
+		group by day order by day;`,
+		shortURLID, from.UTC(), to.UTC(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var day string
+		var clicks int
+
+		if err := rows.Scan(&day, &clicks); err != nil {
+			return nil, err
+		}
+
+		dayTime, err := time.Parse("2006-01-02", day)
+
+		if err != nil {
+			return nil, fmt.Errorf("error parse day of clicks %q: %w", day, err)
+		}
+
+		stats.Days = append(stats.Days, core.DayCount{Day: dayTime, Count: clicks})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.TopReferrers, err = s.topValues(ctx, "referrer", shortURLID, from, to, top); err != nil {
+		return nil, err
+	}
+
+	if stats.TopUserAgents, err = s.topValues(ctx, "user_agent", shortURLID, from, to, top); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// topValues самые частые значения колонки column
+func (s *clickRepository) topValues(
+	ctx context.Context,
+	column, shortURLID string,
+	from, to time.Time,
+	top int,
+) ([]core.ValueCount, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		fmt.Sprintf(
+			`select %[1]s, count(*) as count from click
+			where short_url_id = ? and created_at >= ? and created_at < ? and %[1]s <> ''
+			group by %[1]s order by count desc, %[1]s limit ?;`,
+			column,
+		),
+		shortURLID, from.UTC(), to.UTC(), top,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	values := make([]core.ValueCount, 0, top)
+
+	for rows.Next() {
+		var value core.ValueCount
+
+		if err := rows.Scan(&value.Value, &value.Count); err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
@@ -0,0 +1,140 @@
+package storagesqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// schema versions of database schema. Index of item + 1 is value of PRAGMA user_version
+var schema = []string{
+	`
+	create table if not exists short_url
+	(
+		id             text                              not null primary key,
+		url            text                              not null,
+		user_id        text,
+		created_at     timestamp default current_timestamp not null,
+		correlation_id text,
+		deleted        boolean   default false           not null
+	);
+
+	create unique index if not exists short_url_uindex
+		on short_url (url);
+
+	create index if not exists short_url_user_id_index
+		on short_url (user_id);
+	`,
+	`
+	create index if not exists short_url_user_id_created_at_index
+		on short_url (user_id, created_at, id);
+	`,
+	`
+	alter table short_url add column expires_at timestamp;
+
+	create index if not exists short_url_expires_at_index
+		on short_url (expires_at) where not deleted and expires_at is not null;
+	`,
+	`
+	create table if not exists click
+	(
+		id           integer primary key autoincrement,
+		short_url_id text      not null,
+		created_at   timestamp not null,
+		referrer     text      not null default '',
+		user_agent   text      not null default '',
+		ip           text      not null default ''
+	);
+
+	create index if not exists click_short_url_id_created_at_index
+		on click (short_url_id, created_at);
+	`,
+	`
+	create table if not exists outbox
+	(
+		id           integer primary key autoincrement,
+		type         text      not null,
+		short_url_id text      not null,
+		url          text      not null,
+		user_id      text      not null default '',
+		created_at   timestamp not null
+	);
+	`,
+	`
+	create table if not exists webhook
+	(
+		id         text      not null primary key,
+		user_id    text      not null,
+		url        text      not null,
+		secret     text      not null,
+		events     text      not null,
+		failures   integer   not null default 0,
+		disabled   boolean   not null default false,
+		created_at timestamp not null
+	);
+
+	create index if not exists webhook_user_id_index
+		on webhook (user_id, created_at);
+
+	create table if not exists webhook_cursor
+	(
+		id     integer not null primary key check (id = 1),
+		cursor integer not null
+	);
+	`,
+	`
+	create table if not exists url_change
+	(
+		id           integer primary key autoincrement,
+		short_url_id text      not null,
+		user_id      text      not null,
+		old_url      text      not null,
+		new_url      text      not null,
+		changed_at   timestamp not null
+	);
+
+	create index if not exists url_change_short_url_id_index
+		on url_change (short_url_id, id);
+	`,
+	`
+	alter table short_url add column redirect_type integer not null default 0;
+	alter table short_url add column cache_max_age integer;
+	`,
+	`
+	drop index if exists short_url_uindex;
+
+	create unique index if not exists short_url_uindex
+		on short_url (url) where not deleted;
+	`,
+}
+
+// migrate apply versions of schema which greater PRAGMA user_version
+func (s *StorageSQLite) migrate(ctx context.Context) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+
+	if err := tx.QueryRowContext(ctx, `pragma user_version;`).Scan(&version); err != nil {
+		return err
+	}
+
+	if version >= len(schema) {
+		return nil
+	}
+
+	for i := version; i < len(schema); i++ {
+		if _, err := tx.ExecContext(ctx, schema[i]); err != nil {
+			return fmt.Errorf("error apply schema version %d: %w", i+1, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`pragma user_version = %d;`, len(schema))); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,688 @@
+package storagesqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+	storeerrors "github.com/shreyner/go-shortener/internal/storage/store_errors"
+)
+
+var (
+	_ repositories.ShortURLRepository = (*shortURLRepository)(nil)
+	_ repositories.ShortURLScanner    = (*shortURLRepository)(nil)
+	_ repositories.EventRepository    = (*shortURLRepository)(nil)
+)
+
+type shortURLRepository struct {
+	log *zap.Logger
+	db  *sql.DB
+}
+
+// NewShortURLStore create sqlite store
+func NewShortURLStore(log *zap.Logger, db *sql.DB) *shortURLRepository {
+	return &shortURLRepository{
+		log: log,
+		db:  db,
+	}
+}
+
+// Add Добавить короткую ссылку в store вместе с событием created
+func (s *shortURLRepository) Add(ctx context.Context, shortURL *core.ShortURL) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := expireByURL(ctx, tx, shortURL.URL, time.Now()); err != nil {
+		return err
+	}
+
+	var resultID string
+
+	err = tx.QueryRowContext(
+		ctx,
+		`insert into short_url (id, url, user_id, created_at, expires_at, redirect_type, cache_max_age) values (?, ?, ?, ?, ?, ?, ?) on conflict (url) where not deleted do update set url=excluded.url returning id;`,
+		shortURL.ID,
+		shortURL.URL,
+		shortURL.UserID,
+		shortURL.CreatedAt.UTC(),
+		nullTimeUTC(shortURL.ExpiresAt),
+		shortURL.RedirectType,
+		shortURL.CacheMaxAge,
+	).Scan(&resultID)
+
+	if isPrimaryKeyViolation(err) {
+		return storeerrors.NewShortURLIDConflictError(shortURL.ID)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if resultID != shortURL.ID {
+		return storeerrors.NewShortURLCreateConflictError(resultID)
+	}
+
+	if err := insertEvents(ctx, tx, core.EventCreated, `id = ?`, shortURL.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetByID Получить короткую ссылку по идентификатору
+func (s *shortURLRepository) GetByID(ctx context.Context, id string) (*core.ShortURL, bool) {
+	var shortURL core.ShortURL
+
+	err := s.db.QueryRowContext(
+		ctx,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where id = ?`,
+		id,
+	).Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge)
+
+	if err != nil {
+		return nil, false
+	}
+
+	return &shortURL, true
+}
+
+// AllByUserID получить все ссылки по идентификатору пользователя
+func (s *shortURLRepository) AllByUserID(ctx context.Context, id string) ([]*core.ShortURL, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where user_id = ? order by rowid`,
+		id,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var shortURLs []*core.ShortURL
+
+	for rows.Next() {
+		shortURL := core.ShortURL{}
+
+		if err := rows.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge); err != nil {
+			return nil, err
+		}
+
+		shortURLs = append(shortURLs, &shortURL)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shortURLs, nil
+}
+
+// ListByUserID получить страницу ссылок пользователя. Следующая страница ищется по ключу сортировки из курсора
+func (s *shortURLRepository) ListByUserID(ctx context.Context, id string, opts core.ListOptions) (*core.ShortURLPage, error) {
+	cursor, err := repositories.DecodeCursor(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	limit := repositories.NormalizeLimit(opts.Limit)
+
+	direction, compare := "asc", ">"
+
+	if opts.Desc {
+		direction, compare = "desc", "<"
+	}
+
+	query := `select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where user_id = ?`
+	args := []interface{}{id}
+
+	if opts.SortBy == core.SortByCreatedAt {
+		if cursor != nil {
+			query += ` and (created_at, id) ` + compare + ` (?, ?)`
+			args = append(args, cursor.CreatedAt.UTC(), cursor.ID)
+		}
+
+		query += ` order by created_at ` + direction + `, id ` + direction
+	} else {
+		if cursor != nil {
+			query += ` and id ` + compare + ` ?`
+			args = append(args, cursor.ID)
+		}
+
+		query += ` order by id ` + direction
+	}
+
+	query += ` limit ?`
+	args = append(args, limit+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var page core.ShortURLPage
+
+	for rows.Next() {
+		shortURL := core.ShortURL{}
+
+		if err := rows.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge); err != nil {
+			return nil, err
+		}
+
+		page.ShortURLs = append(page.ShortURLs, &shortURL)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.ShortURLs) > limit {
+		page.ShortURLs = page.ShortURLs[:limit]
+		page.NextCursor = repositories.EncodeCursor(page.ShortURLs[limit-1], opts)
+	}
+
+	return &page, nil
+}
+
+// CreateBatch Добавление ссылок пачкой в одной транзакции.
+// Уже сокращенный url, как и в Add, возвращает ShortURLCreateConflictError
+func (s *shortURLRepository) CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `insert into short_url (id, url, user_id, correlation_id, created_at, expires_at, redirect_type, cache_max_age, deleted) values (?, ?, ?, ?, ?, ?, ?, ?, ?) on conflict (url) where not deleted do update set url=excluded.url returning id;`)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	now := time.Now()
+
+	for _, v := range *shortURLs {
+		if !v.IsDeleted {
+			if err := expireByURL(ctx, tx, v.URL, now); err != nil {
+				return err
+			}
+		}
+
+		var resultID string
+
+		err := stmt.QueryRowContext(ctx, v.ID, v.URL, v.UserID, v.CorrelationID, v.CreatedAt.UTC(), nullTimeUTC(v.ExpiresAt), v.RedirectType, v.CacheMaxAge, v.IsDeleted).Scan(&resultID)
+
+		if isPrimaryKeyViolation(err) {
+			return storeerrors.NewShortURLIDConflictError(v.ID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if resultID != v.ID {
+			return storeerrors.NewShortURLCreateConflictError(resultID)
+		}
+
+		if err := insertEvents(ctx, tx, core.EventCreated, `id = ?`, v.ID); err != nil {
+			return err
+		}
+
+		if !v.IsDeleted {
+			continue
+		}
+
+		if err := insertEvents(ctx, tx, core.EventDeleted, `id = ?`, v.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteURLsUserByIds Удаление пачкой коротких ссылок от имени пользователя
+func (s *shortURLRepository) DeleteURLsUserByIds(ctx context.Context, userID string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, userID)
+
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	where := `not deleted and user_id = ? and id in (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertEvents(ctx, tx, core.EventDeleted, where, args...); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `update short_url set deleted = true where `+where+`;`, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExpired Пометить удаленными ссылки с истекшим сроком жизни
+func (s *shortURLRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	where := `not deleted and expires_at is not null and expires_at <= ?`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := insertEvents(ctx, tx, core.EventExpired, where, now.UTC()); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, `update short_url set deleted = true where `+where+`;`, now.UTC())
+
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+// UpdateURL Изменить url короткой ссылки владельцем, изменение и событие пишутся в той же транзакции
+func (s *shortURLRepository) UpdateURL(ctx context.Context, change *core.URLChange, redirect core.RedirectUpdate) (*core.ShortURL, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var shortURL core.ShortURL
+
+	err = tx.QueryRowContext(
+		ctx,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where id = ?`,
+		change.ShortURLID,
+	).Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.ErrShortURLNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repositories.CheckURLChange(&shortURL, change.UserID); err != nil {
+		return nil, err
+	}
+
+	change.OldURL = shortURL.URL
+	redirect.ApplyTo(&shortURL)
+
+	if shortURL.URL == change.NewURL {
+		if redirect.IsEmpty() {
+			return &shortURL, nil
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`update short_url set redirect_type = ?, cache_max_age = ? where id = ?;`,
+			shortURL.RedirectType, shortURL.CacheMaxAge, shortURL.ID,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+
+		return &shortURL, nil
+	}
+
+	if err := expireByURL(ctx, tx, change.NewURL, change.ChangedAt); err != nil {
+		return nil, err
+	}
+
+	var conflictID string
+
+	err = tx.QueryRowContext(ctx, `select id from short_url where url = ? and not deleted`, change.NewURL).Scan(&conflictID)
+
+	if err == nil {
+		return nil, storeerrors.NewShortURLCreateConflictError(conflictID)
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update short_url set url = ?, redirect_type = ?, cache_max_age = ? where id = ?;`,
+		change.NewURL, shortURL.RedirectType, shortURL.CacheMaxAge, change.ShortURLID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var changeID int64
+
+	err = tx.QueryRowContext(
+		ctx,
+		`insert into url_change (short_url_id, user_id, old_url, new_url, changed_at) values (?, ?, ?, ?, ?) returning id;`,
+		change.ShortURLID, change.UserID, change.OldURL, change.NewURL, change.ChangedAt.UTC(),
+	).Scan(&changeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertEvents(ctx, tx, core.EventUpdated, `id = ?`, change.ShortURLID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	change.ID = changeID
+	shortURL.URL = change.NewURL
+
+	return &shortURL, nil
+}
+
+// SetRedirect Изменить тип редиректа и max-age короткой ссылки владельцем
+func (s *shortURLRepository) SetRedirect(ctx context.Context, userID, id string, update core.RedirectUpdate) (*core.ShortURL, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var shortURL core.ShortURL
+
+	err = tx.QueryRowContext(
+		ctx,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where id = ?`,
+		id,
+	).Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.ErrShortURLNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repositories.CheckURLChange(&shortURL, userID); err != nil {
+		return nil, err
+	}
+
+	update.ApplyTo(&shortURL)
+
+	_, err = tx.ExecContext(
+		ctx,
+		`update short_url set redirect_type = ?, cache_max_age = ? where id = ?;`,
+		shortURL.RedirectType, shortURL.CacheMaxAge, id,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &shortURL, nil
+}
+
+// URLChanges изменения url короткой ссылки по порядку
+func (s *shortURLRepository) URLChanges(ctx context.Context, shortURLID string) ([]*core.URLChange, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select id, short_url_id, user_id, old_url, new_url, changed_at from url_change where short_url_id = ? order by id`,
+		shortURLID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var changes []*core.URLChange
+
+	for rows.Next() {
+		change := core.URLChange{}
+
+		if err := rows.Scan(&change.ID, &change.ShortURLID, &change.UserID, &change.OldURL, &change.NewURL, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// expireByURL mark deleted expired short url with url, so url can be shorted again. Event expired is written as in DeleteExpired
+func expireByURL(ctx context.Context, tx *sql.Tx, url string, now time.Time) error {
+	where := `not deleted and url = ? and expires_at is not null and expires_at <= ?`
+
+	if err := insertEvents(ctx, tx, core.EventExpired, where, url, now.UTC()); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `update short_url set deleted = true where `+where+`;`, url, now.UTC())
+
+	return err
+}
+
+// insertEvents write into outbox events for short urls matched by where in transaction of change
+func insertEvents(ctx context.Context, tx *sql.Tx, eventType core.EventType, where string, args ...interface{}) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`insert into outbox (type, short_url_id, url, user_id, created_at)
+		select ?, id, url, coalesce(user_id, ''), ? from short_url where `+where+` order by rowid;`,
+		append([]interface{}{string(eventType), time.Now().UTC().Truncate(time.Microsecond)}, args...)...,
+	)
+
+	return err
+}
+
+// EventsAfter события ленты после after. Запись в sqlite последовательна, поэтому ID растут в порядке фиксации
+func (s *shortURLRepository) EventsAfter(ctx context.Context, after int64, limit int) ([]*core.Event, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select id, type, short_url_id, url, user_id, created_at from outbox where id > ? order by id limit ?`,
+		after, limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []*core.Event
+
+	for rows.Next() {
+		event := core.Event{}
+
+		if err := rows.Scan(&event.ID, &event.Type, &event.ShortURLID, &event.URL, &event.UserID, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetStats return stats
+func (s *shortURLRepository) GetStats(ctx context.Context, statsRange core.StatsRange) (*core.ShortStats, error) {
+	var shortStats core.ShortStats
+
+	err := s.db.QueryRowContext(
+		ctx,
+		`select count(*),
+			count(*) filter (where deleted),
+			count(distinct user_id) filter (where user_id <> ''),
+			count(distinct user_id) filter (where user_id <> '' and created_at >= ? and created_at < ?)
+		from short_url;`,
+		statsRange.From.UTC(), statsRange.To.UTC(),
+	).Scan(&shortStats.URLs, &shortStats.DeletedURLs, &shortStats.Users, &shortStats.ActiveUsers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRowContext(
+		ctx,
+		`select page_count * page_size from pragma_page_count(), pragma_page_size();`,
+	).Scan(&shortStats.StorageSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select substr(created_at, 1, 10) as day, count(*) from short_url
+		where created_at >= ? and created_at < ?
+		group by day;`,
+		statsRange.From.UTC(), statsRange.To.UTC(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	days := map[time.Time]int{}
+
+	for rows.Next() {
+		var day string
+		var count int
+
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+
+		dayTime, err := time.Parse(core.StatsDayLayout, day)
+
+		if err != nil {
+			return nil, fmt.Errorf("error parse day of short urls %q: %w", day, err)
+		}
+
+		days[dayTime] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	shortStats.CreatedPerDay = repositories.FillDays(days, statsRange)
+
+	return &shortStats, nil
+}
+
+// ScanAll обойти все ссылки с идентификатором больше afterID по порядку
+func (s *shortURLRepository) ScanAll(ctx context.Context, afterID string, fn func(shortURL *core.ShortURL) error) error {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`select id, url, user_id, deleted, created_at, expires_at, redirect_type, cache_max_age from short_url where id > ? order by id`,
+		afterID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		shortURL := core.ShortURL{}
+
+		if err := rows.Scan(&shortURL.ID, &shortURL.URL, &shortURL.UserID, &shortURL.IsDeleted, &shortURL.CreatedAt, &shortURL.ExpiresAt, &shortURL.RedirectType, &shortURL.CacheMaxAge); err != nil {
+			return err
+		}
+
+		if err := fn(&shortURL); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// nullTimeUTC value of nullable time in UTC for compare with stored values
+func nullTimeUTC(t sql.NullTime) interface{} {
+	if !t.Valid {
+		return nil
+	}
+
+	return t.Time.UTC()
+}
+
+// isPrimaryKeyViolation short url with same id already exists
+func isPrimaryKeyViolation(err error) bool {
+	var sqliteError *sqlite.Error
+
+	return errors.As(err, &sqliteError) && sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
@@ -0,0 +1,65 @@
+// Package storagesqlite хранилище во встроенной базе SQLite
+package storagesqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+)
+
+// DSNPrefix prefix of database dsn for select sqlite storage. For example sqlite://data/shortener.db
+const DSNPrefix = "sqlite://"
+
+// StorageSQLite storage include connection to sqlite database
+type StorageSQLite struct {
+	DB *sql.DB
+}
+
+// NewStorageSQLite open database by dsn sqlite://path in WAL mode and apply schema
+func NewStorageSQLite(log *zap.Logger, dsn string) (*StorageSQLite, error) {
+	path := strings.TrimPrefix(dsn, DSNPrefix)
+
+	if path == "" {
+		return nil, fmt.Errorf("path to sqlite database is required: %s", dsn)
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?%s", path, params.Encode()))
+
+	if err != nil {
+		log.Error("error when open sqlite", zap.Error(err))
+		return nil, err
+	}
+
+	storage := &StorageSQLite{
+		DB: db,
+	}
+
+	if err := storage.migrate(context.Background()); err != nil {
+		db.Close()
+
+		return nil, fmt.Errorf("error when apply sqlite schema: %w", err)
+	}
+
+	return storage, nil
+}
+
+// PingContext check connection to db
+func (s *StorageSQLite) PingContext(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+// Close connection to db
+func (s *StorageSQLite) Close() error {
+	return s.DB.Close()
+}
@@ -25,3 +25,20 @@ func NewShortURLCreateConflictError(originID string) error {
 		OriginID: originID,
 	}
 }
+
+// ShortURLIDConflictError short url with same ID already exists. Returned when custom alias was taken
+type ShortURLIDConflictError struct {
+	ID string
+}
+
+// Error return string about error
+func (s *ShortURLIDConflictError) Error() string {
+	return fmt.Sprintf("short url with id: %s already exists", s.ID)
+}
+
+// NewShortURLIDConflictError constructor error
+func NewShortURLIDConflictError(id string) error {
+	return &ShortURLIDConflictError{
+		ID: id,
+	}
+}
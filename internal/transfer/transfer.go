@@ -0,0 +1,216 @@
+// Package transfer copy short urls between stores and verify result
+//
+// Example use:
+//
+//	progress, err := transfer.Copy(ctx, log, src, dst, transfer.Options{BatchSize: 500})
+//	result, err := transfer.Verify(ctx, src, dst)
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+var (
+	defaultBatchSize = 500
+
+	// ErrScanNotSupported source store can't iterate over all short urls
+	ErrScanNotSupported = errors.New("store does not support scan of all short urls")
+)
+
+// Options params of copy
+type Options struct {
+	// BatchSize count of short urls in one CreateBatch to destination
+	BatchSize int
+	// CheckpointPath file for save progress. If file exists copy continue after last saved ID
+	CheckpointPath string
+	// OnProgress called after every batch
+	OnProgress func(progress Progress)
+}
+
+// Progress state of copy
+type Progress struct {
+	LastID  string `json:"lastId"`
+	Copied  int    `json:"copied"`
+	Skipped int    `json:"skipped"`
+	Total   int    `json:"-"`
+}
+
+// Copy stream all short urls from src into dst by batches ordered by ID.
+// Short urls which already exist in dst are skipped, so copy can be repeated after fail
+func Copy(
+	ctx context.Context,
+	log *zap.Logger,
+	src repositories.ShortURLRepository,
+	dst repositories.ShortURLRepository,
+	opts Options,
+) (*Progress, error) {
+	scanner, ok := src.(repositories.ShortURLScanner)
+
+	if !ok {
+		return nil, ErrScanNotSupported
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	progress, err := loadCheckpoint(opts.CheckpointPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if progress.LastID != "" {
+		log.Info("resume copy from checkpoint", zap.String("lastID", progress.LastID), zap.Int("copied", progress.Copied))
+	}
+
+	// Нужно только общее число ссылок, пустой период не считает статистику по дням
+	stats, err := src.GetStats(ctx, core.StatsRange{})
+
+	if err != nil {
+		return nil, fmt.Errorf("error get stats of source: %w", err)
+	}
+
+	progress.Total = stats.URLs
+
+	batch := make([]*core.ShortURL, 0, opts.BatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		copied, err := writeBatch(ctx, dst, batch)
+
+		if err != nil {
+			return err
+		}
+
+		progress.Copied += copied
+		progress.Skipped += len(batch) - copied
+		progress.LastID = batch[len(batch)-1].ID
+		batch = batch[:0]
+
+		if err := saveCheckpoint(opts.CheckpointPath, progress); err != nil {
+			return err
+		}
+
+		if opts.OnProgress != nil {
+			opts.OnProgress(*progress)
+		}
+
+		return nil
+	}
+
+	err = scanner.ScanAll(ctx, progress.LastID, func(shortURL *core.ShortURL) error {
+		batch = append(batch, shortURL)
+
+		if len(batch) < opts.BatchSize {
+			return nil
+		}
+
+		return flush()
+	})
+
+	if err != nil {
+		return progress, err
+	}
+
+	if err := flush(); err != nil {
+		return progress, err
+	}
+
+	return progress, nil
+}
+
+// writeBatch create in dst not existing short urls, deleted are created as deleted, and mark deleted existing. Return count of created
+func writeBatch(ctx context.Context, dst repositories.ShortURLRepository, batch []*core.ShortURL) (int, error) {
+	newShortURLs := make([]*core.ShortURL, 0, len(batch))
+	deletedByUser := map[string][]string{}
+
+	for _, shortURL := range batch {
+		existing, ok := dst.GetByID(ctx, shortURL.ID)
+
+		if ok && existing.URL != shortURL.URL {
+			return 0, fmt.Errorf("short url %s has different url in destination: %s", shortURL.ID, existing.URL)
+		}
+
+		if !ok {
+			newShortURL := *shortURL
+			newShortURLs = append(newShortURLs, &newShortURL)
+			continue
+		}
+
+		if shortURL.IsDeleted && shortURL.UserID.Valid && !existing.IsDeleted {
+			deletedByUser[shortURL.UserID.String] = append(deletedByUser[shortURL.UserID.String], shortURL.ID)
+		}
+	}
+
+	if len(newShortURLs) > 0 {
+		if err := dst.CreateBatch(ctx, &newShortURLs); err != nil {
+			return 0, fmt.Errorf("error create batch in destination: %w", err)
+		}
+	}
+
+	for userID, ids := range deletedByUser {
+		if err := dst.DeleteURLsUserByIds(ctx, userID, ids); err != nil {
+			return 0, fmt.Errorf("error mark deleted in destination: %w", err)
+		}
+	}
+
+	return len(newShortURLs), nil
+}
+
+func loadCheckpoint(path string) (*Progress, error) {
+	var progress Progress
+
+	if path == "" {
+		return &progress, nil
+	}
+
+	data, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return &progress, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, fmt.Errorf("error parse checkpoint %s: %w", path, err)
+	}
+
+	return &progress, nil
+}
+
+// saveCheckpoint write progress into temp file and rename it, so checkpoint is never half written
+func saveCheckpoint(path string, progress *Progress) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(progress)
+
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
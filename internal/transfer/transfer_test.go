@@ -0,0 +1,139 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+	"github.com/shreyner/go-shortener/internal/repositories/repotest"
+	storagememory "github.com/shreyner/go-shortener/internal/storage/storage_memory"
+)
+
+// failingRepository fail CreateBatch after limit calls
+type failingRepository struct {
+	repositories.ShortURLRepository
+	limit int
+}
+
+func (r *failingRepository) CreateBatch(ctx context.Context, shortURLs *[]*core.ShortURL) error {
+	if r.limit == 0 {
+		return errors.New("destination is unavailable")
+	}
+
+	r.limit--
+
+	return r.ShortURLRepository.CreateBatch(ctx, shortURLs)
+}
+
+func newSource(t *testing.T, count int) repositories.ShortURLRepository {
+	src := storagememory.NewShortURLStore()
+
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("id%03d", i)
+		userID := fmt.Sprintf("user%d", i%3)
+
+		require.NoError(t, src.Add(context.Background(), repotest.NewShortURL(id, "https://vk.com/"+id, userID)))
+	}
+
+	require.NoError(t, src.DeleteURLsUserByIds(context.Background(), "user1", []string{"id001", "id004"}))
+
+	return src
+}
+
+func TestCopy(t *testing.T) {
+	t.Run("should copy all with deleted flags", func(t *testing.T) {
+		src := newSource(t, 10)
+		dst := storagememory.NewShortURLStore()
+
+		var calls int
+
+		progress, err := Copy(context.Background(), zap.NewNop(), src, dst, Options{
+			BatchSize: 3,
+			OnProgress: func(_ Progress) {
+				calls++
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 10, progress.Copied)
+		assert.Equal(t, 10, progress.Total)
+		assert.Equal(t, 4, calls)
+
+		got, ok := dst.GetByID(context.Background(), "id004")
+		require.True(t, ok)
+		assert.True(t, got.IsDeleted)
+		assert.Equal(t, "user1", got.UserID.String)
+
+		result, err := Verify(context.Background(), src, dst)
+		require.NoError(t, err)
+		assert.True(t, result.Match())
+		assert.Equal(t, 10, result.Destination.Count)
+	})
+
+	t.Run("should resume from checkpoint after fail", func(t *testing.T) {
+		src := newSource(t, 10)
+		dst := storagememory.NewShortURLStore()
+		checkpointPath := filepath.Join(t.TempDir(), "checkpoint")
+
+		_, err := Copy(context.Background(), zap.NewNop(), src, &failingRepository{ShortURLRepository: dst, limit: 2}, Options{
+			BatchSize:      3,
+			CheckpointPath: checkpointPath,
+		})
+		require.Error(t, err)
+
+		result, err := Verify(context.Background(), src, dst)
+		require.NoError(t, err)
+		assert.False(t, result.Match())
+		assert.Equal(t, 6, result.Destination.Count)
+
+		progress, err := Copy(context.Background(), zap.NewNop(), src, dst, Options{
+			BatchSize:      3,
+			CheckpointPath: checkpointPath,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 10, progress.Copied)
+		assert.Equal(t, "id009", progress.LastID)
+
+		result, err = Verify(context.Background(), src, dst)
+		require.NoError(t, err)
+		assert.True(t, result.Match())
+	})
+
+	t.Run("should skip already copied", func(t *testing.T) {
+		src := newSource(t, 5)
+		dst := storagememory.NewShortURLStore()
+
+		require.NoError(t, dst.Add(context.Background(), repotest.NewShortURL("id000", "https://vk.com/id000", "user0")))
+
+		progress, err := Copy(context.Background(), zap.NewNop(), src, dst, Options{BatchSize: 2})
+
+		require.NoError(t, err)
+		assert.Equal(t, 4, progress.Copied)
+		assert.Equal(t, 1, progress.Skipped)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("should detect different deleted flag", func(t *testing.T) {
+		src := newSource(t, 5)
+		dst := storagememory.NewShortURLStore()
+
+		_, err := Copy(context.Background(), zap.NewNop(), src, dst, Options{})
+		require.NoError(t, err)
+
+		require.NoError(t, dst.DeleteURLsUserByIds(context.Background(), "user2", []string{"id002"}))
+
+		result, err := Verify(context.Background(), src, dst)
+		require.NoError(t, err)
+		assert.False(t, result.Match())
+		assert.Equal(t, result.Source.Count, result.Destination.Count)
+	})
+}
@@ -0,0 +1,96 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/shreyner/go-shortener/internal/core"
+	"github.com/shreyner/go-shortener/internal/repositories"
+)
+
+// Summary count and checksum of all short urls in store.
+// Checksum does not depend on order of short urls
+type Summary struct {
+	Count    int
+	Checksum uint64
+}
+
+// VerifyResult summaries of source and destination
+type VerifyResult struct {
+	Source      Summary
+	Destination Summary
+}
+
+// Match return true if stores have same short urls
+func (r *VerifyResult) Match() bool {
+	return r.Source == r.Destination
+}
+
+// Verify compare count and checksum of short urls in src and dst
+func Verify(ctx context.Context, src, dst repositories.ShortURLRepository) (*VerifyResult, error) {
+	source, err := Summarize(ctx, src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	destination, err := Summarize(ctx, dst)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifyResult{
+		Source:      *source,
+		Destination: *destination,
+	}, nil
+}
+
+// Summarize calculate count and checksum of all short urls in store
+func Summarize(ctx context.Context, repository repositories.ShortURLRepository) (*Summary, error) {
+	scanner, ok := repository.(repositories.ShortURLScanner)
+
+	if !ok {
+		return nil, ErrScanNotSupported
+	}
+
+	var summary Summary
+
+	err := scanner.ScanAll(ctx, "", func(shortURL *core.ShortURL) error {
+		summary.Count++
+		summary.Checksum += checksum(shortURL)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+// checksum of fields which must be same after copy
+func checksum(shortURL *core.ShortURL) uint64 {
+	h := sha256.New()
+
+	for _, field := range []string{shortURL.ID, shortURL.URL, shortURL.UserID.String} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+
+	var flags byte
+
+	if shortURL.UserID.Valid {
+		flags |= 1
+	}
+
+	if shortURL.IsDeleted {
+		flags |= 2
+	}
+
+	h.Write([]byte{flags})
+
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
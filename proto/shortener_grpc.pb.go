@@ -27,6 +27,8 @@ type ShortenerClient interface {
 	CreateBatchShort(ctx context.Context, in *CreateBatchShortRequest, opts ...grpc.CallOption) (*CreateBatchShortResponse, error)
 	ListUserURLs(ctx context.Context, in *ListUserURLsRequest, opts ...grpc.CallOption) (*ListUserURLsResponse, error)
 	DeleteByIDs(ctx context.Context, in *DeleteByIDsRequest, opts ...grpc.CallOption) (*DeleteByIDsResponse, error)
+	GetURLStats(ctx context.Context, in *GetURLStatsRequest, opts ...grpc.CallOption) (*GetURLStatsResponse, error)
+	UpdateShort(ctx context.Context, in *UpdateShortRequest, opts ...grpc.CallOption) (*UpdateShortResponse, error)
 }
 
 type shortenerClient struct {
@@ -78,6 +80,26 @@ func (c *shortenerClient) DeleteByIDs(ctx context.Context, in *DeleteByIDsReques
 	return out, nil
 }
 
+// GetURLStats -
+func (c *shortenerClient) GetURLStats(ctx context.Context, in *GetURLStatsRequest, opts ...grpc.CallOption) (*GetURLStatsResponse, error) {
+	out := new(GetURLStatsResponse)
+	err := c.cc.Invoke(ctx, "/shortener.Shortener/GetURLStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateShort -
+func (c *shortenerClient) UpdateShort(ctx context.Context, in *UpdateShortRequest, opts ...grpc.CallOption) (*UpdateShortResponse, error) {
+	out := new(UpdateShortResponse)
+	err := c.cc.Invoke(ctx, "/shortener.Shortener/UpdateShort", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServer is the server API for Shortener service.
 // All implementations must embed UnimplementedShortenerServer
 // for forward compatibility
@@ -86,6 +108,8 @@ type ShortenerServer interface {
 	CreateBatchShort(context.Context, *CreateBatchShortRequest) (*CreateBatchShortResponse, error)
 	ListUserURLs(context.Context, *ListUserURLsRequest) (*ListUserURLsResponse, error)
 	DeleteByIDs(context.Context, *DeleteByIDsRequest) (*DeleteByIDsResponse, error)
+	GetURLStats(context.Context, *GetURLStatsRequest) (*GetURLStatsResponse, error)
+	UpdateShort(context.Context, *UpdateShortRequest) (*UpdateShortResponse, error)
 	mustEmbedUnimplementedShortenerServer()
 }
 
@@ -112,6 +136,16 @@ func (UnimplementedShortenerServer) ListUserURLs(context.Context, *ListUserURLsR
 func (UnimplementedShortenerServer) DeleteByIDs(context.Context, *DeleteByIDsRequest) (*DeleteByIDsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteByIDs not implemented")
 }
+
+// GetURLStats -
+func (UnimplementedShortenerServer) GetURLStats(context.Context, *GetURLStatsRequest) (*GetURLStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURLStats not implemented")
+}
+
+// UpdateShort -
+func (UnimplementedShortenerServer) UpdateShort(context.Context, *UpdateShortRequest) (*UpdateShortResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateShort not implemented")
+}
 func (UnimplementedShortenerServer) mustEmbedUnimplementedShortenerServer() {}
 
 // UnsafeShortenerServer may be embedded to opt out of forward compatibility for this service.
@@ -198,6 +232,42 @@ func _Shortener_DeleteByIDs_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_GetURLStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).GetURLStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener.Shortener/GetURLStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).GetURLStats(ctx, req.(*GetURLStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_UpdateShort_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateShortRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).UpdateShort(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/shortener.Shortener/UpdateShort",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).UpdateShort(ctx, req.(*UpdateShortRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Shortener_ServiceDesc is the grpc.ServiceDesc for Shortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -221,6 +291,14 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteByIDs",
 			Handler:    _Shortener_DeleteByIDs_Handler,
 		},
+		{
+			MethodName: "GetURLStats",
+			Handler:    _Shortener_GetURLStats_Handler,
+		},
+		{
+			MethodName: "UpdateShort",
+			Handler:    _Shortener_UpdateShort_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/shortener.proto",